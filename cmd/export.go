@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/zde37/Jusgo/internal/controller"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/service"
+)
+
+// exportJokes writes every stored joke to a file, or stdout if none is given, like GET /jokes/export does.
+// The output can be read back with the import subcommand.
+func exportJokes(ctx context.Context, source string, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "ndjson", "output format: ndjson, json or csv")
+	flags.Usage = func() {
+		log.Print("usage: jusgo export [-format ndjson|json|csv] [file]")
+	}
+	flags.Parse(args)
+
+	var out io.Writer = os.Stdout
+	if path := flags.Arg(0); path != "" && path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	buffered := bufio.NewWriter(out)
+	jokeWriter, err := controller.NewJokeWriter(buffered, *format)
+	if err != nil {
+		return err
+	}
+
+	r, closeRepo, err := newRepository(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	count := 0
+	err = service.NewService(r.Repo).Srvc.ExportJokes(ctx, func(joke models.Jusgo) error {
+		count++
+		return jokeWriter.Write(joke)
+	})
+	if err != nil {
+		return err
+	}
+	if err := jokeWriter.Close(); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	log.Printf("exported %d jokes", count)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/zde37/Jusgo/internal/audit"
+	"github.com/zde37/Jusgo/internal/controller"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/service"
+)
+
+// importJokes stores the jokes in the file at path, "-" reads them from stdin, like POST /jokes:batch
+// does but without going through the server. Jokes that can't be stored are logged and skipped.
+func importJokes(ctx context.Context, source, path string) error {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	items, err := controller.DecodeJokeBatch(in)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	r, closeRepo, err := newRepository(ctx, source)
+	if err != nil {
+		return err
+	}
+	defer closeRepo()
+
+	valid := make([]models.Jusgo, 0, len(items))
+	for _, item := range items {
+		if item.Err == nil {
+			valid = append(valid, item.Joke)
+		}
+	}
+
+	// the revisions of imported jokes name the import instead of an admin
+	_, errs, err := service.NewService(r.Repo).Srvc.CreateJokes(audit.WithActor(ctx, "import"), valid)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i, item := range items {
+		if item.Err == nil {
+			item.Err, errs = errs[0], errs[1:]
+		}
+		if item.Err != nil {
+			log.Printf("joke %d: %v", i, item.Err)
+			failed++
+		}
+	}
+
+	log.Printf("imported %d of %d jokes", len(items)-failed, len(items))
+	if failed > 0 {
+		return fmt.Errorf("%d jokes could not be imported", failed)
+	}
+	return nil
+}
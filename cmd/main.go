@@ -2,34 +2,81 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // time zones for the daily joke, in case the host has none installed
 
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/zde37/Jusgo/internal/controller"
 	"github.com/zde37/Jusgo/internal/database"
+	"github.com/zde37/Jusgo/internal/jwt"
+	"github.com/zde37/Jusgo/internal/models"
 	"github.com/zde37/Jusgo/internal/repository"
 	"github.com/zde37/Jusgo/internal/service"
 )
 
 func main() {
-	// Set up MongoDB connection
 	ctx := context.Background()
-	client, cancel, err := database.ConnectToMongoDB(os.Getenv("DB_SOURCE"), ctx)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := migrate(ctx, os.Getenv("DB_SOURCE")); err != nil {
+				log.Fatalf("failed to migrate: %v", err)
+			}
+			return
+		case "import":
+			if len(os.Args) != 3 {
+				log.Fatal("usage: jusgo import <file>")
+			}
+			if err := importJokes(ctx, os.Getenv("DB_SOURCE"), os.Args[2]); err != nil {
+				log.Fatalf("failed to import: %v", err)
+			}
+			return
+		case "export":
+			if err := exportJokes(ctx, os.Getenv("DB_SOURCE"), os.Args[2:]); err != nil {
+				log.Fatalf("failed to export: %v", err)
+			}
+			return
+		}
+	}
+
+	// Set up storage
+	r, closeRepo, err := newRepository(ctx, os.Getenv("DB_SOURCE"))
 	if err != nil {
-		log.Fatalf("failed to connect to mongodb: %v", err)
+		log.Fatalf("failed to set up storage: %v", err)
+	}
+	cacheSize, cacheTTL, err := cacheConfig(os.Getenv("CACHE_SIZE"), os.Getenv("CACHE_TTL"))
+	if err != nil {
+		log.Fatalf("invalid cache config: %v", err)
+	}
+	// the memory repository is as fast as the cache
+	if cacheSize > 0 && !strings.HasPrefix(os.Getenv("DB_SOURCE"), "memory://") {
+		r = repository.NewCachedRepository(r, repository.NewLRUCache(cacheSize), cacheTTL)
+		go logCacheStats(r)
 	}
-	collection := client.Database(os.Getenv("DATABASE")).Collection(os.Getenv("COLLECTION"))
-	r := repository.NewRepository(collection)
 	s := service.NewService(r.Repo)
-	h := controller.NewHandler(s.Srvc)
+	if err := bootstrapAPIKey(ctx, s.Srvc, os.Getenv("TOKEN")); err != nil {
+		log.Fatalf("failed to store the bootstrap key: %v", err)
+	}
+	auth, err := jwtAuth()
+	if err != nil {
+		log.Fatalf("invalid JWT config: %v", err)
+	}
+	h := controller.NewHandler(s.Srvc, auth)
 
-	defer cancel()
-	defer client.Disconnect(ctx)
+	defer closeRepo()
+
+	retention, err := trashRetention(os.Getenv("TRASH_RETENTION"))
+	if err != nil {
+		log.Fatalf("invalid TRASH_RETENTION: %v", err)
+	}
 
 	// setup server
 	srv := &http.Server{
@@ -43,6 +90,10 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	go cronJob()
+	go signJokes(ctx, s.Srvc)
+	if retention > 0 {
+		go purgeTrash(ctx, s.Srvc, retention)
+	}
 
 	go func() {
 		log.Printf("server started on %s", srv.Addr)
@@ -62,6 +113,218 @@ func main() {
 	log.Println("server exited")
 }
 
+// newRepository picks the storage backend from the scheme of the DB_SOURCE uri.
+// "memory://" keeps jokes in memory, "sqlite://<file>" uses a SQLite database file,
+// "postgres://" uses PostgreSQL and anything else is treated as a MongoDB uri.
+func newRepository(ctx context.Context, source string) (*repository.Repository, func(), error) {
+	switch {
+	case strings.HasPrefix(source, "memory://"):
+		log.Println("using in-memory storage, jokes will not be persisted")
+		return repository.NewMemoryRepository(), func() {}, nil
+
+	case strings.HasPrefix(source, "sqlite://"):
+		db, err := database.ConnectToSQLite(strings.TrimPrefix(source, "sqlite://"), ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repository.NewSQLiteRepository(db), func() { db.Close() }, nil
+
+	case isPostgres(source):
+		db, err := database.ConnectToPostgres(source, ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repository.NewPostgresRepository(db), func() { db.Close() }, nil
+	}
+
+	client, cancel, err := database.ConnectToMongoDB(source, ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	db := client.Database(os.Getenv("DATABASE"))
+	collection := db.Collection(os.Getenv("COLLECTION"))
+	submissions := db.Collection(os.Getenv("COLLECTION") + "_submissions")
+	votes := db.Collection(os.Getenv("COLLECTION") + "_votes")
+	revisions := db.Collection(os.Getenv("COLLECTION") + "_revisions")
+	apiKeys := db.Collection(os.Getenv("COLLECTION") + "_api_keys")
+	if err = database.CreateMongoIndexes(ctx, collection, submissions, votes, revisions, apiKeys); err != nil {
+		return nil, nil, err
+	}
+
+	return repository.NewRepository(collection, submissions, votes, revisions, apiKeys), func() {
+		client.Disconnect(ctx)
+		cancel()
+	}, nil
+}
+
+// defaultTrashRetention is how long deleted jokes stay in the trash when TRASH_RETENTION isn't set.
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// trashRetention parses TRASH_RETENTION, a duration like "720h". 0 keeps deleted jokes until they are purged by hand.
+func trashRetention(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultTrashRetention, nil
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if retention < 0 {
+		return 0, fmt.Errorf("must not be negative, got %s", value)
+	}
+	return retention, nil
+}
+
+// purgeTrash permanently removes the jokes that have been in the trash for longer than retention, every hour.
+func purgeTrash(ctx context.Context, s service.ServiceProvider, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		purged, err := s.PurgeTrash(ctx, time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("failed to purge the trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d jokes from the trash", purged)
+		}
+		<-ticker.C
+	}
+}
+
+// signJokes gives the jokes stored before signatures existed one, until then they aren't found as near duplicates.
+func signJokes(ctx context.Context, s service.ServiceProvider) {
+	signed, err := s.SignJokes(ctx)
+	if err != nil {
+		log.Printf("failed to sign jokes: %v", err)
+	} else if signed > 0 {
+		log.Printf("signed %d jokes for duplicate detection", signed)
+	}
+}
+
+// bootstrapAPIKey stores TOKEN as a key with every scope if there are no keys yet, so the first keys can be
+// made with it. Once there are, TOKEN is ignored, revoking the bootstrap key revokes it for good.
+// A TOKEN too short to be safe keeps the server from starting when it would be stored.
+func bootstrapAPIKey(ctx context.Context, s service.ServiceProvider, token string) error {
+	if token == "" {
+		return nil
+	}
+	_, ok, err := s.BootstrapAPIKey(ctx, token)
+	if err != nil {
+		return err
+	}
+	if ok {
+		log.Println("stored TOKEN as the bootstrap key, revoke it once other keys are made")
+	} else {
+		log.Println("TOKEN is ignored, there are API keys already; unset it, it no longer grants access")
+	}
+	return nil
+}
+
+const (
+	defaultCacheSize = 10000
+	defaultCacheTTL  = time.Minute
+)
+
+// cacheConfig parses CACHE_SIZE, how many jokes are kept in the cache, 0 turns it off, and CACHE_TTL,
+// a duration like "30s" for how long they are kept. With several instances, a joke changed through one
+// can be outdated on the others for up to CACHE_TTL.
+func cacheConfig(size, ttl string) (int, time.Duration, error) {
+	cacheSize, cacheTTL := defaultCacheSize, defaultCacheTTL
+	if size != "" {
+		var err error
+		if cacheSize, err = strconv.Atoi(size); err != nil || cacheSize < 0 {
+			return 0, 0, fmt.Errorf("CACHE_SIZE must be a number of jokes, got %q", size)
+		}
+	}
+	if ttl != "" {
+		var err error
+		if cacheTTL, err = time.ParseDuration(ttl); err != nil || cacheTTL <= 0 {
+			return 0, 0, fmt.Errorf("CACHE_TTL must be a positive duration, got %q", ttl)
+		}
+	}
+	return cacheSize, cacheTTL, nil
+}
+
+// defaultJWKSTTL is how long a JWKS is used before it is loaded again, new keys show up earlier anyway.
+const defaultJWKSTTL = time.Hour
+
+// jwtAuth configures JWTs for the admin endpoints, which stay off unless JWT_JWKS or JWT_SECRET is set:
+//   - JWT_ISSUER and JWT_AUDIENCE must match the iss and aud claims of a token
+//   - JWT_JWKS is the file or URL of the JWKS with the keys of the issuer, kept for JWT_JWKS_TTL(default: 1h)
+//   - JWT_SECRET is the key of HS256 tokens, for issuers without a JWKS, at least 32 bytes long
+//   - JWT_ROLES_CLAIM names the claim with the roles(default: roles), JWT_ROLES maps more roles to scopes,
+//     like "editor=jokes:write,jokes:delete;moderator=submissions:moderate", see controller.DefaultRoles
+func jwtAuth() (*controller.JWTAuth, error) {
+	jwks, secret := os.Getenv("JWT_JWKS"), os.Getenv("JWT_SECRET")
+	var keys jwt.KeySource
+	switch {
+	case jwks != "" && secret != "":
+		return nil, fmt.Errorf("set either JWT_JWKS or JWT_SECRET, not both")
+	case jwks != "":
+		ttl := defaultJWKSTTL
+		if value := os.Getenv("JWT_JWKS_TTL"); value != "" {
+			var err error
+			if ttl, err = time.ParseDuration(value); err != nil || ttl <= 0 {
+				return nil, fmt.Errorf("JWT_JWKS_TTL must be a positive duration, got %q", value)
+			}
+		}
+		keys = jwt.NewJWKS(jwks, ttl)
+	case secret != "":
+		var err error
+		if keys, err = jwt.NewHMACKey(secret); err != nil {
+			return nil, fmt.Errorf("JWT_SECRET: %w", err)
+		}
+	default:
+		return nil, nil
+	}
+
+	issuer, audience := os.Getenv("JWT_ISSUER"), os.Getenv("JWT_AUDIENCE")
+	if issuer == "" || audience == "" {
+		return nil, fmt.Errorf("JWT_ISSUER and JWT_AUDIENCE are required")
+	}
+	roles, err := jwtRoles(os.Getenv("JWT_ROLES"))
+	if err != nil {
+		return nil, err
+	}
+	rolesClaim := os.Getenv("JWT_ROLES_CLAIM")
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+	return &controller.JWTAuth{Verifier: jwt.NewVerifier(issuer, audience, keys), RolesClaim: rolesClaim, Roles: roles}, nil
+}
+
+// jwtRoles adds the roles in value, role=scope,scope pairs separated by semicolons, to controller.DefaultRoles.
+func jwtRoles(value string) (map[string][]string, error) {
+	roles := controller.DefaultRoles()
+	for _, pair := range strings.Split(value, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		role, scopes, ok := strings.Cut(pair, "=")
+		role = strings.TrimSpace(role)
+		if !ok || role == "" {
+			return nil, fmt.Errorf("JWT_ROLES must be role=scope,scope pairs separated by semicolons, got %q", pair)
+		}
+		roles[role] = nil
+		for _, scope := range strings.Split(scopes, ",") {
+			scope = strings.TrimSpace(scope)
+			if !models.IsScope(scope) {
+				return nil, fmt.Errorf("JWT_ROLES: unknown scope %q for role %s", scope, role)
+			}
+			roles[role] = append(roles[role], scope)
+		}
+	}
+	return roles, nil
+}
+
+// logCacheStats logs the hits and misses of the joke cache every hour.
+func logCacheStats(r *repository.Repository) {
+	for range time.Tick(time.Hour) {
+		if stats, ok := r.CacheStats(); ok {
+			log.Printf("joke cache: %d hits, %d misses", stats.Hits, stats.Misses)
+		}
+	}
+}
+
 // cronJob sends a request to the health route every 13 minute. To prevent the server from sleeping on render(default: 15 minutes)
 func cronJob() {
 	for range time.Tick(13 * time.Minute) {
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/zde37/Jusgo/internal/database"
+)
+
+// migrate applies pending schema migrations to the SQL database at source and exits.
+// The server also migrates on startup, running this first keeps slow migrations out of a deploy.
+func migrate(ctx context.Context, source string) error {
+	var (
+		db  *sql.DB
+		err error
+	)
+	switch {
+	case strings.HasPrefix(source, "sqlite://"):
+		db, err = database.ConnectToSQLite(strings.TrimPrefix(source, "sqlite://"), ctx)
+	case isPostgres(source):
+		db, err = database.ConnectToPostgres(source, ctx)
+	default:
+		log.Println("DB_SOURCE is not a SQL database, nothing to migrate")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	version, err := database.SchemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	log.Printf("database schema is at version %d", version)
+	return nil
+}
+
+func isPostgres(source string) bool {
+	return strings.HasPrefix(source, "postgres://") || strings.HasPrefix(source, "postgresql://")
+}
@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActor(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, UnknownActor, Actor(ctx))
+	require.Equal(t, UnknownActor, Actor(WithActor(ctx, "")))
+	require.Equal(t, "admin", Actor(WithActor(ctx, "admin")))
+}
+
+func TestRequestID(t *testing.T) {
+	ctx := context.Background()
+	require.Empty(t, RequestID(ctx))
+
+	id := NewRequestID()
+	require.Len(t, id, 32)
+	require.NotEqual(t, id, NewRequestID())
+	require.Equal(t, id, RequestID(WithRequestID(ctx, id)))
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+// maxAPIKeyBytes caps the body of a request for a new API key.
+const maxAPIKeyBytes = 4 << 10
+
+// createdAPIKey is a new API key with its secret, which is never shown again.
+type createdAPIKey struct {
+	models.APIKey
+	Secret string `json:"secret"`
+}
+
+// CreateAPIKey creates an API key. A key can only hand out the scopes it has itself.
+func (h *handlerImpl) CreateAPIKey(w http.ResponseWriter, r *http.Request) error {
+	var req models.APIKeyRequest
+	if err := decodeBody(w, r, maxAPIKeyBytes, &req); err != nil {
+		return err
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return NewErrorStatus(errors.New("expires_at must be in the future"), http.StatusBadRequest)
+	}
+
+	creator, _ := apiKeyFrom(r.Context())
+	for _, scope := range req.Scopes {
+		if !creator.HasScope(scope) {
+			return NewErrorStatus(fmt.Errorf("can't grant the %s scope, the api key doesn't have it", scope), http.StatusForbidden)
+		}
+	}
+
+	key, secret, err := h.service.CreateAPIKey(r.Context(), req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(createdAPIKey{APIKey: key, Secret: secret})
+}
+
+// GetAPIKeys lists every API key, revoked and expired ones included, oldest first.
+func (h *handlerImpl) GetAPIKeys(w http.ResponseWriter, r *http.Request) error {
+	keys, err := h.service.GetAPIKeys(r.Context())
+	if err != nil {
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey stops an API key from working, right away.
+func (h *handlerImpl) RevokeAPIKey(w http.ResponseWriter, r *http.Request) error {
+	_, err := h.service.RevokeAPIKey(r.Context(), r.PathValue("id"))
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return NewErrorStatus(err, http.StatusNotFound)
+	case errors.Is(err, repository.ErrInvalidID):
+		return NewErrorStatus(err, http.StatusBadRequest)
+	case err != nil:
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/audit"
+	mockproviders "github.com/zde37/Jusgo/internal/mock"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/service"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRequireScope(t *testing.T) {
+	key := models.APIKey{ID: repository.NewID(), Name: "ci", Prefix: "jusgo_abcdef", Scopes: []string{models.ScopeJokesWrite}}
+
+	testData := []struct {
+		name   string
+		header string
+		scope  string
+		stub   func(s *mockproviders.MockServiceProvider)
+		status int
+		actor  string
+	}{
+		{name: "no header", scope: models.ScopeJokesWrite, status: http.StatusUnauthorized},
+		{name: "basic", header: "Basic dXNlcjpwYXNz", scope: models.ScopeJokesWrite, status: http.StatusUnauthorized},
+		{
+			name:   "key",
+			header: "Bearer jusgo_abcdef123",
+			scope:  models.ScopeJokesWrite,
+			stub: func(s *mockproviders.MockServiceProvider) {
+				s.EXPECT().AuthenticateAPIKey(gomock.Any(), gomock.Eq("jusgo_abcdef123")).Times(1).Return(key, nil)
+			},
+			status: http.StatusOK,
+			actor:  "ci (jusgo_abcdef)",
+		},
+		{
+			name:   "missing scope",
+			header: "Bearer jusgo_abcdef123",
+			scope:  models.ScopeJokesDelete,
+			stub: func(s *mockproviders.MockServiceProvider) {
+				s.EXPECT().AuthenticateAPIKey(gomock.Any(), gomock.Any()).Times(1).Return(key, nil)
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "invalid key",
+			header: "Bearer roo",
+			scope:  models.ScopeJokesWrite,
+			stub: func(s *mockproviders.MockServiceProvider) {
+				s.EXPECT().AuthenticateAPIKey(gomock.Any(), gomock.Any()).Times(1).Return(models.APIKey{}, service.ErrInvalidAPIKey)
+			},
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := mockproviders.NewMockServiceProvider(ctrl)
+			if tc.stub != nil {
+				tc.stub(s)
+			}
+			h := &handlerImpl{service: s}
+
+			var actor string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, ok := apiKeyFrom(r.Context())
+				require.True(t, ok)
+				actor = audit.Actor(r.Context())
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/keys", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			h.requireScope(tc.scope, next).ServeHTTP(w, r)
+
+			require.Equal(t, tc.status, w.Code)
+			require.Equal(t, tc.actor, actor)
+			if tc.status == http.StatusUnauthorized {
+				require.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
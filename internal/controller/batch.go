@@ -0,0 +1,291 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+const (
+	maxBatchSize  = 1000
+	maxBatchBytes = 4 << 20
+)
+
+// BatchItem is one joke of a batch, ready to be stored unless Err says why it was rejected.
+type BatchItem struct {
+	Joke models.Jusgo
+	Err  error
+}
+
+type batchResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Created int           `json:"created"`
+	Failed  int           `json:"failed"`
+	Results []batchResult `json:"results"`
+}
+
+// CreateJokes stores a JSON array, NDJSON stream or CSV file of jokes. Every joke is validated and stored on its
+// own, the response has a result per joke in the order they were sent.
+func (h *handlerImpl) CreateJokes(w http.ResponseWriter, r *http.Request) error {
+	items, err := decodeJokeBatch(http.MaxBytesReader(w, r.Body, maxBatchBytes), h.validate, maxBatchSize)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return NewErrorStatus(fmt.Errorf("request body must not be larger than %d bytes", maxBatchBytes), http.StatusRequestEntityTooLarge)
+		}
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	valid := make([]models.Jusgo, 0, len(items))
+	for _, item := range items {
+		if item.Err == nil {
+			valid = append(valid, item.Joke)
+		}
+	}
+
+	jokes, errs, err := h.service.CreateJokes(r.Context(), valid)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	resp := batchResponse{Results: make([]batchResult, len(items))}
+	for i, item := range items {
+		result := batchResult{Index: i, Status: http.StatusBadRequest}
+		if item.Err == nil {
+			item.Joke, item.Err = jokes[0], errs[0]
+			jokes, errs = jokes[1:], errs[1:]
+			result.Status = http.StatusCreated
+			switch {
+			case errors.Is(item.Err, repository.ErrDuplicateKey):
+				result.Status = http.StatusConflict
+			case item.Err != nil:
+				result.Status = http.StatusInternalServerError
+			}
+		}
+
+		if item.Err != nil {
+			result.Error = item.Err.Error()
+			resp.Failed++
+		} else {
+			result.ID = item.Joke.ID
+			resp.Created++
+		}
+		resp.Results[i] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// batchJokeRequest is a JokeRequest that may also carry the ID and timestamps of an exported joke,
+// so an export can be imported again without changing them.
+type batchJokeRequest struct {
+	models.JokeRequest
+	ID        string    `json:"id" validate:"omitempty,mongodb"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// joke turns a validated request into a joke ready to be stored, keeping the ID and timestamps it was sent with.
+func (req batchJokeRequest) joke() models.Jusgo {
+	joke := newJoke(req.JokeRequest)
+	joke.ID = req.ID // checked by the validator, the repository gives the joke one if it is empty
+	if !req.CreatedAt.IsZero() {
+		joke.CreatedAt, joke.UpdatedAt = req.CreatedAt, req.CreatedAt
+	}
+	if !req.UpdatedAt.IsZero() {
+		joke.UpdatedAt = req.UpdatedAt
+	}
+	return joke
+}
+
+// decodedRequest is a joke of a batch as it was read, err is set if it couldn't be decoded.
+type decodedRequest struct {
+	req batchJokeRequest
+	err error
+}
+
+// DecodeJokeBatch reads jokes from r like POST /jokes:batch does, without a limit on their number.
+func DecodeJokeBatch(r io.Reader) ([]BatchItem, error) {
+	return decodeJokeBatch(r, newValidator(), 0)
+}
+
+// decodeJokeBatch reads a JSON array of jokes, one JSON joke per line or a CSV file with a header row,
+// telling them apart by their first character. Only errors that make the whole input unusable are returned,
+// a joke that is malformed or fails validation gets its own error. maxItems of zero means no limit.
+func decodeJokeBatch(r io.Reader, validate *validator.Validate, maxItems int) ([]BatchItem, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil, errors.New("request body must not be empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded []decodedRequest
+	switch first {
+	case '[':
+		decoded, err = decodeJSONArray(br)
+	case '{':
+		decoded, err = decodeNDJSON(br)
+	default:
+		decoded, err = decodeCSV(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if maxItems > 0 && len(decoded) > maxItems {
+		return nil, fmt.Errorf("a batch must not have more than %d jokes", maxItems)
+	}
+
+	items := make([]BatchItem, len(decoded))
+	for i, d := range decoded {
+		if d.err == nil {
+			d.err = validate.Struct(d.req)
+		}
+		if d.err != nil {
+			items[i].Err = d.err
+			continue
+		}
+		items[i].Joke = d.req.joke()
+	}
+	return items, nil
+}
+
+func decodeJSONArray(r io.Reader) ([]decodedRequest, error) {
+	var raws []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raws); err != nil {
+		return nil, err
+	}
+
+	decoded := make([]decodedRequest, len(raws))
+	for i, raw := range raws {
+		decoded[i].err = json.Unmarshal(raw, &decoded[i].req)
+	}
+	return decoded, nil
+}
+
+// decodeNDJSON reads one joke per line, a line that isn't valid JSON only fails that joke. Blank lines are skipped.
+func decodeNDJSON(r io.Reader) ([]decodedRequest, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxBatchBytes)
+
+	var decoded []decodedRequest
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var d decodedRequest
+		d.err = json.Unmarshal(line, &d.req)
+		decoded = append(decoded, d)
+	}
+	return decoded, scanner.Err()
+}
+
+// decodeCSV reads jokes in the layout written by the csv export, see csvColumns. Columns can be in any
+// order and all but joke or setup and delivery can be left out.
+func decodeCSV(r io.Reader) ([]decodedRequest, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range header {
+		if !slices.Contains(csvColumns, column) {
+			return nil, fmt.Errorf("unknown CSV column %q, expected some of %s", column, strings.Join(csvColumns, ", "))
+		}
+	}
+
+	var decoded []decodedRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return decoded, nil
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, err
+		}
+
+		d := decodedRequest{err: err}
+		for i, value := range record {
+			if i >= len(header) {
+				break
+			}
+			if err := setCSVField(&d.req, header[i], unescapeCSVFormula(value)); err != nil && d.err == nil {
+				d.err = err
+			}
+		}
+		decoded = append(decoded, d)
+	}
+}
+
+func setCSVField(req *batchJokeRequest, column, value string) error {
+	var err error
+	switch column {
+	case "id":
+		req.ID = value
+	case "type":
+		req.Type = value
+	case "joke":
+		req.Joke = value
+	case "setup":
+		req.Setup = value
+	case "delivery":
+		req.Delivery = value
+	case "category":
+		req.Category = value
+	case "tags":
+		req.Tags = splitList(value)
+	case "created_at":
+		req.CreatedAt, err = parseCSVTime(column, value)
+	case "updated_at":
+		req.UpdatedAt, err = parseCSVTime(column, value)
+	}
+	return err
+}
+
+func parseCSVTime(column, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, expected an RFC 3339 timestamp", column, value)
+	}
+	return t, nil
+}
+
+// peekNonSpace skips leading white space in r and returns the first byte after it without consuming it.
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\n' && b != '\r' {
+			return b, r.UnreadByte()
+		}
+	}
+}
@@ -8,10 +8,12 @@ import (
 type ErrorStatus struct {
 	error
 	statusCode int
+	id         string // the joke the error is about, if any
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
+	ID    string `json:"id,omitempty"`
 }
 
 func (e ErrorStatus) Unwrap() error { return e.error }
@@ -19,9 +21,9 @@ func (e ErrorStatus) Unwrap() error { return e.error }
 func ErrorInfo(err error) (ErrorResponse, int) {
 	var errStatus ErrorStatus
 	if errors.As(err, &errStatus) {
-		return ErrorResponse{errStatus.error.Error()}, errStatus.statusCode
+		return ErrorResponse{errStatus.error.Error(), errStatus.id}, errStatus.statusCode
 	}
-	return ErrorResponse{errors.New("unknown error occurred").Error()}, http.StatusInternalServerError
+	return ErrorResponse{Error: errors.New("unknown error occurred").Error()}, http.StatusInternalServerError
 }
 
 func NewErrorStatus(err error, code int) error {
@@ -29,4 +31,13 @@ func NewErrorStatus(err error, code int) error {
 		error:  err,
 		statusCode: code,
 	}
-}
\ No newline at end of file
+}
+
+// NewErrorStatusWithID is NewErrorStatus for errors about an existing joke, whose id is sent along.
+func NewErrorStatusWithID(err error, code int, id string) error {
+	return ErrorStatus{
+		error:      err,
+		statusCode: code,
+		id:         id,
+	}
+}
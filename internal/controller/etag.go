@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/service"
+)
+
+// jokeETag returns the strong entity tag of joke, "<version>-<hash of its JSON>". The hash makes the tag
+// change with the votes too, while If-Match only looks at the version, so votes never fail a write.
+func jokeETag(joke models.Jusgo) string {
+	body, err := json.Marshal(joke)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return `"` + strconv.FormatInt(joke.Version, 10) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// setETag sets the ETag header of a response holding joke.
+func setETag(w http.ResponseWriter, joke models.Jusgo) {
+	if etag := jokeETag(joke); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+}
+
+// ifMatchVersion returns the version a write may change current at, given the If-Match header of r.
+// Without the header, or with "*", that is any version, unless REQUIRE_IF_MATCH is set and the header
+// is missing. A list matches when one of its strong tags is at the version of current.
+func (h *handlerImpl) ifMatchVersion(r *http.Request, current models.Jusgo) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch header {
+	case "":
+		if h.requireIfMatch {
+			return 0, NewErrorStatus(errors.New("If-Match header is required, send the ETag of the joke"), http.StatusPreconditionRequired)
+		}
+		return service.AnyVersion, nil
+	case "*":
+		return service.AnyVersion, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		if version, ok := etagVersion(strings.TrimSpace(tag)); ok && version == current.Version {
+			return current.Version, nil
+		}
+	}
+	return 0, NewErrorStatus(repository.ErrVersionConflict, http.StatusPreconditionFailed)
+}
+
+// etagVersion returns the version of a strong entity tag made by jokeETag.
+// Weak tags never match under If-Match, so they are not accepted.
+func etagVersion(tag string) (int64, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+	v, err := strconv.ParseInt(version, 10, 64)
+	if err != nil || v < 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// requireIfMatch reports whether writes to a joke must carry an If-Match header, set by REQUIRE_IF_MATCH.
+func requireIfMatch() bool {
+	require, _ := strconv.ParseBool(os.Getenv("REQUIRE_IF_MATCH"))
+	return require
+}
+
+// cacheControl lets clients and shared caches keep public joke responses, but only use them after checking
+// with If-None-Match or If-Modified-Since that they are current, which is cheap thanks to 304 responses.
+const cacheControl = "public, no-cache"
+
+// writeCacheable writes v as the JSON body of a response that can be cached, with etag, or a hash of the
+// body if empty, and lastModified, if set. A client that already has it gets a 304 Not Modified instead.
+// Listings leave lastModified out, a joke leaving the listing doesn't show in the UpdatedAt of the others.
+func writeCacheable(w http.ResponseWriter, r *http.Request, v any, etag string, lastModified time.Time) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+	if etag == "" {
+		sum := sha256.Sum256(body)
+		etag = `"` + hex.EncodeToString(sum[:8]) + `"`
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// notModified reports whether the conditional headers of r say the client has the response with etag
+// and lastModified already. If-Modified-Since only counts without If-None-Match, as RFC 9110 has it.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		if strings.TrimSpace(header) == "*" {
+			return true
+		}
+		// If-None-Match compares weakly, W/"x" matches "x"
+		for _, tag := range strings.Split(header, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		// Last-Modified only has whole seconds
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/service"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	joke := models.Jusgo{ID: repository.NewID(), Joke: "knock knock", Version: 4}
+	etag := jokeETag(joke)
+
+	// votes change the tag but not the version, the old tag still matches
+	voted := joke
+	voted.Upvotes = 1
+	require.NotEqual(t, etag, jokeETag(voted))
+
+	testData := []struct {
+		name    string
+		header  string
+		require bool
+		version int64
+		status  int
+	}{
+		{name: "no header", header: "", version: service.AnyVersion},
+		{name: "no header required", header: "", require: true, status: http.StatusPreconditionRequired},
+		{name: "any", header: "*", require: true, version: service.AnyVersion},
+		{name: "current", header: etag, version: 4},
+		{name: "current after votes", header: jokeETag(voted), version: 4},
+		{name: "list", header: `"3-abc", ` + etag, version: 4},
+		{name: "stale", header: `"3-abc"`, status: http.StatusPreconditionFailed},
+		{name: "weak", header: "W/" + etag, status: http.StatusPreconditionFailed},
+		{name: "garbage", header: "4", status: http.StatusPreconditionFailed},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &handlerImpl{requireIfMatch: tc.require}
+			r := httptest.NewRequest(http.MethodPatch, "/jokes/"+joke.ID, nil)
+			if tc.header != "" {
+				r.Header.Set("If-Match", tc.header)
+			}
+
+			version, err := h.ifMatchVersion(r, joke)
+			if tc.status != 0 {
+				_, status := ErrorInfo(err)
+				require.Equal(t, tc.status, status)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.version, version)
+		})
+	}
+}
+
+func TestWriteCacheable(t *testing.T) {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 500_000_000, time.UTC)
+	joke := models.Jusgo{ID: repository.NewID(), Joke: "knock knock", UpdatedAt: updated}
+	etag := jokeETag(joke)
+
+	testData := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{name: "unconditional", status: http.StatusOK},
+		{name: "same tag", header: map[string]string{"If-None-Match": etag}, status: http.StatusNotModified},
+		{name: "weak tag", header: map[string]string{"If-None-Match": `"x", W/` + etag}, status: http.StatusNotModified},
+		{name: "other tag", header: map[string]string{"If-None-Match": `"x"`}, status: http.StatusOK},
+		{name: "any tag", header: map[string]string{"If-None-Match": "*"}, status: http.StatusNotModified},
+		{name: "not modified since", header: map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)}, status: http.StatusNotModified},
+		{name: "modified since", header: map[string]string{"If-Modified-Since": updated.Add(-time.Second).Format(http.TimeFormat)}, status: http.StatusOK},
+		{
+			name:   "tag wins over date",
+			header: map[string]string{"If-None-Match": `"x"`, "If-Modified-Since": updated.Format(http.TimeFormat)},
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/jokes/"+joke.ID, nil)
+			for key, value := range tc.header {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+
+			require.NoError(t, writeCacheable(w, r, joke, etag, joke.UpdatedAt))
+			require.Equal(t, tc.status, w.Code)
+			require.Equal(t, etag, w.Header().Get("ETag"))
+			require.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", w.Header().Get("Last-Modified"))
+			require.Equal(t, cacheControl, w.Header().Get("Cache-Control"))
+			if tc.status == http.StatusNotModified {
+				require.Zero(t, w.Body.Len())
+			} else {
+				require.Contains(t, w.Body.String(), "knock knock")
+			}
+		})
+	}
+}
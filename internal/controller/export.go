@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/models"
+)
+
+// exportTimeout replaces the usual request timeout for exports, which take as long as the corpus is big.
+const exportTimeout = 10 * time.Minute
+
+// exportFormats are the formats jokes can be exported in, mapped to their content type.
+var exportFormats = map[string]string{
+	"ndjson": "application/x-ndjson",
+	"json":   "application/json",
+	"csv":    "text/csv",
+}
+
+// csvColumns are the columns of a csv export. Tags are a comma separated list, timestamps are RFC 3339.
+// Cells a spreadsheet would run as a formula start with a ', see escapeCSVFormula.
+var csvColumns = []string{"id", "type", "joke", "setup", "delivery", "category", "tags", "created_at", "updated_at"}
+
+// JokeWriter writes jokes in one of the export formats: ndjson, json or csv. Close finishes the output,
+// it doesn't close the underlying writer.
+type JokeWriter interface {
+	Write(joke models.Jusgo) error
+	Close() error
+}
+
+// NewJokeWriter returns a JokeWriter for format that writes to w. Everything it writes can be read back
+// by DecodeJokeBatch.
+func NewJokeWriter(w io.Writer, format string) (JokeWriter, error) {
+	switch format {
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case "json":
+		return &jsonArrayWriter{w: w}, nil
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, errors.New("invalid format, must be ndjson, json or csv")
+	}
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(joke models.Jusgo) error {
+	return w.enc.Encode(joke) // Encode ends every joke with a newline
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+type jsonArrayWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (w *jsonArrayWriter) Write(joke models.Jusgo) error {
+	data, err := json.Marshal(joke)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if !w.started {
+		separator, w.started = "[\n", true
+	}
+	_, err = io.WriteString(w.w, separator+string(data))
+	return err
+}
+
+func (w *jsonArrayWriter) Close() error {
+	end := "\n]\n"
+	if !w.started {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(w.w, end)
+	return err
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(joke models.Jusgo) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	record := []string{
+		joke.ID, joke.Type, joke.Joke, joke.Setup, joke.Delivery, joke.Category, strings.Join(joke.Tags, ","),
+		joke.CreatedAt.UTC().Format(time.RFC3339Nano), joke.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	for i, value := range record {
+		record[i] = escapeCSVFormula(value)
+	}
+	return w.w.Write(record)
+}
+
+// csvFormulaStart are the characters that make spreadsheets run a cell as a formula when it starts with one.
+const csvFormulaStart = "=+-@\t\r"
+
+// escapeCSVFormula puts a ' in front of value if a spreadsheet would run it as a formula. Anyone can submit
+// a joke, opening an export must not run what they wrote. A value that starts with ' gets another one if
+// it would need one without it, so unescapeCSVFormula can tell them apart.
+func escapeCSVFormula(value string) string {
+	if csvFormulaEscaped(value) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVFormula undoes escapeCSVFormula.
+func unescapeCSVFormula(value string) string {
+	if rest, ok := strings.CutPrefix(value, "'"); ok && csvFormulaEscaped(rest) {
+		return rest
+	}
+	return value
+}
+
+// csvFormulaEscaped reports whether escapeCSVFormula puts a ' in front of value.
+func csvFormulaEscaped(value string) bool {
+	for strings.HasPrefix(value, "'") {
+		value = value[1:]
+	}
+	return value != "" && strings.ContainsRune(csvFormulaStart, rune(value[0]))
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil { // an empty export still has its header
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.w.Write(csvColumns)
+}
+
+// ExportJokes streams every joke in the format given by the format param (default: ndjson).
+func (h *handlerImpl) ExportJokes(w http.ResponseWriter, r *http.Request) error {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	jokeWriter, err := NewJokeWriter(w, format)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	// the middleware and the server only give a request a few seconds
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), exportTimeout)
+	defer cancel()
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportTimeout)) // not every ResponseWriter has deadlines
+
+	w.Header().Set("Content-Type", exportFormats[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="jokes-%s.%s"`, time.Now().UTC().Format(time.DateOnly), format))
+	w.WriteHeader(http.StatusOK)
+
+	err = h.service.ExportJokes(ctx, jokeWriter.Write)
+	if err == nil {
+		err = jokeWriter.Close()
+	}
+	if err != nil {
+		// the status is already sent, aborting the response is the only way to tell
+		// the client its export is incomplete
+		log.Printf("export aborted: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	created := time.Date(2024, 2, 29, 13, 37, 0, 123456789, time.UTC)
+	jokes := []models.Jusgo{
+		{
+			ID:        repository.NewID(),
+			Type:      models.TypeSingle,
+			Joke:      `A "quoted", comma separated joke`,
+			Category:  "go",
+			Tags:      []string{"one-liner", "pun"},
+			CreatedAt: created,
+			UpdatedAt: created.Add(time.Hour),
+		},
+		{
+			ID:        repository.NewID(),
+			Type:      models.TypeTwoPart,
+			Setup:     "Why do programmers prefer dark mode?",
+			Delivery:  "Because light attracts bugs.\nLiterally.",
+			Category:  models.DefaultCategory,
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+		{
+			ID:        repository.NewID(),
+			Type:      models.TypeTwoPart,
+			Setup:     `=HYPERLINK("http://example.com","click")`,
+			Delivery:  "'@SUM(1+1)",
+			Category:  "go",
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+	}
+
+	for _, format := range []string{"ndjson", "json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := NewJokeWriter(&buf, format)
+			require.NoError(t, err)
+			for _, joke := range jokes {
+				require.NoError(t, writer.Write(joke))
+			}
+			require.NoError(t, writer.Close())
+
+			items, err := DecodeJokeBatch(&buf)
+			require.NoError(t, err)
+			require.Len(t, items, len(jokes))
+			for i, item := range items {
+				require.NoError(t, item.Err)
+				require.Equal(t, jokes[i].ID, item.Joke.ID)
+				require.Equal(t, jokes[i].Type, item.Joke.Type)
+				require.Equal(t, jokes[i].Joke, item.Joke.Joke)
+				require.Equal(t, jokes[i].Setup, item.Joke.Setup)
+				require.Equal(t, jokes[i].Delivery, item.Joke.Delivery)
+				require.Equal(t, jokes[i].Category, item.Joke.Category)
+				require.Equal(t, jokes[i].Tags, item.Joke.Tags)
+				require.True(t, jokes[i].CreatedAt.Equal(item.Joke.CreatedAt))
+				require.True(t, jokes[i].UpdatedAt.Equal(item.Joke.UpdatedAt))
+			}
+		})
+	}
+}
+
+func TestExportCSVFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewJokeWriter(&buf, "csv")
+	require.NoError(t, err)
+	for _, text := range []string{"=1+1", "+1", "-1", "@A1", "\t=1", "'=1", "'quoted'"} {
+		require.NoError(t, writer.Write(models.Jusgo{ID: repository.NewID(), Type: models.TypeSingle, Joke: text}))
+	}
+	require.NoError(t, writer.Close())
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	var cells []string
+	for _, record := range records[1:] {
+		cells = append(cells, record[2])
+	}
+	require.Equal(t, []string{"'=1+1", "'+1", "'-1", "'@A1", "'\t=1", "''=1", "'quoted'"}, cells)
+}
+
+func TestExportEmpty(t *testing.T) {
+	for _, format := range []string{"ndjson", "json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := NewJokeWriter(&buf, format)
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			if format == "ndjson" {
+				require.Zero(t, buf.Len())
+				return
+			}
+			items, err := DecodeJokeBatch(&buf)
+			require.NoError(t, err)
+			require.Empty(t, items)
+		})
+	}
+}
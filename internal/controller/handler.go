@@ -9,18 +9,41 @@ import (
 type HandlerProvider interface {
 	Mux() *http.ServeMux
 	CreateJoke(w http.ResponseWriter, r *http.Request) error
+	CreateJokes(w http.ResponseWriter, r *http.Request) error
 	GetJoke(w http.ResponseWriter, r *http.Request) error
 	GetAllJokes(w http.ResponseWriter, r *http.Request) error
+	GetRandomJokes(w http.ResponseWriter, r *http.Request) error
+	SearchJokes(w http.ResponseWriter, r *http.Request) error
+	GetTopJokes(w http.ResponseWriter, r *http.Request) error
+	Vote(w http.ResponseWriter, r *http.Request) error
+	ExportJokes(w http.ResponseWriter, r *http.Request) error
+	GetDailyJoke(w http.ResponseWriter, r *http.Request) error
 	UpdateJoke(w http.ResponseWriter, r *http.Request) error
+	PatchJoke(w http.ResponseWriter, r *http.Request) error
 	DeleteJoke(w http.ResponseWriter, r *http.Request) error
+	SubmitJoke(w http.ResponseWriter, r *http.Request) error
+	GetSubmissions(w http.ResponseWriter, r *http.Request) error
+	GetSubmission(w http.ResponseWriter, r *http.Request) error
+	UpdateSubmission(w http.ResponseWriter, r *http.Request) error
+	ApproveSubmission(w http.ResponseWriter, r *http.Request) error
+	RejectSubmission(w http.ResponseWriter, r *http.Request) error
+	GetTrash(w http.ResponseWriter, r *http.Request) error
+	RestoreJoke(w http.ResponseWriter, r *http.Request) error
+	PurgeJoke(w http.ResponseWriter, r *http.Request) error
+	GetJokeHistory(w http.ResponseWriter, r *http.Request) error
+	RevertJoke(w http.ResponseWriter, r *http.Request) error
+	CreateAPIKey(w http.ResponseWriter, r *http.Request) error
+	GetAPIKeys(w http.ResponseWriter, r *http.Request) error
+	RevokeAPIKey(w http.ResponseWriter, r *http.Request) error
 }
 
 type Handler struct {
 	Hndl HandlerProvider
 }
 
-func NewHandler(s service.ServiceProvider) *Handler  {
+// NewHandler returns the handler of every route. auth, if not nil, lets the admin endpoints take JWTs.
+func NewHandler(s service.ServiceProvider, auth *JWTAuth) *Handler  {
 	return &Handler{
-		Hndl: newHandlerImpl(s),
+		Hndl: newHandlerImpl(s, auth),
 	}
 }
\ No newline at end of file
@@ -3,30 +3,44 @@ package controller
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/zde37/Jusgo/internal/cursor"
 	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
 	"github.com/zde37/Jusgo/internal/service"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/time/rate"
 )
 
 type handlerImpl struct {
 	server   *http.ServeMux
 	service  service.ServiceProvider
 	validate *validator.Validate
+	cursors  *cursor.Signer
+
+	requireIfMatch bool     // writes to a joke must send its ETag
+	jwt            *JWTAuth // nil if JWTs aren't accepted
 }
 
-func newHandlerImpl(s service.ServiceProvider) *handlerImpl {
+func newHandlerImpl(s service.ServiceProvider, auth *JWTAuth) *handlerImpl {
 	mux := http.NewServeMux()
 	handlerImpl := &handlerImpl{
 		service:  s,
 		server:   mux,
-		validate: validator.New(),
+		validate: newValidator(),
+		cursors:  cursor.NewSigner(cursorKey()),
+
+		requireIfMatch: requireIfMatch(),
+		jwt:            auth,
 	}
 
 	handlerImpl.RegisterRoutes()
@@ -34,19 +48,59 @@ func newHandlerImpl(s service.ServiceProvider) *handlerImpl {
 	return handlerImpl
 }
 
+// newValidator returns a validator that also knows the joke specific "category" and "joketag" rules,
+// and the "scope" rule for API keys.
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	validate.RegisterValidation("category", func(fl validator.FieldLevel) bool {
+		return models.IsCategory(fl.Field().String())
+	})
+	validate.RegisterValidation("joketag", func(fl validator.FieldLevel) bool {
+		return models.IsTag(fl.Field().String())
+	})
+	validate.RegisterValidation("scope", func(fl validator.FieldLevel) bool {
+		return models.IsScope(fl.Field().String())
+	})
+	return validate
+}
+
 func (h *handlerImpl) Mux() *http.ServeMux {
 	return h.server
 }
 
 func (h *handlerImpl) RegisterRoutes() {
-	rl := newRateLimiter() 
+	rl := newRateLimiter(rate.Limit(1), 5) // 1 request per second, burst of 5
+	// anyone can submit jokes, a few an hour is plenty for a person
+	submissionLimiter := newRateLimiter(rate.Every(10*time.Minute), 3)
 
 	h.server.Handle("GET /hello-world", middleware(h.HealthHandler))
-	h.server.Handle("POST /jokes", limitMiddleware(rl, ensureAdmin(middleware(h.CreateJoke)))) // admin only
+	h.server.Handle("POST /jokes", limitMiddleware(rl, h.requireScope(models.ScopeJokesWrite, middleware(h.CreateJoke))))
+	h.server.Handle("POST /jokes:batch", limitMiddleware(rl, h.requireScope(models.ScopeJokesWrite, middleware(h.CreateJokes))))
+	h.server.Handle("GET /jokes/random", limitMiddleware(rl, middleware(h.GetRandomJokes)))
+	h.server.Handle("GET /jokes/daily", limitMiddleware(rl, middleware(h.GetDailyJoke)))
+	h.server.Handle("GET /jokes/export", h.requireScope(models.ScopeJokesWrite, middleware(h.ExportJokes)))
+	h.server.Handle("GET /jokes/search", limitMiddleware(rl, middleware(h.SearchJokes)))
+	h.server.Handle("GET /jokes/top", limitMiddleware(rl, middleware(h.GetTopJokes)))
+	h.server.Handle("POST /jokes/{id}/vote", limitMiddleware(rl, middleware(h.Vote)))
 	h.server.Handle("GET /jokes/{id}", limitMiddleware(rl, middleware(h.GetJoke)))
 	h.server.Handle("GET /jokes", limitMiddleware(rl, middleware(h.GetAllJokes)))
-	h.server.Handle("PATCH /jokes/{id}", ensureAdmin(middleware(h.UpdateJoke)))  // admin only
-	h.server.Handle("DELETE /jokes/{id}", ensureAdmin(middleware(h.DeleteJoke))) // admin only
+	h.server.Handle("PUT /jokes/{id}", h.requireScope(models.ScopeJokesWrite, middleware(h.UpdateJoke)))
+	h.server.Handle("PATCH /jokes/{id}", h.requireScope(models.ScopeJokesWrite, middleware(h.PatchJoke)))
+	h.server.Handle("DELETE /jokes/{id}", h.requireScope(models.ScopeJokesDelete, middleware(h.DeleteJoke)))
+	h.server.Handle("POST /submissions", limitMiddleware(submissionLimiter, middleware(h.SubmitJoke)))
+	h.server.Handle("GET /submissions", h.requireScope(models.ScopeSubmissionsModerate, middleware(h.GetSubmissions)))
+	h.server.Handle("GET /submissions/{id}", h.requireScope(models.ScopeSubmissionsModerate, middleware(h.GetSubmission)))
+	h.server.Handle("PATCH /submissions/{id}", h.requireScope(models.ScopeSubmissionsModerate, middleware(h.UpdateSubmission)))
+	h.server.Handle("POST /submissions/{id}/approve", h.requireScope(models.ScopeSubmissionsModerate, middleware(h.ApproveSubmission)))
+	h.server.Handle("POST /submissions/{id}/reject", h.requireScope(models.ScopeSubmissionsModerate, middleware(h.RejectSubmission)))
+	h.server.Handle("POST /jokes/{id}/restore", h.requireScope(models.ScopeJokesWrite, middleware(h.RestoreJoke)))
+	h.server.Handle("GET /trash", h.requireScope(models.ScopeJokesDelete, middleware(h.GetTrash)))
+	h.server.Handle("DELETE /trash/{id}", h.requireScope(models.ScopeJokesDelete, middleware(h.PurgeJoke)))
+	h.server.Handle("GET /jokes/{id}/history", h.requireScope(models.ScopeJokesWrite, middleware(h.GetJokeHistory)))
+	h.server.Handle("POST /jokes/{id}/revert/{rev}", h.requireScope(models.ScopeJokesWrite, middleware(h.RevertJoke)))
+	h.server.Handle("POST /keys", h.requireScope(models.ScopeKeysManage, middleware(h.CreateAPIKey)))
+	h.server.Handle("GET /keys", h.requireScope(models.ScopeKeysManage, middleware(h.GetAPIKeys)))
+	h.server.Handle("DELETE /keys/{id}", h.requireScope(models.ScopeKeysManage, middleware(h.RevokeAPIKey)))
 
 	v1 := http.NewServeMux()
 	v1.Handle("/v1/", http.StripPrefix("/v1", h.server))
@@ -74,18 +128,21 @@ func (h *handlerImpl) CreateJoke(w http.ResponseWriter, r *http.Request) error {
 		return NewErrorStatus(err, http.StatusBadRequest)
 	}
 
-	data := models.Jusgo{
-		ID:        primitive.NewObjectID(),
-		Joke:      req.Joke,
-		UpdatedAt: time.Now(),
-		CreatedAt: time.Now(),
+	force, err := parseForceParam(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
 	}
 
-	joke, err := h.service.CreateJoke(r.Context(), data)
+	joke, err := h.service.CreateJoke(r.Context(), newJoke(req), force)
 	if err != nil {
+		var dup *service.DuplicateError
+		if errors.As(err, &dup) {
+			return NewErrorStatusWithID(err, http.StatusConflict, dup.ID)
+		}
 		return NewErrorStatus(err, http.StatusInternalServerError)
 	}
 
+	setETag(w, joke)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	return json.NewEncoder(w).Encode(joke)
@@ -99,15 +156,16 @@ func (h *handlerImpl) GetJoke(w http.ResponseWriter, r *http.Request) error {
 
 	joke, err := h.service.GetJoke(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
+		if errors.Is(err, repository.ErrNotFound) {
 			return NewErrorStatus(err, http.StatusNotFound)
 		}
+		if errors.Is(err, repository.ErrInvalidID) {
+			return NewErrorStatus(err, http.StatusBadRequest)
+		}
 		return NewErrorStatus(err, http.StatusInternalServerError)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	return json.NewEncoder(w).Encode(joke)
+	return writeCacheable(w, r, joke, jokeETag(joke), joke.UpdatedAt)
 }
 
 func (h *handlerImpl) GetAllJokes(w http.ResponseWriter, r *http.Request) error {
@@ -116,14 +174,69 @@ func (h *handlerImpl) GetAllJokes(w http.ResponseWriter, r *http.Request) error
 		return NewErrorStatus(err, http.StatusBadRequest)
 	}
 
-	jokes, err := h.service.GetAllJokes(r.Context(), page, limit)
+	filter, err := parseFilterParams(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+	if err := parseDateRangeParams(r, &filter); err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	sort, err := parseSortParam(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	if r.URL.Query().Has("cursor") {
+		if r.URL.Query().Has("sort") {
+			return NewErrorStatus(errors.New("cursor and sort can't be used together, cursors always page in creation order"), http.StatusBadRequest)
+		}
+		return h.getJokesByCursor(w, r, filter, limit)
+	}
+
+	query := models.JokeQuery{Filter: filter, Sort: sort, Page: page, Limit: limit}
+	jokes, total, err := h.service.GetAllJokes(r.Context(), query)
 	if err != nil {
 		return NewErrorStatus(err, http.StatusInternalServerError)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	return json.NewEncoder(w).Encode(jokes)
+	resp := newPageResponse(r, jokes, page, limit, total)
+	setLinkHeader(w, r, resp)
+	return writeCacheable(w, r, resp, "", time.Time{})
+}
+
+// getJokesByCursor serves GET /jokes in cursor mode. An empty cursor starts at the first joke.
+func (h *handlerImpl) getJokesByCursor(w http.ResponseWriter, r *http.Request, filter models.JokeFilter, limit int) error {
+	if r.URL.Query().Has("page") {
+		return NewErrorStatus(errors.New("cursor and page can't be used together"), http.StatusBadRequest)
+	}
+
+	var after string
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		c, err := h.cursors.Decode(token)
+		if err != nil {
+			return NewErrorStatus(err, http.StatusBadRequest)
+		}
+		after = c.After
+	}
+
+	jokes, more, err := h.service.GetJokesAfter(r.Context(), filter, after, limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidID) {
+			return NewErrorStatus(cursor.ErrInvalid, http.StatusBadRequest)
+		}
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	resp := cursorPageResponse{Data: jokes, Limit: limit}
+	if more {
+		next := h.cursors.Encode(cursor.Cursor{After: jokes[len(jokes)-1].ID})
+		nextURL := listURL(r, "cursor", next)
+		resp.NextCursor, resp.Next = &next, &nextURL
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextURL))
+	}
+
+	return writeCacheable(w, r, resp, "", time.Time{})
 }
 
 func parsePaginationParams(r *http.Request) (int, int, error) {
@@ -131,7 +244,7 @@ func parsePaginationParams(r *http.Request) (int, int, error) {
 	limit := r.URL.Query().Get("limit")
 
 	p := 1
-	l := 10
+	l := defaultPageLimit
 
 	var err error
 	if page != "" {
@@ -143,14 +256,284 @@ func parsePaginationParams(r *http.Request) (int, int, error) {
 
 	if limit != "" {
 		l, err = strconv.Atoi(limit)
-		if err != nil || l < 1 {
-			return 0, 0, errors.New("invalid limit number")
+		if err != nil || l < 1 || l > maxPageLimit {
+			return 0, 0, fmt.Errorf("invalid limit number, must be between 1 and %d", maxPageLimit)
 		}
 	}
 
 	return p, l, nil
 }
 
+const maxSearchLength = 256
+
+// SearchJokes returns a page of the jokes matching the full text query q, best matches first.
+func (h *handlerImpl) SearchJokes(w http.ResponseWriter, r *http.Request) error {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		return NewErrorStatus(errors.New("q is required"), http.StatusBadRequest)
+	}
+	if len(query) > maxSearchLength {
+		return NewErrorStatus(fmt.Errorf("q must not be longer than %d characters", maxSearchLength), http.StatusBadRequest)
+	}
+
+	page, limit, err := parsePaginationParams(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	results, err := h.service.SearchJokes(r.Context(), query, page, limit)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(results)
+}
+
+// GetRandomJokes returns a random joke, or a list of count distinct jokes when count is set.
+func (h *handlerImpl) GetRandomJokes(w http.ResponseWriter, r *http.Request) error {
+	count, exclude, err := parseRandomParams(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	filter, err := parseFilterParams(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	jokes, err := h.service.GetRandomJokes(r.Context(), filter, count, exclude)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidID) {
+			return NewErrorStatus(errors.New("invalid id in exclude"), http.StatusBadRequest)
+		}
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Has("count") {
+		w.WriteHeader(http.StatusOK)
+		return json.NewEncoder(w).Encode(jokes)
+	}
+
+	if len(jokes) == 0 {
+		return NewErrorStatus(errors.New("no jokes found"), http.StatusNotFound)
+	}
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(jokes[0])
+}
+
+const maxRandomCount = 50
+
+// parseRandomParams reads count (default: 1) and the IDs to exclude, which can be
+// given as a comma separated list, repeated exclude params or both.
+func parseRandomParams(r *http.Request) (int, []string, error) {
+	query := r.URL.Query()
+
+	c := 1
+	if count := query.Get("count"); count != "" {
+		var err error
+		c, err = strconv.Atoi(count)
+		if err != nil || c < 1 || c > maxRandomCount {
+			return 0, nil, fmt.Errorf("invalid count, must be between 1 and %d", maxRandomCount)
+		}
+	}
+
+	return c, queryList(query, "exclude"), nil
+}
+
+// parseFilterParams reads the type, category and tag filters. Several categories match jokes in any
+// of them, several tags match jokes with all of them unless tag_mode=any.
+func parseFilterParams(r *http.Request) (models.JokeFilter, error) {
+	query := r.URL.Query()
+	filter := models.JokeFilter{
+		Type:         query.Get("type"),
+		Categories:   queryList(query, "category"),
+		Tags:         queryList(query, "tag"),
+		MatchAllTags: true,
+	}
+
+	if filter.Type != "" && filter.Type != models.TypeSingle && filter.Type != models.TypeTwoPart {
+		return filter, errors.New("invalid type, must be single or twopart")
+	}
+	for _, category := range filter.Categories {
+		if !models.IsCategory(category) {
+			return filter, fmt.Errorf("unknown category %q", category)
+		}
+	}
+	for _, tag := range filter.Tags {
+		if !models.IsTag(tag) {
+			return filter, fmt.Errorf("unknown tag %q", tag)
+		}
+	}
+
+	switch query.Get("tag_mode") {
+	case "", "all":
+	case "any":
+		filter.MatchAllTags = false
+	default:
+		return filter, errors.New("invalid tag_mode, must be all or any")
+	}
+
+	return filter, nil
+}
+
+// sortParams are the values sort accepts, a leading - sorts in descending order.
+var sortParams = []string{"created_at", "-created_at", "updated_at", "-updated_at", "rating", "-rating"}
+
+// parseSortParam reads the order jokes are listed in, by ID unless sort is set.
+func parseSortParam(r *http.Request) (models.JokeSort, error) {
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "":
+		return models.JokeSort{}, nil
+	default:
+		if !slices.Contains(sortParams, sort) {
+			return models.JokeSort{}, fmt.Errorf("invalid sort, must be one of %s", strings.Join(sortParams, ", "))
+		}
+		field, descending := strings.CutPrefix(sort, "-")
+		return models.JokeSort{Field: field, Descending: descending}, nil
+	}
+}
+
+// parseDateRangeParams reads created_after, created_before and updated_since into filter.
+// They take RFC 3339 timestamps or YYYY-MM-DD dates, which mean midnight UTC.
+func parseDateRangeParams(r *http.Request, filter *models.JokeFilter) error {
+	params := []struct {
+		key string
+		dst *time.Time
+	}{
+		{key: "created_after", dst: &filter.CreatedAfter},
+		{key: "created_before", dst: &filter.CreatedBefore},
+		{key: "updated_since", dst: &filter.UpdatedSince},
+	}
+
+	for _, param := range params {
+		value := r.URL.Query().Get(param.key)
+		if value == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s, expected an RFC 3339 timestamp or YYYY-MM-DD", param.key)
+		}
+		*param.dst = t
+	}
+
+	if !filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero() && !filter.CreatedAfter.Before(filter.CreatedBefore) {
+		return errors.New("created_after must be before created_before")
+	}
+	return nil
+}
+
+// queryList collects the values of key, given as a comma separated list, repeated params or both.
+func queryList(query url.Values, key string) []string {
+	var list []string
+	for _, value := range query[key] {
+		list = append(list, splitList(value)...)
+	}
+	return list
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// newJoke turns a validated request into a joke ready to be stored.
+func newJoke(req models.JokeRequest) models.Jusgo {
+	now := time.Now()
+	return models.Jusgo{
+		Type:      typeOrDefault(req.Type),
+		Joke:      req.Joke,
+		Setup:     req.Setup,
+		Delivery:  req.Delivery,
+		Category:  categoryOrDefault(req.Category),
+		Tags:      uniqueTags(req.Tags),
+		UpdatedAt: now,
+		CreatedAt: now,
+	}
+}
+
+func typeOrDefault(jokeType string) string {
+	if jokeType == "" {
+		return models.TypeSingle
+	}
+	return jokeType
+}
+
+func categoryOrDefault(category string) string {
+	if category == "" {
+		return models.DefaultCategory
+	}
+	return category
+}
+
+// uniqueTags sorts tags and drops repeated ones.
+func uniqueTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// GetDailyJoke returns the joke of the day for date (default: today) in the time zone tz (default: UTC).
+func (h *handlerImpl) GetDailyJoke(w http.ResponseWriter, r *http.Request) error {
+	date, err := parseDailyParams(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	joke, err := h.service.GetDailyJoke(r.Context(), date)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewErrorStatus(errors.New("no jokes found"), http.StatusNotFound)
+		}
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(joke)
+}
+
+func parseDailyParams(r *http.Request) (time.Time, error) {
+	tz := r.URL.Query().Get("tz")
+	date := r.URL.Query().Get("date")
+
+	loc := time.UTC
+	if tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time zone %q", tz)
+		}
+	}
+
+	if date == "" {
+		return time.Now().In(loc), nil
+	}
+
+	d, err := time.ParseInLocation(time.DateOnly, date, loc)
+	if err != nil {
+		return time.Time{}, errors.New("invalid date, expected YYYY-MM-DD")
+	}
+	return d, nil
+}
+
+// UpdateJoke replaces a joke with the one in the body, its ID, creation time and votes are kept.
 func (h *handlerImpl) UpdateJoke(w http.ResponseWriter, r *http.Request) error {
 	id := r.PathValue("id")
 	if id == "" {
@@ -168,26 +551,120 @@ func (h *handlerImpl) UpdateJoke(w http.ResponseWriter, r *http.Request) error {
 	if err := h.validate.Struct(&req); err != nil {
 		return NewErrorStatus(err, http.StatusBadRequest)
 	}
+	force, err := parseForceParam(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
 
-	objectID, err := primitive.ObjectIDFromHex(id) // convert id to mongodb primitive ID
+	current, err := h.service.GetJoke(r.Context(), id)
+	if err != nil {
+		return jokeError(err)
+	}
+	version, err := h.ifMatchVersion(r, current)
 	if err != nil {
-		return NewErrorStatus(errors.New("failed to create object id"), http.StatusInternalServerError)
+		return err
 	}
 
 	updatedJoke, err := h.service.UpdateJoke(r.Context(), models.Jusgo{
-		ID:        objectID,
+		ID:        current.ID,
+		Type:      typeOrDefault(req.Type),
 		Joke:      req.Joke,
+		Setup:     req.Setup,
+		Delivery:  req.Delivery,
+		Category:  categoryOrDefault(req.Category),
+		Tags:      uniqueTags(req.Tags),
 		UpdatedAt: time.Now(),
-	})
+	}, version, force)
 	if err != nil {
-		return NewErrorStatus(err, http.StatusInternalServerError)
+		var dup *service.DuplicateError
+		if errors.As(err, &dup) {
+			return NewErrorStatusWithID(err, http.StatusConflict, dup.ID)
+		}
+		return jokeError(err)
 	}
 
+	setETag(w, updatedJoke)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	return json.NewEncoder(w).Encode(updatedJoke)
 }
 
+// PatchJoke changes the fields of a joke in the JSON merge patch in the body and leaves the others alone.
+func (h *handlerImpl) PatchJoke(w http.ResponseWriter, r *http.Request) error {
+	if err := checkPatchType(w, r); err != nil {
+		return err
+	}
+
+	var patch models.JokePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		if err == io.EOF {
+			return NewErrorStatus(errors.New("request body must not be empty"), http.StatusBadRequest)
+		}
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+	force, err := parseForceParam(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	current, err := h.service.GetJoke(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return jokeError(err)
+	}
+	version, err := h.ifMatchVersion(r, current)
+	if err != nil {
+		return err
+	}
+
+	// the patch is applied to the joke as it is stored when the update happens, which may be newer than current
+	patchedJoke, err := h.service.PatchJoke(r.Context(), current.ID, func(joke *models.Jusgo) error {
+		patch.Apply(joke)
+		req := joke.Request()
+		if err := h.validate.Struct(&req); err != nil {
+			return NewErrorStatus(err, http.StatusBadRequest)
+		}
+		joke.Type = typeOrDefault(joke.Type)
+		joke.Category = categoryOrDefault(joke.Category)
+		joke.Tags = uniqueTags(joke.Tags)
+		return nil
+	}, version, force)
+	if err != nil {
+		var status ErrorStatus
+		var dup *service.DuplicateError
+		switch {
+		case errors.As(err, &status):
+			return err
+		case errors.As(err, &dup):
+			return NewErrorStatusWithID(err, http.StatusConflict, dup.ID)
+		}
+		return jokeError(err)
+	}
+
+	setETag(w, patchedJoke)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(patchedJoke)
+}
+
+// mergePatchType is the media type of a JSON merge patch, the kind of patch PATCH /jokes/{id} takes.
+const mergePatchType = "application/merge-patch+json"
+
+// checkPatchType rejects patches of a type other than mergePatchType. Plain JSON is taken as a merge patch too,
+// a JSON object means the same either way, and so is a request without a type.
+func checkPatchType(w http.ResponseWriter, r *http.Request) error {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == mergePatchType || mediaType == "application/json") {
+		return nil
+	}
+
+	w.Header().Set("Accept-Patch", mergePatchType)
+	return NewErrorStatus(fmt.Errorf("unsupported patch type %q, use %s", contentType, mergePatchType), http.StatusUnsupportedMediaType)
+}
+
 func (h *handlerImpl) DeleteJoke(w http.ResponseWriter, r *http.Request) error {
 	id := r.PathValue("id")
 	if id == "" {
@@ -195,17 +672,33 @@ func (h *handlerImpl) DeleteJoke(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	// check if joke exists first
-	if _, err := h.service.GetJoke(r.Context(), id); err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return NewErrorStatus(err, http.StatusNotFound)
-		}
-		return NewErrorStatus(err, http.StatusInternalServerError)
+	current, err := h.service.GetJoke(r.Context(), id)
+	if err != nil {
+		return jokeError(err)
+	}
+	version, err := h.ifMatchVersion(r, current)
+	if err != nil {
+		return err
 	}
 
-	if err := h.service.DeleteJoke(r.Context(), id); err != nil {
-		return NewErrorStatus(err, http.StatusInternalServerError)
+	if err := h.service.DeleteJoke(r.Context(), id, version); err != nil {
+		return jokeError(err)
 	}
 
 	w.WriteHeader(http.StatusOK)
 	return nil
 }
+
+// jokeError maps the errors of reading or writing a single joke to a status.
+func jokeError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return NewErrorStatus(err, http.StatusNotFound)
+	case errors.Is(err, repository.ErrInvalidID):
+		return NewErrorStatus(err, http.StatusBadRequest)
+	case errors.Is(err, repository.ErrVersionConflict):
+		return NewErrorStatus(err, http.StatusPreconditionFailed)
+	default:
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/service"
+)
+
+// GetJokeHistory lists the revisions of a joke, oldest first.
+func (h *handlerImpl) GetJokeHistory(w http.ResponseWriter, r *http.Request) error {
+	page, limit, err := parsePaginationParams(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	id := r.PathValue("id")
+	revisions, total, err := h.service.GetJokeHistory(r.Context(), id, page, limit)
+	if err != nil {
+		return historyError(err)
+	}
+	if total == 0 {
+		// jokes stored before revisions were recorded have none, jokes that don't exist are a 404
+		if _, err := h.service.GetJoke(r.Context(), id); err != nil {
+			return historyError(err)
+		}
+	}
+
+	resp := newPageResponse(r, revisions, page, limit, total)
+	setLinkHeader(w, r, resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// RevertJoke puts a joke back to how one of its revisions left it, recording the revert as a new revision.
+func (h *handlerImpl) RevertJoke(w http.ResponseWriter, r *http.Request) error {
+	rev, err := strconv.ParseInt(r.PathValue("rev"), 10, 64)
+	if err != nil || rev < 1 {
+		return NewErrorStatus(errors.New("invalid revision, must be a positive number"), http.StatusBadRequest)
+	}
+
+	force, err := parseForceParam(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	joke, err := h.service.RevertJoke(r.Context(), r.PathValue("id"), rev, force)
+	if err != nil {
+		return historyError(err)
+	}
+
+	setETag(w, joke)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(joke)
+}
+
+// historyError maps the errors of the history service methods to a status.
+func historyError(err error) error {
+	var dup *service.DuplicateError
+	switch {
+	case errors.As(err, &dup):
+		return NewErrorStatusWithID(err, http.StatusConflict, dup.ID)
+	case errors.Is(err, service.ErrNothingToRevert):
+		return NewErrorStatus(err, http.StatusConflict)
+	case errors.Is(err, repository.ErrNotFound):
+		return NewErrorStatus(errors.New("joke or revision not found"), http.StatusNotFound)
+	case errors.Is(err, repository.ErrInvalidID):
+		return NewErrorStatus(err, http.StatusBadRequest)
+	default:
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/zde37/Jusgo/internal/jwt"
+	"github.com/zde37/Jusgo/internal/models"
+)
+
+// JWTAuth lets the admin endpoints take JWTs from an identity provider, next to API keys.
+// The roles of a token decide its scopes.
+type JWTAuth struct {
+	Verifier   *jwt.Verifier
+	RolesClaim string              // the claim with the roles, like "roles" or "realm_access.roles"
+	Roles      map[string][]string // the scopes of each role, see DefaultRoles
+}
+
+// DefaultRoles returns the roles every JWTAuth knows: one named after each scope, which grants it,
+// and "admin", which grants every scope.
+func DefaultRoles() map[string][]string {
+	roles := map[string][]string{"admin": models.Scopes}
+	for _, scope := range models.Scopes {
+		roles[scope] = []string{scope}
+	}
+	return roles
+}
+
+// isJWT reports whether token looks like a JWT rather than an API key, which has no dots.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// authenticateJWT verifies token and returns its subject, with a key that has the scopes of its roles.
+func (a *JWTAuth) authenticateJWT(ctx context.Context, token string) (identity, error) {
+	claims, err := a.Verifier.Verify(ctx, token)
+	if err != nil {
+		return identity{}, err
+	}
+
+	var scopes []string
+	for _, role := range claims.Strings(a.RolesClaim) {
+		scopes = append(scopes, a.Roles[role]...)
+	}
+	slices.Sort(scopes)
+	return identity{
+		key:    models.APIKey{Name: claims.Subject, Scopes: slices.Compact(scopes), ExpiresAt: &claims.ExpiresAt},
+		actor:  fmt.Sprintf("%s (%s)", claims.Subject, claims.Issuer),
+		client: "sub:" + claims.Subject,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/audit"
+	"github.com/zde37/Jusgo/internal/jwt"
+	"github.com/zde37/Jusgo/internal/models"
+)
+
+// signHS256 returns a token with claims signed with secret.
+func signHS256(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestRequireScopeJWT(t *testing.T) {
+	const secret, issuer = "jwt secret", "https://issuer.example.com"
+	roles := DefaultRoles()
+	roles["moderator"] = []string{models.ScopeSubmissionsModerate}
+	h := &handlerImpl{jwt: &JWTAuth{
+		Verifier:   jwt.NewVerifier(issuer, "jusgo", jwt.HMACKey(secret)),
+		RolesClaim: "realm_access.roles",
+		Roles:      roles,
+	}}
+
+	token := func(roles ...string) string {
+		return signHS256(t, secret, map[string]any{
+			"iss":          issuer,
+			"aud":          "jusgo",
+			"sub":          "alice",
+			"exp":          time.Now().Add(time.Hour).Unix(),
+			"realm_access": map[string]any{"roles": roles},
+		})
+	}
+	expired := signHS256(t, secret, map[string]any{"iss": issuer, "aud": "jusgo", "sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()})
+
+	testData := []struct {
+		name   string
+		token  string
+		scope  string
+		status int
+	}{
+		{name: "role", token: token("moderator"), scope: models.ScopeSubmissionsModerate, status: http.StatusOK},
+		{name: "role named after the scope", token: token(models.ScopeJokesWrite), scope: models.ScopeJokesWrite, status: http.StatusOK},
+		{name: "admin", token: token("admin"), scope: models.ScopeKeysManage, status: http.StatusOK},
+		{name: "other role", token: token("moderator", "unknown"), scope: models.ScopeJokesWrite, status: http.StatusForbidden},
+		{name: "no roles", token: token(), scope: models.ScopeJokesWrite, status: http.StatusForbidden},
+		{name: "expired", token: expired, scope: models.ScopeJokesWrite, status: http.StatusUnauthorized},
+		{name: "other secret", token: signHS256(t, "other", map[string]any{"iss": issuer}), scope: models.ScopeJokesWrite, status: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			var actor string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key, ok := apiKeyFrom(r.Context())
+				require.True(t, ok)
+				require.Equal(t, "alice", key.Name)
+				actor = audit.Actor(r.Context())
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/keys", nil)
+			r.Header.Set("Authorization", "Bearer "+tc.token)
+			w := httptest.NewRecorder()
+			h.requireScope(tc.scope, next).ServeHTTP(w, r)
+
+			require.Equal(t, tc.status, w.Code)
+			if tc.status == http.StatusOK {
+				require.Equal(t, "alice ("+issuer+")", actor)
+			}
+		})
+	}
+}
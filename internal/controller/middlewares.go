@@ -3,15 +3,19 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
+	"github.com/zde37/Jusgo/internal/audit"
+	"github.com/zde37/Jusgo/internal/jwt"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/service"
 )
 
 const (
@@ -19,12 +23,19 @@ const (
 	authorizationTypeBearer = "bearer"
 )
 
+const requestIDHeaderKey = "X-Request-ID"
+
+// maxRequestIDLength caps the request IDs clients can pick, longer ones are replaced.
+const maxRequestIDLength = 64
+
 func middleware(f func(http.ResponseWriter, *http.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer cancel()
 
-		r = r.WithContext(ctx)
+		requestID := requestIDFrom(r)
+		w.Header().Set(requestIDHeaderKey, requestID)
+		r = r.WithContext(audit.WithRequestID(ctx, requestID))
 
 		startTime := time.Now()
 		if err := f(w, r); err != nil {
@@ -45,39 +56,110 @@ func middleware(f func(http.ResponseWriter, *http.Request) error) http.HandlerFu
 	}
 }
 
-func ensureAdmin(next http.Handler) http.Handler {
+// requireScope lets a request through to next if its bearer token is an active API key with scope or a JWT
+// with a role that grants scope. The key, or the one standing in for the JWT, is put in the context of the
+// request, see apiKeyFrom.
+func (h *handlerImpl) requireScope(scope string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authorizationHeader := r.Header.Get(authorizationHeaderKey)
-		if len(authorizationHeader) == 0 {
-			err := fmt.Errorf("authorization header is not provided")
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		token, err := bearerToken(r)
+		if err != nil {
+			unauthorized(w, err)
 			return
 		}
 
-		fields := strings.Fields(authorizationHeader)
-		if len(fields) < 2 {
-			err := fmt.Errorf("invalid authorization header format")
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		id, err := h.authenticate(r.Context(), token)
+		if errors.Is(err, service.ErrInvalidAPIKey) || errors.Is(err, jwt.ErrInvalidToken) {
+			unauthorized(w, err)
 			return
 		}
-
-		authorizationType := strings.ToLower(fields[0])
-		if authorizationType != authorizationTypeBearer {
-			err := fmt.Errorf("unsupported authorization type %s", authorizationType)
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		if err != nil {
+			log.Printf("failed to authenticate: %v", err)
+			http.Error(w, "unable to authenticate", http.StatusInternalServerError)
 			return
 		}
-
-		accessToken := fields[1]
-		if accessToken != os.Getenv("TOKEN") {
-			err := fmt.Errorf("invalid token")
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+		if !id.key.HasScope(scope) {
+			err := fmt.Errorf("missing the %s scope", scope)
+			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
-		next.ServeHTTP(w, r)
+
+		ctx := audit.WithActor(withAPIKey(r.Context(), id.key), id.actor)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// bearerToken returns the token of the bearer authorization header of r.
+func bearerToken(r *http.Request) (string, error) {
+	authorizationHeader := r.Header.Get(authorizationHeaderKey)
+	if len(authorizationHeader) == 0 {
+		return "", fmt.Errorf("authorization header is not provided")
+	}
+
+	fields := strings.Fields(authorizationHeader)
+	if len(fields) < 2 {
+		return "", fmt.Errorf("invalid authorization header format")
+	}
+
+	authorizationType := strings.ToLower(fields[0])
+	if authorizationType != authorizationTypeBearer {
+		return "", fmt.Errorf("unsupported authorization type %s", authorizationType)
+	}
+	return fields[1], nil
+}
+
+// identity is who a bearer token belongs to.
+type identity struct {
+	key    models.APIKey // what the token may do, stands in for JWTs, which aren't stored keys
+	actor  string        // the actor revisions record
+	client string        // the client votes are counted for, see clientID
+}
+
+// authenticate returns who token belongs to. API keys are looked up by their hash, so how long the
+// lookup takes tells nothing about how much of a key a guess got right.
+func (h *handlerImpl) authenticate(ctx context.Context, token string) (identity, error) {
+	if h.jwt != nil && isJWT(token) {
+		return h.jwt.authenticateJWT(ctx, token)
+	}
+	key, err := h.service.AuthenticateAPIKey(ctx, token)
+	if err != nil {
+		return identity{}, err
+	}
+	return identity{key: key, actor: fmt.Sprintf("%s (%s)", key.Name, key.Prefix), client: "key:" + key.ID}, nil
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// apiKeyKey is the context key of the API key a request was authenticated with.
+type apiKeyKey struct{}
+
+func withAPIKey(ctx context.Context, key models.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// apiKeyFrom returns the API key requireScope authenticated the request with ctx with.
+func apiKeyFrom(ctx context.Context) (models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey{}).(models.APIKey)
+	return key, ok
+}
+
+// requestIDFrom returns the X-Request-ID the client sent, so its logs and our revisions can be
+// matched up, or a new ID if it didn't send a usable one.
+func requestIDFrom(r *http.Request) string {
+	id := r.Header.Get(requestIDHeaderKey)
+	if id == "" || len(id) > maxRequestIDLength {
+		return audit.NewRequestID()
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return audit.NewRequestID()
+		}
+	}
+	return id
+}
+
 func limitMiddleware(rl *rateLimiter, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ip, _, err := net.SplitHostPort(r.RemoteAddr)
@@ -86,8 +168,7 @@ func limitMiddleware(rl *rateLimiter, next http.Handler) http.Handler {
 			return
 		}
 
-		limiter := rl.getClient(ip)
-		if !limiter.Allow() {
+		if !rl.allow(ip) {
 			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
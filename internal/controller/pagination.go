@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zde37/Jusgo/internal/models"
+)
+
+const (
+	defaultPageLimit = 10
+	maxPageLimit     = 100
+)
+
+// pageResponse is the envelope GET /jokes and GET /submissions wrap a page of T in.
+type pageResponse[T any] struct {
+	Data       []T     `json:"data"`
+	Page       int     `json:"page"`
+	Limit      int     `json:"limit"`
+	Total      int64   `json:"total"`
+	TotalPages int64   `json:"total_pages"`
+	Next       *string `json:"next"`
+	Prev       *string `json:"prev"`
+}
+
+// cursorPageResponse is the envelope GET /jokes uses in cursor mode. There is no total since
+// counting is what cursor mode avoids, next_cursor is null on the last page.
+type cursorPageResponse struct {
+	Data       []models.Jusgo `json:"data"`
+	Limit      int            `json:"limit"`
+	NextCursor *string        `json:"next_cursor"`
+	Next       *string        `json:"next"`
+}
+
+// newPageResponse builds the envelope for page of items, pointing next and prev at the
+// neighbouring pages of the request r when they exist.
+func newPageResponse[T any](r *http.Request, items []T, page, limit int, total int64) pageResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+
+	totalPages := (total + int64(limit) - 1) / int64(limit)
+	resp := pageResponse[T]{
+		Data:       items,
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+
+	if int64(page) < totalPages {
+		next := pageURL(r, page+1)
+		resp.Next = &next
+	}
+	if page > 1 {
+		// a page past the end points back at the last one rather than at another empty page
+		prev := pageURL(r, int(min(int64(page-1), max(totalPages, 1))))
+		resp.Prev = &prev
+	}
+	return resp
+}
+
+// setLinkHeader sets the RFC 8288 Link header for resp, with first and last always present.
+func setLinkHeader[T any](w http.ResponseWriter, r *http.Request, resp pageResponse[T]) {
+	links := []string{
+		fmt.Sprintf(`<%s>; rel="first"`, pageURL(r, 1)),
+	}
+	if resp.Prev != nil {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, *resp.Prev))
+	}
+	if resp.Next != nil {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, *resp.Next))
+	}
+	links = append(links, fmt.Sprintf(`<%s>; rel="last"`, pageURL(r, int(max(resp.TotalPages, 1)))))
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// pageURL returns the URL of the request r with its page param set to page.
+func pageURL(r *http.Request, page int) string {
+	return listURL(r, "page", strconv.Itoa(page))
+}
+
+// listURL returns the URL of the request r with the query param key set to value. It is built from
+// the original request URI so the /v1 prefix stripped by the router is kept.
+func listURL(r *http.Request, key, value string) string {
+	path := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		path = u.Path
+	}
+
+	query := r.URL.Query()
+	query.Set(key, value)
+	return (&url.URL{Path: path, RawQuery: query.Encode()}).String()
+}
+
+// cursorKey returns the key cursors are signed with. Without CURSOR_SECRET a random key is used,
+// which is fine for a single instance but invalidates every cursor on restart.
+func cursorKey() []byte {
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return key
+}
@@ -12,44 +12,73 @@ type client struct {
 	lastSeen time.Time
 }
 
+// minIdleTTL is how long clients are kept at least after their last request.
+const minIdleTTL = 3 * time.Minute
+
 type rateLimiter struct {
 	clients map[string]*client
 	mu      sync.Mutex
+	limit   rate.Limit
+	burst   int
+	idleTTL time.Duration    // how long a client is kept after its last request
+	now     func() time.Time // for tests
 }
 
-func newRateLimiter() *rateLimiter {
+// newRateLimiter allows every client limit requests per second, with bursts of up to burst requests.
+func newRateLimiter(limit rate.Limit, burst int) *rateLimiter {
 	rl := &rateLimiter{
 		clients: make(map[string]*client),
+		limit:   limit,
+		burst:   burst,
+		idleTTL: idleTTL(limit, burst),
+		now:     time.Now,
 	}
 
 	go rl.cleanupClients()
 	return rl
 }
 
-func (rl *rateLimiter) getClient(ip string) *rate.Limiter {
+// idleTTL returns how long a client with an empty bucket takes to get burst requests again, but at least
+// minIdleTTL. Forgetting a client sooner would hand it a full bucket before it earned one.
+func idleTTL(limit rate.Limit, burst int) time.Duration {
+	if limit == rate.Inf || limit <= 0 {
+		return minIdleTTL
+	}
+	refill := time.Duration(float64(burst) / float64(limit) * float64(time.Second)).Round(time.Second)
+	return max(refill, minIdleTTL)
+}
+
+// allow reports whether the client with ip may make a request now, and counts it if so.
+func (rl *rateLimiter) allow(ip string) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	if c, exists := rl.clients[ip]; exists {
-		c.lastSeen = time.Now()
-		return c.limiter
+	now := rl.now()
+	c, exists := rl.clients[ip]
+	if !exists {
+		c = &client{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.clients[ip] = c
 	}
-
-	limiter := rate.NewLimiter(rate.Limit(1), 5) // 1 request per second, burst of 5
-	rl.clients[ip] = &client{limiter, time.Now()}
-	return limiter
+	c.lastSeen = now
+	return c.limiter.AllowN(now, 1)
 }
 
 func (rl *rateLimiter) cleanupClients() {
 	for {
 		time.Sleep(time.Minute)
+		rl.evictIdle()
+	}
+}
+
+// evictIdle forgets the clients that made no request for idleTTL, their buckets are full again anyway.
+func (rl *rateLimiter) evictIdle() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
 
-		rl.mu.Lock()
-		for ip, c := range rl.clients {
-			if time.Since(c.lastSeen) > 3*time.Minute {
-				delete(rl.clients, ip)
-			}
+	now := rl.now()
+	for ip, c := range rl.clients {
+		if now.Sub(c.lastSeen) > rl.idleTTL {
+			delete(rl.clients, ip)
 		}
-		rl.mu.Unlock()
 	}
 }
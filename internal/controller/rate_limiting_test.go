@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterEviction(t *testing.T) {
+	testData := []struct {
+		name  string
+		idle  time.Duration
+		allow int // requests allowed after idling
+	}{
+		{name: "3 minutes", idle: 3 * time.Minute},
+		{name: "6 minutes", idle: 6 * time.Minute},
+		{name: "9 minutes", idle: 9 * time.Minute},
+		// a token came back, the rest are still missing
+		{name: "11 minutes", idle: 11 * time.Minute, allow: 1},
+		// the client was forgotten, its bucket had refilled anyway
+		{name: "an hour", idle: time.Hour, allow: 3},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Now()
+			rl := &rateLimiter{
+				clients: make(map[string]*client),
+				limit:   rate.Every(10 * time.Minute),
+				burst:   3,
+				idleTTL: idleTTL(rate.Every(10*time.Minute), 3),
+				now:     func() time.Time { return now },
+			}
+
+			for range 3 {
+				require.True(t, rl.allow("1.2.3.4"))
+			}
+			require.False(t, rl.allow("1.2.3.4"))
+
+			now = now.Add(tc.idle)
+			rl.evictIdle()
+			for range tc.allow {
+				require.True(t, rl.allow("1.2.3.4"))
+			}
+			require.False(t, rl.allow("1.2.3.4"))
+		})
+	}
+}
+
+func TestIdleTTL(t *testing.T) {
+	require.Equal(t, 30*time.Minute, idleTTL(rate.Every(10*time.Minute), 3))
+	require.Equal(t, minIdleTTL, idleTTL(rate.Limit(1), 5))
+	require.Equal(t, minIdleTTL, idleTTL(rate.Inf, 1))
+}
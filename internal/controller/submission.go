@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/service"
+)
+
+// maxSubmissionBytes caps the body of a submission, anyone can send one.
+const maxSubmissionBytes = 64 << 10
+
+// rejectRequest is the body of POST /submissions/{id}/reject.
+type rejectRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// SubmitJoke queues a joke from anyone for an admin to approve or reject.
+func (h *handlerImpl) SubmitJoke(w http.ResponseWriter, r *http.Request) error {
+	var req models.JokeRequest
+	if err := decodeBody(w, r, maxSubmissionBytes, &req); err != nil {
+		return err
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	submission, err := h.service.SubmitJoke(r.Context(), newSubmission(req))
+	if err != nil {
+		return submissionError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(submission)
+}
+
+// GetSubmissions lists submissions oldest first, by default the pending ones. status=all lists every submission.
+func (h *handlerImpl) GetSubmissions(w http.ResponseWriter, r *http.Request) error {
+	page, limit, err := parsePaginationParams(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.SubmissionPending
+	case "all":
+		status = ""
+	case models.SubmissionPending, models.SubmissionApproved, models.SubmissionRejected:
+	default:
+		return NewErrorStatus(errors.New("invalid status, must be pending, approved, rejected or all"), http.StatusBadRequest)
+	}
+
+	submissions, total, err := h.service.GetSubmissions(r.Context(), status, page, limit)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	resp := newPageResponse(r, submissions, page, limit, total)
+	setLinkHeader(w, r, resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(resp)
+}
+
+func (h *handlerImpl) GetSubmission(w http.ResponseWriter, r *http.Request) error {
+	submission, err := h.service.GetSubmission(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return submissionError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(submission)
+}
+
+// UpdateSubmission replaces the joke of a pending submission, to fix it up before approving it.
+func (h *handlerImpl) UpdateSubmission(w http.ResponseWriter, r *http.Request) error {
+	var req models.JokeRequest
+	if err := decodeBody(w, r, maxSubmissionBytes, &req); err != nil {
+		return err
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	submission, err := h.service.UpdateSubmission(r.Context(), r.PathValue("id"), newSubmission(req))
+	if err != nil {
+		return submissionError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(submission)
+}
+
+// ApproveSubmission stores a pending submission as a joke. Like POST /jokes it takes force=true
+// to store a joke that is similar to an existing one.
+func (h *handlerImpl) ApproveSubmission(w http.ResponseWriter, r *http.Request) error {
+	force, err := parseForceParam(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	joke, err := h.service.ApproveSubmission(r.Context(), r.PathValue("id"), force)
+	if err != nil {
+		return submissionError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(joke)
+}
+
+func (h *handlerImpl) RejectSubmission(w http.ResponseWriter, r *http.Request) error {
+	var req rejectRequest
+	if err := decodeBody(w, r, maxSubmissionBytes, &req); err != nil {
+		return err
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	submission, err := h.service.RejectSubmission(r.Context(), r.PathValue("id"), req.Reason)
+	if err != nil {
+		return submissionError(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(submission)
+}
+
+// newSubmission is newJoke for a submission.
+func newSubmission(req models.JokeRequest) models.Submission {
+	now := time.Now()
+	return models.Submission{
+		Type:      typeOrDefault(req.Type),
+		Joke:      req.Joke,
+		Setup:     req.Setup,
+		Delivery:  req.Delivery,
+		Category:  categoryOrDefault(req.Category),
+		Tags:      uniqueTags(req.Tags),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// decodeBody decodes the JSON body of r, which may be at most limit bytes, into v.
+func decodeBody(w http.ResponseWriter, r *http.Request, limit int64, v any) error {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(v)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case err == nil:
+		return nil
+	case err == io.EOF:
+		return NewErrorStatus(errors.New("request body must not be empty"), http.StatusBadRequest)
+	case errors.As(err, &maxBytesErr):
+		return NewErrorStatus(errors.New("request body is too large"), http.StatusRequestEntityTooLarge)
+	default:
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+}
+
+// parseForceParam returns the force param, false if it is missing.
+func parseForceParam(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get("force")
+	if value == "" {
+		return false, nil
+	}
+	force, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("invalid force, must be true or false")
+	}
+	return force, nil
+}
+
+// submissionError maps the errors of the submission service methods to a status.
+func submissionError(err error) error {
+	var dup *service.DuplicateError
+	switch {
+	case errors.As(err, &dup):
+		return NewErrorStatusWithID(err, http.StatusConflict, dup.ID)
+	case errors.Is(err, service.ErrNotPending):
+		return NewErrorStatus(err, http.StatusConflict)
+	case errors.Is(err, repository.ErrNotFound):
+		return NewErrorStatus(errors.New("submission not found"), http.StatusNotFound)
+	case errors.Is(err, repository.ErrInvalidID):
+		return NewErrorStatus(err, http.StatusBadRequest)
+	default:
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/service"
+)
+
+// GetTrash lists the deleted jokes, the most recently deleted first.
+func (h *handlerImpl) GetTrash(w http.ResponseWriter, r *http.Request) error {
+	page, limit, err := parsePaginationParams(r)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	query := models.JokeQuery{
+		Filter: models.JokeFilter{Deleted: true},
+		Sort:   models.JokeSort{Field: models.SortDeletedAt, Descending: true},
+		Page:   page,
+		Limit:  limit,
+	}
+	jokes, total, err := h.service.GetAllJokes(r.Context(), query)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	resp := newPageResponse(r, jokes, page, limit, total)
+	setLinkHeader(w, r, resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// RestoreJoke takes a joke out of the trash.
+func (h *handlerImpl) RestoreJoke(w http.ResponseWriter, r *http.Request) error {
+	joke, err := h.service.RestoreJoke(r.Context(), r.PathValue("id"))
+	if err != nil {
+		return trashError(err)
+	}
+
+	setETag(w, joke)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(joke)
+}
+
+// PurgeJoke permanently removes a joke from the trash. Live jokes have to be deleted first.
+func (h *handlerImpl) PurgeJoke(w http.ResponseWriter, r *http.Request) error {
+	if err := h.service.PurgeJoke(r.Context(), r.PathValue("id")); err != nil {
+		return trashError(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// trashError maps the errors of the trash service methods to a status.
+func trashError(err error) error {
+	var dup *service.DuplicateError
+	switch {
+	case errors.As(err, &dup):
+		return NewErrorStatusWithID(err, http.StatusConflict, dup.ID)
+	case errors.Is(err, repository.ErrNotFound):
+		return NewErrorStatus(errors.New("joke not found in the trash"), http.StatusNotFound)
+	case errors.Is(err, repository.ErrInvalidID):
+		return NewErrorStatus(err, http.StatusBadRequest)
+	default:
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+}
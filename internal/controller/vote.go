@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/jwt"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/service"
+)
+
+// topPeriods are the periods GET /jokes/top ranks jokes over, all counts every vote ever cast.
+var topPeriods = map[string]time.Duration{
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+	"all":  0,
+}
+
+type voteRequest struct {
+	Vote string `json:"vote" validate:"required,oneof=up down"`
+}
+
+// Vote counts an up or down vote on a joke, once per client and joke a day.
+func (h *handlerImpl) Vote(w http.ResponseWriter, r *http.Request) error {
+	var req voteRequest
+	if err := decodeBody(w, r, 1<<10, &req); err != nil {
+		return err
+	}
+	if err := h.validate.Struct(req); err != nil {
+		return NewErrorStatus(err, http.StatusBadRequest)
+	}
+
+	client, err := h.clientID(r)
+	if err != nil {
+		return err
+	}
+
+	joke, err := h.service.Vote(r.Context(), r.PathValue("id"), client, req.Vote == "up")
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAlreadyVoted):
+			return NewErrorStatus(err, http.StatusConflict)
+		case errors.Is(err, repository.ErrNotFound):
+			return NewErrorStatus(err, http.StatusNotFound)
+		case errors.Is(err, repository.ErrInvalidID):
+			return NewErrorStatus(err, http.StatusBadRequest)
+		}
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(joke)
+}
+
+// GetTopJokes lists the best rated jokes of the period param (day, week or all, default: all)
+// by the Wilson score of their votes in that period.
+func (h *handlerImpl) GetTopJokes(w http.ResponseWriter, r *http.Request) error {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "all"
+	}
+	duration, ok := topPeriods[period]
+	if !ok {
+		return NewErrorStatus(errors.New("invalid period, must be day, week or all"), http.StatusBadRequest)
+	}
+
+	limit := defaultPageLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return NewErrorStatus(fmt.Errorf("invalid limit number, must be between 1 and %d", maxPageLimit), http.StatusBadRequest)
+		}
+	}
+
+	var since time.Time
+	if duration > 0 {
+		since = time.Now().Add(-duration)
+	}
+
+	jokes, err := h.service.GetTopJokes(r.Context(), since, limit)
+	if err != nil {
+		return NewErrorStatus(err, http.StatusInternalServerError)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(jokes)
+}
+
+// clientID identifies who sent r: the API key or the subject of the JWT it was sent with, or the IP address it
+// came from if it wasn't authenticated. Clients behind one NAT or proxy share an IP address, but not their keys.
+func (h *handlerImpl) clientID(r *http.Request) (string, error) {
+	if r.Header.Get(authorizationHeaderKey) != "" {
+		token, err := bearerToken(r)
+		if err != nil {
+			return "", NewErrorStatus(err, http.StatusUnauthorized)
+		}
+		id, err := h.authenticate(r.Context(), token)
+		if errors.Is(err, service.ErrInvalidAPIKey) || errors.Is(err, jwt.ErrInvalidToken) {
+			return "", NewErrorStatus(err, http.StatusUnauthorized)
+		}
+		if err != nil {
+			return "", NewErrorStatus(err, http.StatusInternalServerError)
+		}
+		return id.client, nil
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", NewErrorStatus(errors.New("unable to determine IP"), http.StatusInternalServerError)
+	}
+	return "ip:" + ip, nil
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/jwt"
+	mockproviders "github.com/zde37/Jusgo/internal/mock"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/service"
+	"go.uber.org/mock/gomock"
+)
+
+func TestClientID(t *testing.T) {
+	const secret, issuer = "jwt secret", "https://issuer.example.com"
+	key := models.APIKey{ID: repository.NewID(), Name: "ci", Prefix: "jusgo_abcdef"}
+	token := signHS256(t, secret, map[string]any{"iss": issuer, "aud": "jusgo", "sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+
+	testData := []struct {
+		name   string
+		header string
+		stub   func(s *mockproviders.MockServiceProvider)
+		client string
+		status int
+	}{
+		{name: "anonymous", client: "ip:192.0.2.1"},
+		{
+			name:   "api key",
+			header: "Bearer jusgo_abcdef123",
+			stub: func(s *mockproviders.MockServiceProvider) {
+				s.EXPECT().AuthenticateAPIKey(gomock.Any(), gomock.Eq("jusgo_abcdef123")).Times(1).Return(key, nil)
+			},
+			client: "key:" + key.ID,
+		},
+		{name: "jwt", header: "Bearer " + token, client: "sub:alice"},
+		{
+			name:   "invalid api key",
+			header: "Bearer jusgo_revoked",
+			stub: func(s *mockproviders.MockServiceProvider) {
+				s.EXPECT().AuthenticateAPIKey(gomock.Any(), gomock.Any()).Times(1).Return(models.APIKey{}, service.ErrInvalidAPIKey)
+			},
+			status: http.StatusUnauthorized,
+		},
+		{name: "not bearer", header: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			s := mockproviders.NewMockServiceProvider(ctrl)
+			if tc.stub != nil {
+				tc.stub(s)
+			}
+			h := &handlerImpl{service: s, jwt: &JWTAuth{
+				Verifier:   jwt.NewVerifier(issuer, "jusgo", jwt.HMACKey(secret)),
+				RolesClaim: "roles",
+				Roles:      DefaultRoles(),
+			}}
+
+			r := httptest.NewRequest(http.MethodPost, "/jokes/1/vote", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+
+			client, err := h.clientID(r)
+			if tc.status != 0 {
+				_, status := ErrorInfo(err)
+				require.Equal(t, tc.status, status)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.client, client)
+		})
+	}
+}
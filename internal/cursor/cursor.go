@@ -0,0 +1,67 @@
+// Package cursor turns the position of a keyset paginated listing into an opaque token clients
+// hand back to get the next page. Tokens are signed, so a client can't make one up or edit one
+// to point somewhere the server never sent it.
+package cursor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned by Decode for tokens that are malformed or weren't signed with the same key.
+var ErrInvalid = errors.New("invalid cursor")
+
+// Cursor is where a listing continues from.
+type Cursor struct {
+	After string `json:"a"` // ID of the last joke that was returned
+}
+
+// Signer encodes and decodes cursors with an HMAC-SHA256 key.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Encode returns the token for c: its JSON encoding and signature, each base64url encoded and joined by a dot.
+func (s *Signer) Encode(c Cursor) string {
+	payload, _ := json.Marshal(c) // can't fail, Cursor only holds strings
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+// Decode checks the signature of token and returns the cursor it holds.
+func (s *Signer) Decode(token string) (Cursor, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Cursor{}, ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return Cursor{}, ErrInvalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return Cursor{}, ErrInvalid
+	}
+	if !hmac.Equal(signature, s.sign(payload)) {
+		return Cursor{}, ErrInvalid
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Cursor{}, ErrInvalid
+	}
+	return c, nil
+}
+
+func (s *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
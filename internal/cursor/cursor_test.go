@@ -0,0 +1,46 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	want := Cursor{After: "66a1f0c2e4b0a1b2c3d4e5f6"}
+
+	token := signer.Encode(want)
+	require.NotContains(t, token, want.After) // opaque, not just the ID
+
+	got, err := signer.Decode(token)
+	require.NoError(t, err)
+	require.Equal(t, want, got)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	token := signer.Encode(Cursor{After: "66a1f0c2e4b0a1b2c3d4e5f6"})
+	payload, signature, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"a":"ffffffffffffffffffffffff"}`))
+
+	testData := []struct {
+		Name  string
+		token string
+	}{
+		{Name: "Empty", token: ""},
+		{Name: "No signature", token: payload},
+		{Name: "Not base64", token: "!!!." + signature},
+		{Name: "Edited payload", token: forged + "." + signature},
+		{Name: "Signed with another key", token: NewSigner([]byte("other")).Encode(Cursor{After: "66a1f0c2e4b0a1b2c3d4e5f6"})},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := signer.Decode(tc.token)
+			require.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
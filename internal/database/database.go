@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -26,6 +28,30 @@ func ConnectToMongoDB(uri string, ctx context.Context) (*mongo.Client, context.C
 		return client, cancel, err
 	}
 
+	if err = requireTransactions(ctx, client); err != nil {
+		return client, cancel, err
+	}
+
 	log.Println("connected to mongodb")
 	return client, cancel, nil
 }
+
+// ErrStandalone is returned by ConnectToMongoDB for a standalone server. Joke writes run in transactions,
+// which MongoDB only supports on replica sets and sharded clusters.
+var ErrStandalone = errors.New("mongodb is a standalone server, transactions need a replica set: start it with --replSet and run rs.initiate(), make mongodb does")
+
+// requireTransactions fails with ErrStandalone unless client is connected to a replica set or a sharded cluster,
+// so a standalone server is found at startup rather than by the first write.
+func requireTransactions(ctx context.Context, client *mongo.Client) error {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"` // "isdbgrid" from the mongos of a sharded cluster
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return err
+	}
+	if hello.SetName == "" && hello.Msg != "isdbgrid" {
+		return ErrStandalone
+	}
+	return nil
+}
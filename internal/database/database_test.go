@@ -1,19 +1,96 @@
 package database
 
 import (
-	"context" 
+	"context"
+	"database/sql"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/joho/godotenv"
-	_ "github.com/joho/godotenv/autoload"
 	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/models"
 )
 
 func TestConnectToMongoDB(t *testing.T) {
-	err := godotenv.Load("../../.env")
-	require.NoError(t,err) 
+	_ = godotenv.Load("../../.env")
 
-	_, _, err = ConnectToMongoDB(os.Getenv("DB_SOURCE"), context.Background())
+	source := os.Getenv("DB_SOURCE")
+	if source == "" || strings.HasPrefix(source, "memory://") {
+		t.Skip("DB_SOURCE is not a mongodb uri")
+	}
+
+	_, _, err := ConnectToMongoDB(source, context.Background())
+	require.NoError(t, err)
+}
+
+func TestConnectToSQLite(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "jusgo.db")
+
+	migrations, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+	latest := migrations[len(migrations)-1].version
+
+	db, err := ConnectToSQLite(dsn, ctx)
+	require.NoError(t, err)
+
+	version, err := SchemaVersion(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, latest, version)
+	require.NoError(t, db.Close())
+
+	// reopening applies nothing new
+	db, err = ConnectToSQLite(dsn, ctx)
+	require.NoError(t, err)
+	defer db.Close()
+
+	version, err = SchemaVersion(ctx, db)
+	require.NoError(t, err)
+	require.Equal(t, latest, version)
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	sqlite, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+	postgres, err := loadMigrations("postgres")
+	require.NoError(t, err)
+
+	// every schema change has to be written for both databases
+	require.Len(t, postgres, len(sqlite))
+	for i := range sqlite {
+		require.Equal(t, sqlite[i].name, postgres[i].name)
+		require.Equal(t, i+1, sqlite[i].version)
+	}
+}
+
+func TestMigrationBackfillsCategory(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "jusgo.db"))
 	require.NoError(t, err)
+	defer db.Close()
+
+	migrations, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, `CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY)`)
+	require.NoError(t, err)
+	// a joke stored after categories were added but before they were backfilled
+	for _, m := range migrations {
+		if strings.HasSuffix(m.name, "_backfill_category.sql") {
+			break
+		}
+		require.NoError(t, applyMigration(ctx, conn, m))
+	}
+	_, err = conn.ExecContext(ctx, `INSERT INTO jokes (id, joke, created_at, updated_at) VALUES ('legacy', 'joke', '2024-01-01', '2024-01-01')`)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	require.NoError(t, Migrate(ctx, db, "sqlite"))
+
+	var category string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT category FROM jokes WHERE id = 'legacy'`).Scan(&category))
+	require.Equal(t, models.DefaultCategory, category)
 }
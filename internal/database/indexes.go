@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateMongoIndexes creates the indexes the jokes, submissions, votes, revisions and API keys queries rely on.
+// Indexes that already exist are left alone, so it is safe to call on every startup. Jokes stored before
+// categories existed are filed under models.DefaultCategory on the way, so filtering by it finds them.
+func CreateMongoIndexes(ctx context.Context, collection, submissions, votes, revisions, apiKeys *mongo.Collection) error {
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"category": bson.M{"$in": bson.A{nil, ""}}}, // nil matches a missing category
+		bson.M{"$set": bson.M{"category": models.DefaultCategory}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "type", Value: 1}}},
+		{Keys: bson.D{{Key: "category", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		// sorting by a timestamp breaks ties by _id, an index serves both directions
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "updated_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "rating", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "joke", Value: "text"}, {Key: "setup", Value: "text"}, {Key: "delivery", Value: "text"}}},
+		// one entry per bucket of the signature, near duplicates are looked up by bucket
+		{Keys: bson.D{{Key: "signature", Value: 1}}},
+		// partial, so jokes stored before normalized existed don't all collide on a missing value
+		{
+			Keys: bson.D{{Key: "normalized", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"normalized": bson.M{"$type": "string"}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// submissions are listed by status, oldest first
+	_, err = submissions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = votes.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		// a client gets one vote per joke and window
+		{
+			Keys:    bson.D{{Key: "joke_id", Value: 1}, {Key: "client", Value: 1}, {Key: "window", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// revisions are numbered per joke, the index also keeps two changes from taking the same number
+	_, err = revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "joke_id", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// every request with an API key looks it up by the hash of its secret
+	_, err = apiKeys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
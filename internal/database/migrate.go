@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// migrationLockID is the postgres advisory lock held while migrating, so replicas
+// starting at the same time don't apply the same migration twice.
+const migrationLockID = 4857392
+
+// Migrate applies the embedded migrations for dialect ("sqlite" or "postgres") that have not been
+// applied to db yet. Every migration runs in its own transaction together with the insert that records it.
+func Migrate(ctx context.Context, db *sql.DB, dialect string) error {
+	pending, err := loadMigrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if dialect == "postgres" {
+		if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock(`+strconv.Itoa(migrationLockID)+`)`); err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(`+strconv.Itoa(migrationLockID)+`)`)
+	}
+
+	if _, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var current sql.NullInt64
+	if err = conn.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range pending {
+		if int64(m.version) <= current.Int64 {
+			continue
+		}
+
+		if err = applyMigration(ctx, conn, m); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+		}
+		log.Printf("applied migration %s", m.name)
+	}
+	return nil
+}
+
+// SchemaVersion returns the version of the last migration applied to db, 0 if none was.
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, m.query); err != nil {
+		return err
+	}
+
+	// the version comes from our own file names so there is nothing to escape
+	if _, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (`+strconv.Itoa(m.version)+`)`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadMigrations reads migrations/<dialect>/NNNN_name.sql sorted by version.
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrations, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q: %w", dialect, err)
+	}
+
+	list := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+
+		query, err := fs.ReadFile(migrations, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, migration{version: version, name: name, query: string(query)})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].version < list[j].version
+	})
+	return list, nil
+}
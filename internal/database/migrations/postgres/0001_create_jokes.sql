@@ -0,0 +1,6 @@
+CREATE TABLE jokes (
+    id         TEXT COLLATE "C" PRIMARY KEY, -- hex encoded ObjectID, "C" keeps byte ordering
+    joke       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
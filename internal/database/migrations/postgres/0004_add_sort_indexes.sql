@@ -0,0 +1,3 @@
+-- listings sort by a timestamp with id as the tie breaker, and filter on the same timestamps
+CREATE INDEX jokes_created_at ON jokes (created_at, id);
+CREATE INDEX jokes_updated_at ON jokes (updated_at, id);
@@ -0,0 +1,4 @@
+-- NULL for jokes stored before, NULLs don't collide in a unique index
+ALTER TABLE jokes ADD COLUMN normalized TEXT;
+
+CREATE UNIQUE INDEX jokes_normalized ON jokes (normalized);
@@ -0,0 +1,16 @@
+CREATE TABLE submissions (
+    id         TEXT COLLATE "C" PRIMARY KEY, -- hex encoded ObjectID, "C" keeps byte ordering
+    type       TEXT NOT NULL,
+    joke       TEXT NOT NULL,
+    setup      TEXT NOT NULL,
+    delivery   TEXT NOT NULL,
+    category   TEXT NOT NULL,
+    tags       TEXT NOT NULL, -- JSON array
+    status     TEXT NOT NULL,
+    reason     TEXT NOT NULL,
+    joke_id    TEXT NOT NULL, -- the joke it was approved as
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX submissions_status ON submissions (status, id);
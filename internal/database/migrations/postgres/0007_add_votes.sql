@@ -0,0 +1,17 @@
+ALTER TABLE jokes ADD COLUMN upvotes BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE jokes ADD COLUMN downvotes BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE jokes ADD COLUMN rating DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX jokes_rating ON jokes (rating, id);
+
+-- one vote per client, joke and window
+CREATE TABLE votes (
+    joke_id      TEXT NOT NULL,
+    client       TEXT NOT NULL,
+    up           BOOLEAN NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (joke_id, client, window_start)
+);
+
+CREATE INDEX votes_created_at ON votes (created_at);
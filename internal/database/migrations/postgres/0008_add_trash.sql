@@ -0,0 +1,4 @@
+-- NULL for live jokes, set while a joke is in the trash
+ALTER TABLE jokes ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX jokes_deleted_at ON jokes (deleted_at, id);
@@ -0,0 +1,11 @@
+-- only the SHA-256 hash of a key is stored, the secret is shown once when the key is created
+CREATE TABLE api_keys (
+    id         TEXT PRIMARY KEY, -- hex encoded ObjectID
+    name       TEXT NOT NULL,
+    prefix     TEXT NOT NULL, -- start of the secret, to tell keys apart
+    hash       TEXT NOT NULL UNIQUE,
+    scopes     TEXT NOT NULL, -- JSON array
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
@@ -0,0 +1,8 @@
+-- the dedupe.Signature buckets of every joke, near duplicates of a joke are looked up by its buckets
+CREATE TABLE joke_signatures (
+    bucket  TEXT NOT NULL,
+    joke_id TEXT NOT NULL,
+    PRIMARY KEY (bucket, joke_id)
+);
+
+CREATE INDEX joke_signatures_joke_id ON joke_signatures (joke_id);
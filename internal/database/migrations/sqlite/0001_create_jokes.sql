@@ -0,0 +1,6 @@
+CREATE TABLE jokes (
+    id         TEXT PRIMARY KEY, -- hex encoded ObjectID
+    joke       TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
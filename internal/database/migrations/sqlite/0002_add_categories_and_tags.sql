@@ -0,0 +1,4 @@
+ALTER TABLE jokes ADD COLUMN category TEXT NOT NULL DEFAULT '';
+ALTER TABLE jokes ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'; -- JSON array
+
+CREATE INDEX jokes_category ON jokes (category);
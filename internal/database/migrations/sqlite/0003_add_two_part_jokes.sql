@@ -0,0 +1,5 @@
+ALTER TABLE jokes ADD COLUMN type TEXT NOT NULL DEFAULT 'single';
+ALTER TABLE jokes ADD COLUMN setup TEXT NOT NULL DEFAULT '';
+ALTER TABLE jokes ADD COLUMN delivery TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX jokes_type ON jokes (type);
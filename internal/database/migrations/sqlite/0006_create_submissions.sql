@@ -0,0 +1,16 @@
+CREATE TABLE submissions (
+    id         TEXT PRIMARY KEY, -- hex encoded ObjectID
+    type       TEXT NOT NULL,
+    joke       TEXT NOT NULL,
+    setup      TEXT NOT NULL,
+    delivery   TEXT NOT NULL,
+    category   TEXT NOT NULL,
+    tags       TEXT NOT NULL, -- JSON array
+    status     TEXT NOT NULL,
+    reason     TEXT NOT NULL,
+    joke_id    TEXT NOT NULL, -- the joke it was approved as
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX submissions_status ON submissions (status, id);
@@ -0,0 +1,17 @@
+ALTER TABLE jokes ADD COLUMN upvotes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jokes ADD COLUMN downvotes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jokes ADD COLUMN rating REAL NOT NULL DEFAULT 0;
+
+CREATE INDEX jokes_rating ON jokes (rating, id);
+
+-- one vote per client, joke and window
+CREATE TABLE votes (
+    joke_id      TEXT NOT NULL,
+    client       TEXT NOT NULL,
+    up           BOOLEAN NOT NULL,
+    window_start TIMESTAMP NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    PRIMARY KEY (joke_id, client, window_start)
+);
+
+CREATE INDEX votes_created_at ON votes (created_at);
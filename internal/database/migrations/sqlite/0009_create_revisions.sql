@@ -0,0 +1,14 @@
+-- revisions are never updated or deleted, not even when their joke is purged
+CREATE TABLE revisions (
+    id          TEXT PRIMARY KEY, -- hex encoded ObjectID
+    joke_id     TEXT NOT NULL,
+    number      INTEGER NOT NULL,
+    action      TEXT NOT NULL,
+    old_content TEXT, -- JSON of the joke before the change, NULL when it was created or restored
+    new_content TEXT, -- JSON of the joke after the change, NULL when it was deleted
+    reverted    INTEGER NOT NULL,
+    actor       TEXT NOT NULL,
+    request_id  TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL,
+    UNIQUE (joke_id, number)
+);
@@ -0,0 +1,2 @@
+-- goes up with every update, delete and restore, updates and deletes compare it first
+ALTER TABLE jokes ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
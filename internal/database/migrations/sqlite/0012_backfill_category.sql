@@ -0,0 +1,2 @@
+-- jokes stored before categories existed got an empty one, file them under the default
+UPDATE jokes SET category = 'general' WHERE category = '';
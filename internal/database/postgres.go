@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// ConnectToPostgres establishes a connection to PostgreSQL and brings its schema up to date.
+func ConnectToPostgres(uri string, ctx context.Context) (*sql.DB, error) {
+	db, err := sql.Open("postgres", uri)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err = Migrate(ctx, db, "postgres"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Println("connected to postgres")
+	return db, nil
+}
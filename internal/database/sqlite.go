@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ConnectToSQLite opens the SQLite database file at dsn and brings its schema up to date.
+// The dsn may carry driver query parameters, e.g. "jusgo.db?_pragma=busy_timeout(5000)".
+func ConnectToSQLite(dsn string, ctx context.Context) (*sql.DB, error) {
+	// store timestamps in a format SQLite's date functions understand
+	if !strings.Contains(dsn, "_time_format=") {
+		if strings.Contains(dsn, "?") {
+			dsn += "&_time_format=sqlite"
+		} else {
+			dsn += "?_time_format=sqlite"
+		}
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, a single connection avoids "database is locked"
+	// errors and keeps ":memory:" databases from being split across connections.
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err = Migrate(ctx, db, "sqlite"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	log.Println("connected to sqlite")
+	return db, nil
+}
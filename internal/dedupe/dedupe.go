@@ -0,0 +1,121 @@
+// Package dedupe tells whether two jokes are the same joke told slightly differently.
+// Texts are compared after Normalize, near duplicates are found with the Jaccard similarity
+// of their character trigrams, which shrugs off small rewordings and typos. Signature lets
+// storage find the jokes worth comparing without reading every joke.
+package dedupe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// Threshold is the Similarity from which two jokes count as duplicates.
+const Threshold = 0.8
+
+// Normalize lower cases text, drops apostrophes and turns every other run of punctuation and
+// white space into a single space, so "I'm  DONE!" and "im done" normalize the same.
+func Normalize(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+
+	space := false
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(unicode.ToLower(r))
+		case r == '\'' || r == '’':
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// Trigrams returns the set of character trigrams of normalized, which should come from Normalize.
+// The text is padded with a space on both ends so short words have trigrams of their own.
+func Trigrams(normalized string) map[string]struct{} {
+	runes := []rune(" " + normalized + " ")
+	trigrams := make(map[string]struct{}, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		trigrams[string(runes[i:i+3])] = struct{}{}
+	}
+	return trigrams
+}
+
+// Jaccard returns the share of trigrams a and b have in common, from 0 for nothing to 1 for the same set.
+func Jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	shared := 0
+	for trigram := range a {
+		if _, ok := b[trigram]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Similarity compares two texts, see Jaccard.
+func Similarity(a, b string) float64 {
+	return Jaccard(Trigrams(Normalize(a)), Trigrams(Normalize(b)))
+}
+
+// Bands and Rows shape a Signature: Bands buckets of Rows MinHash values each. Two texts share a bucket
+// with probability 1-(1-J^Rows)^Bands for a Jaccard similarity J, over 99.9% at Threshold and about
+// 5% at 0.3, so few of the jokes that share a bucket aren't near duplicates.
+const (
+	Bands = 20
+	Rows  = 5
+)
+
+// Signature returns the MinHash LSH buckets of normalized, which should come from Normalize. Texts at
+// least Threshold similar almost always share a bucket, so the jokes sharing one with a new joke are the
+// only ones it needs to be compared to. It is nil for a text too short to have a trigram.
+func Signature(normalized string) []string {
+	trigrams := Trigrams(normalized)
+	if len(trigrams) == 0 {
+		return nil
+	}
+
+	var mins [Bands * Rows]uint64
+	for i := range mins {
+		mins[i] = ^uint64(0)
+	}
+	for trigram := range trigrams {
+		h := fnv.New64a()
+		h.Write([]byte(trigram))
+		base := h.Sum64()
+		for i := range mins {
+			mins[i] = min(mins[i], mix(base^uint64(i+1)*0x9e3779b97f4a7c15))
+		}
+	}
+
+	buckets := make([]string, Bands)
+	for band := range buckets {
+		h := fnv.New64a()
+		for _, value := range mins[band*Rows : (band+1)*Rows] {
+			h.Write(binary.BigEndian.AppendUint64(nil, value))
+		}
+		buckets[band] = fmt.Sprintf("%02d:%016x", band, h.Sum64())
+	}
+	return buckets
+}
+
+// mix is the finalizer of SplitMix64, it turns one hash of a trigram into as many independent ones as needed.
+func mix(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,82 @@
+package dedupe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	testData := []struct {
+		Name string
+		text string
+		want string
+	}{
+		{Name: "Case and punctuation", text: "I'm declaring a WAR. var war;", want: "im declaring a war var war"},
+		{Name: "White space", text: "  two\tspaces \n here  ", want: "two spaces here"},
+		{Name: "Curly apostrophe", text: "Don’t panic", want: "dont panic"},
+		{Name: "Punctuation between words", text: "end.Start", want: "end start"},
+		{Name: "Digits and unicode letters", text: "Ünïcode 404!", want: "ünïcode 404"},
+		{Name: "Nothing left", text: "?!...", want: ""},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Equal(t, tc.want, Normalize(tc.text))
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	testData := []struct {
+		Name      string
+		a, b      string
+		duplicate bool
+	}{
+		{Name: "Same after normalizing", a: "I'm declaring a war. var war", b: "im declaring a WAR - var war!", duplicate: true},
+		{Name: "Typo", a: "Why do programmers prefer dark mode? Because light attracts bugs.", b: "Why do programers prefer dark mode? Because light attracts bugs.", duplicate: true},
+		{Name: "Small rewording", a: "Why do programmers prefer dark mode? Because light attracts bugs.", b: "Why do programmers prefer dark mode? Because the light attracts bugs!", duplicate: true},
+		{Name: "Same topic, different joke", a: "Why do programmers prefer dark mode? Because light attracts bugs.", b: "Why do Java developers wear glasses? Because they don't C#.", duplicate: false},
+		{Name: "Shared punchline only", a: "A SQL query walks into a bar and asks two tables: can I join you?", b: "Two tables walk into a bar.", duplicate: false},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			similarity := Similarity(tc.a, tc.b)
+			require.Equal(t, similarity, Similarity(tc.b, tc.a))
+			if tc.duplicate {
+				require.GreaterOrEqual(t, similarity, Threshold)
+			} else {
+				require.Less(t, similarity, Threshold)
+			}
+		})
+	}
+
+	require.Equal(t, 1.0, Similarity("same", "SAME!"))
+	require.Zero(t, Similarity("abc", "xyz"))
+}
+
+func TestSignature(t *testing.T) {
+	shares := func(a, b []string) bool {
+		for i := range a {
+			if a[i] == b[i] {
+				return true
+			}
+		}
+		return false
+	}
+
+	joke := Normalize("Why do programmers prefer dark mode? Because light attracts bugs.")
+	signature := Signature(joke)
+	require.Len(t, signature, Bands)
+	require.Equal(t, signature, Signature(joke))
+
+	near := Normalize("Why do programmers prefer dark mode? Because the light attracts bugs!")
+	require.GreaterOrEqual(t, Jaccard(Trigrams(joke), Trigrams(near)), Threshold)
+	require.True(t, shares(signature, Signature(near)))
+
+	other := Normalize("To understand recursion you must first understand recursion")
+	require.False(t, shares(signature, Signature(other)))
+
+	require.Nil(t, Signature(""))
+}
@@ -0,0 +1,282 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// HMACKey is a KeySource for HS256 tokens signed with one shared secret, whatever their key ID.
+type HMACKey []byte
+
+// MinHMACKeyLength is the shortest secret NewHMACKey takes. Anyone who guesses the secret can sign tokens
+// with any role, so it has to be at least as long as the 256 bit hash HS256 signs with.
+const MinHMACKeyLength = 32
+
+// ErrWeakKey is returned by NewHMACKey for a secret shorter than MinHMACKeyLength bytes.
+var ErrWeakKey = errors.New("HS256 secret must be at least 32 bytes, e.g. the output of openssl rand -base64 32")
+
+// NewHMACKey returns secret as an HMACKey, or ErrWeakKey if it is too short to be safe.
+func NewHMACKey(secret string) (HMACKey, error) {
+	if len(secret) < MinHMACKeyLength {
+		return nil, ErrWeakKey
+	}
+	return HMACKey(secret), nil
+}
+
+func (k HMACKey) Key(_ context.Context, _, alg string) (any, error) {
+	if alg != HS256 {
+		return nil, fmt.Errorf("%w: only %s tokens are accepted", ErrInvalidToken, HS256)
+	}
+	return []byte(k), nil
+}
+
+// minRefresh is how long after a load a token with an unknown key ID loads the set again, so tokens with
+// made up key IDs can't have every request load it.
+const minRefresh = 10 * time.Second
+
+// maxJWKSBytes caps the size of a key set.
+const maxJWKSBytes = 1 << 20
+
+// JWKS is a KeySource that loads a JSON Web Key Set from a file or an http(s) URL. The set is kept for a ttl
+// and loaded again once it passed, or earlier for a token with a key ID it doesn't have. That is how issuers
+// rotate keys: a new key is published in the set before tokens are signed with it.
+type JWKS struct {
+	source string
+	ttl    time.Duration
+	client *http.Client
+	loads  singleflight.Group
+
+	mu        sync.Mutex
+	keys      []jwk
+	loaded    time.Time // zero until the set was loaded once
+	attempted time.Time // of the last load, successful or not
+}
+
+// jwk is a key of a set, key is a []byte, *rsa.PublicKey or *ecdsa.PublicKey like KeySource returns.
+type jwk struct {
+	kid string
+	alg string // empty if the set doesn't restrict the key to an algorithm
+	key any
+}
+
+// NewJWKS returns a JWKS that loads the set from source, a file path or an http(s) URL, and keeps it for ttl.
+// Nothing is loaded until the first token is verified.
+func NewJWKS(source string, ttl time.Duration) *JWKS {
+	return &JWKS{source: source, ttl: ttl, client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+// Key returns the key with kid that fits alg. If the set can't be loaded again once the ttl passed,
+// the keys that were loaded before are used until it can.
+func (j *JWKS) Key(ctx context.Context, kid, alg string) (any, error) {
+	j.mu.Lock()
+	keys, loaded, attempted := j.keys, j.loaded, j.attempted
+	j.mu.Unlock()
+
+	// a failed load is only retried after minRefresh, an issuer that is down doesn't get a load per request
+	retry := time.Since(attempted) >= minRefresh
+	if retry && (loaded.IsZero() || time.Since(loaded) >= j.ttl) {
+		fresh, err := j.load(ctx)
+		switch {
+		case err == nil:
+			keys, loaded, retry = fresh, time.Now(), false
+		case loaded.IsZero():
+			return nil, err
+		}
+	}
+	if loaded.IsZero() {
+		return nil, fmt.Errorf("failed to load JWKS from %s, retrying in %s", j.source, (minRefresh - time.Since(attempted)).Round(time.Second))
+	}
+	if key, ok := findKey(keys, kid, alg); ok {
+		return key, nil
+	}
+
+	// the key may be new, which the set can only show once it was loaded again
+	if retry {
+		fresh, err := j.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := findKey(fresh, kid, alg); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no %s key with ID %q", ErrInvalidToken, alg, kid)
+}
+
+// load loads the set and returns its keys, concurrent loads share one.
+func (j *JWKS) load(ctx context.Context) ([]jwk, error) {
+	// the load is shared, so it must not stop when the context of the caller that started it is canceled
+	loadCtx := context.WithoutCancel(ctx)
+	loaded := j.loads.DoChan("", func() (any, error) {
+		keys, err := j.read(loadCtx)
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.attempted = time.Now()
+		if err != nil {
+			return []jwk(nil), fmt.Errorf("failed to load JWKS from %s: %w", j.source, err)
+		}
+		j.keys, j.loaded = keys, j.attempted
+		return keys, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-loaded:
+		return result.Val.([]jwk), result.Err
+	}
+}
+
+func (j *JWKS) read(ctx context.Context) ([]jwk, error) {
+	var body io.Reader
+	if strings.HasPrefix(j.source, "http://") || strings.HasPrefix(j.source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := j.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		body = resp.Body
+	} else {
+		f, err := os.Open(j.source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		body = f
+	}
+
+	var set struct {
+		Keys []rawJWK `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(body, maxJWKSBytes)).Decode(&set); err != nil {
+		return nil, err
+	}
+	return parseKeys(set.Keys)
+}
+
+// rawJWK holds the members of a JSON Web Key for the key types Verify supports.
+type rawJWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"` // RSA modulus
+	E   string `json:"e"` // RSA exponent
+	X   string `json:"x"` // EC point
+	Y   string `json:"y"`
+	K   string `json:"k"` // HMAC secret
+}
+
+// parseKeys returns the signing keys of a set. Keys for encryption and of other types are left out,
+// sets often hold them too, but a malformed key fails the whole set.
+func parseKeys(raw []rawJWK) ([]jwk, error) {
+	var keys []jwk
+	for _, r := range raw {
+		if r.Use != "" && r.Use != "sig" {
+			continue
+		}
+		var key any
+		var err error
+		switch r.Kty {
+		case "RSA":
+			key, err = parseRSAKey(r)
+		case "EC":
+			key, err = parseECKey(r)
+		case "oct":
+			key, err = base64.RawURLEncoding.DecodeString(r.K)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", r.Kid, err)
+		}
+		keys = append(keys, jwk{kid: r.Kid, alg: r.Alg, key: key})
+	}
+	return keys, nil
+}
+
+func parseRSAKey(r rawJWK) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(r.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(r.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) < 2048/8 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("unsupported RSA key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func parseECKey(r rawJWK) (*ecdsa.PublicKey, error) {
+	if r.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported curve %q", r.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(r.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(r.Y)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != 32 || len(y) != 32 {
+		return nil, fmt.Errorf("malformed P-256 point")
+	}
+	// ecdh checks that the point is on the curve
+	if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// findKey returns the key with kid that fits alg.
+func findKey(keys []jwk, kid, alg string) (any, bool) {
+	for _, k := range keys {
+		if k.kid != kid || k.alg != "" && k.alg != alg {
+			continue
+		}
+		switch k.key.(type) {
+		case []byte:
+			if alg == HS256 {
+				return k.key, true
+			}
+		case *rsa.PublicKey:
+			if alg == RS256 {
+				return k.key, true
+			}
+		case *ecdsa.PublicKey:
+			if alg == ES256 {
+				return k.key, true
+			}
+		}
+	}
+	return nil, false
+}
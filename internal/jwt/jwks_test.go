@@ -0,0 +1,160 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// keySet is a stand-in for the JWKS endpoint of an issuer, it serves the public keys of its current keys.
+type keySet struct {
+	mu    sync.Mutex
+	keys  map[string]any // private keys by kid
+	loads atomic.Int32
+	down  bool
+}
+
+func (s *keySet) set(keys map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *keySet) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.loads.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	json.NewEncoder(w).Encode(s.document())
+}
+
+func (s *keySet) document() map[string]any {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	var keys []map[string]string
+	for kid, key := range s.keys {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "kid": kid, "alg": RS256, "use": "sig",
+				"n": encode(key.N.Bytes()), "e": encode(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case *ecdsa.PrivateKey:
+			x, y := make([]byte, 32), make([]byte, 32)
+			keys = append(keys, map[string]string{
+				"kty": "EC", "kid": kid, "crv": "P-256",
+				"x": encode(key.X.FillBytes(x)), "y": encode(key.Y.FillBytes(y)),
+			})
+		case []byte:
+			keys = append(keys, map[string]string{"kty": "oct", "kid": kid, "k": encode(key)})
+		}
+	}
+	// keys for encryption are left out
+	keys = append(keys, map[string]string{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"})
+	return map[string]any{"keys": keys}
+}
+
+func TestJWKS(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("a shared secret")
+
+	issuer := &keySet{keys: map[string]any{"rsa-1": rsaKey, "ec-1": ecKey, "hmac-1": secret}}
+	server := httptest.NewServer(issuer)
+	defer server.Close()
+
+	jwks := NewJWKS(server.URL, time.Hour)
+	verifier := NewVerifier(testIssuer, testAudience, jwks)
+	verify := func(token string) error {
+		_, err := verifier.Verify(ctx, token)
+		return err
+	}
+
+	require.NoError(t, verify(sign(t, RS256, "rsa-1", rsaKey, validClaims())))
+	require.NoError(t, verify(sign(t, ES256, "ec-1", ecKey, validClaims())))
+	require.NoError(t, verify(sign(t, HS256, "hmac-1", secret, validClaims())))
+	require.Equal(t, int32(1), issuer.loads.Load(), "the set is cached")
+
+	// a key is only used for its algorithm
+	require.ErrorIs(t, verify(sign(t, HS256, "rsa-1", rsaKey.PublicKey.N.Bytes(), validClaims())), ErrInvalidToken)
+	require.ErrorIs(t, verify(sign(t, ES256, "rsa-1", ecKey, validClaims())), ErrInvalidToken)
+
+	// the issuer rotates to a new key, tokens signed with it load the set again
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer.set(map[string]any{"rsa-1": rsaKey, "rsa-2": rotated})
+	token := sign(t, RS256, "rsa-2", rotated, validClaims())
+
+	// but not more often than every minRefresh
+	require.ErrorIs(t, verify(token), ErrInvalidToken)
+	require.Equal(t, int32(1), issuer.loads.Load())
+	jwks.attempted = jwks.attempted.Add(-minRefresh)
+	require.NoError(t, verify(token))
+	require.Equal(t, int32(2), issuer.loads.Load())
+
+	// an unknown key after a load is invalid
+	jwks.attempted = jwks.attempted.Add(-minRefresh)
+	require.ErrorIs(t, verify(sign(t, RS256, "rsa-3", rotated, validClaims())), ErrInvalidToken)
+	require.Equal(t, int32(3), issuer.loads.Load())
+
+	// once the ttl passed the set is loaded again, if that fails the old keys still work
+	issuer.mu.Lock()
+	issuer.down = true
+	issuer.mu.Unlock()
+	jwks.loaded = jwks.loaded.Add(-time.Hour)
+	jwks.attempted = jwks.attempted.Add(-minRefresh)
+	require.NoError(t, verify(token))
+	require.Equal(t, int32(4), issuer.loads.Load())
+}
+
+func TestJWKSFile(t *testing.T) {
+	ctx := context.Background()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	document, err := json.Marshal((&keySet{keys: map[string]any{"ec-1": ecKey}}).document())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, document, 0o600))
+
+	verifier := NewVerifier(testIssuer, testAudience, NewJWKS(path, time.Minute))
+	_, err = verifier.Verify(ctx, sign(t, ES256, "ec-1", ecKey, validClaims()))
+	require.NoError(t, err)
+
+	// a set that can't be loaded fails verification, but doesn't make the token invalid
+	verifier = NewVerifier(testIssuer, testAudience, NewJWKS(filepath.Join(t.TempDir(), "missing.json"), time.Minute))
+	_, err = verifier.Verify(ctx, sign(t, ES256, "ec-1", ecKey, validClaims()))
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewHMACKey(t *testing.T) {
+	_, err := NewHMACKey("x")
+	require.ErrorIs(t, err, ErrWeakKey)
+	_, err = NewHMACKey("0123456789abcdef0123456789abcde")
+	require.ErrorIs(t, err, ErrWeakKey)
+
+	key, err := NewHMACKey("0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+	require.Equal(t, HMACKey("0123456789abcdef0123456789abcdef"), key)
+}
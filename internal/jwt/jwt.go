@@ -0,0 +1,238 @@
+// Package jwt verifies the JSON Web Tokens an identity provider, like an OpenID Connect one, issues.
+// Tokens signed with HS256, RS256 or ES256 are accepted, any other algorithm, "none" included, is not.
+// The keys to verify them with come from a KeySource, like a JSON Web Key Set, see NewJWKS.
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned by Verify for tokens that are malformed, not signed by a known key or
+// that fail a claim check. The error says which.
+var ErrInvalidToken = errors.New("invalid token")
+
+// The signing algorithms Verify accepts.
+const (
+	HS256 = "HS256" // HMAC with SHA-256, the key is a shared secret
+	RS256 = "RS256" // RSASSA-PKCS1-v1_5 with SHA-256
+	ES256 = "ES256" // ECDSA on P-256 with SHA-256
+)
+
+// Leeway is how far the clocks of the issuer and the server may be apart, it is added to exp and taken off nbf.
+const Leeway = 30 * time.Second
+
+// KeySource looks up the key that verifies a token with the key ID kid and algorithm alg.
+// It returns a []byte for HS256, an *rsa.PublicKey for RS256 and an *ecdsa.PublicKey for ES256.
+type KeySource interface {
+	Key(ctx context.Context, kid, alg string) (any, error)
+}
+
+// Claims holds the registered claims Verify checks and every claim of a token by name, see Strings.
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time // zero without nbf
+	IssuedAt  time.Time // zero without iat
+
+	raw map[string]json.RawMessage
+}
+
+// Strings returns the claim name as a list of strings. A list is returned as is and a single string is split
+// at spaces, like the OAuth scope claim. Dots in name reach into objects, like realm_access.roles.
+func (c Claims) Strings(name string) []string {
+	raw := c.raw
+	path := strings.Split(name, ".")
+	for _, key := range path[:len(path)-1] {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw[key], &object); err != nil {
+			return nil
+		}
+		raw = object
+	}
+
+	value := raw[path[len(path)-1]]
+	var list []string
+	if err := json.Unmarshal(value, &list); err == nil {
+		return list
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return strings.Fields(s)
+	}
+	return nil
+}
+
+// Verifier checks the signature and the claims of tokens from one issuer for one audience.
+type Verifier struct {
+	issuer   string
+	audience string
+	keys     KeySource
+	now      func() time.Time
+}
+
+// NewVerifier returns a Verifier for tokens issued by issuer to audience, which must be their iss and one
+// of their aud claims, and signed by a key from keys.
+func NewVerifier(issuer, audience string, keys KeySource) *Verifier {
+	return &Verifier{issuer: issuer, audience: audience, keys: keys, now: time.Now}
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type registeredClaims struct {
+	Issuer    string    `json:"iss"`
+	Subject   string    `json:"sub"`
+	Audience  audience  `json:"aud"`
+	ExpiresAt *jsonTime `json:"exp"`
+	NotBefore *jsonTime `json:"nbf"`
+	IssuedAt  *jsonTime `json:"iat"`
+}
+
+// Verify checks that token is signed by a key of the KeySource, that it was issued by the issuer to the
+// audience and that it is valid now, and returns its claims. The exp and sub claims are required, nbf is
+// optional. Votes and the joke history tell clients apart by sub, a token without one would share them.
+// Errors from the KeySource are returned as is, a failure to load the keys doesn't make a token invalid.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("%w: not a signed JWT", ErrInvalidToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed header", ErrInvalidToken)
+	}
+	if !slices.Contains([]string{HS256, RS256, ES256}, h.Alg) {
+		return Claims{}, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, h.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+
+	key, err := v.keys.Key(ctx, h.Kid, h.Alg)
+	if err != nil {
+		return Claims{}, err
+	}
+	if err := verifySignature(h.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return Claims{}, err
+	}
+
+	var claims Claims
+	var registered registeredClaims
+	if err := decodeSegment(parts[1], &claims.raw); err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	if err := decodeSegment(parts[1], &registered); err != nil {
+		return Claims{}, fmt.Errorf("%w: malformed registered claims", ErrInvalidToken)
+	}
+	claims.Issuer = registered.Issuer
+	claims.Subject = registered.Subject
+	claims.Audience = registered.Audience
+	claims.ExpiresAt = registered.ExpiresAt.Time()
+	claims.NotBefore = registered.NotBefore.Time()
+	claims.IssuedAt = registered.IssuedAt.Time()
+
+	return claims, v.checkClaims(claims)
+}
+
+func (v *Verifier) checkClaims(claims Claims) error {
+	now := v.now()
+	switch {
+	case claims.Issuer != v.issuer:
+		return fmt.Errorf("%w: issued by %q", ErrInvalidToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, v.audience):
+		return fmt.Errorf("%w: not issued to %q", ErrInvalidToken, v.audience)
+	case claims.ExpiresAt.IsZero():
+		return fmt.Errorf("%w: no exp claim", ErrInvalidToken)
+	case strings.TrimSpace(claims.Subject) == "":
+		return fmt.Errorf("%w: no sub claim", ErrInvalidToken)
+	case !now.Before(claims.ExpiresAt.Add(Leeway)):
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	case now.Add(Leeway).Before(claims.NotBefore):
+		return fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	return nil
+}
+
+// verifySignature checks signature of signed with key. The key must be of the kind alg asks for,
+// so a public RSA key can never be used as an HMAC secret.
+func verifySignature(alg string, key any, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	valid := false
+	switch key := key.(type) {
+	case []byte:
+		if alg != HS256 {
+			break
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		valid = hmac.Equal(signature, mac.Sum(nil))
+	case *rsa.PublicKey:
+		if alg != RS256 {
+			break
+		}
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// the signature is r and s, 32 bytes each, not ASN.1 like crypto/ecdsa signs
+		if alg != ES256 || key.Curve != elliptic.P256() || len(signature) != 64 {
+			break
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		valid = ecdsa.Verify(key, digest[:], r, s)
+	}
+	if !valid {
+		return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// audience is the aud claim, which is a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+// jsonTime is a NumericDate claim, seconds since the Unix epoch that may have a fraction.
+type jsonTime float64
+
+func (t *jsonTime) Time() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	seconds, fraction := math.Modf(float64(*t))
+	return time.Unix(int64(seconds), int64(fraction*1e9))
+}
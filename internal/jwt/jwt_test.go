@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "jusgo"
+)
+
+// sign returns a token with claims signed by key, a []byte, *rsa.PrivateKey or *ecdsa.PrivateKey, for alg.
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		require.NoError(t, err)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns claims that pass every check, tests change them to fail one.
+func validClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":   testIssuer,
+		"sub":   "alice",
+		"aud":   testAudience,
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Add(-time.Minute).Unix(),
+		"iat":   now.Unix(),
+		"roles": []string{"editor"},
+	}
+}
+
+func with(change func(claims map[string]any)) map[string]any {
+	claims := validClaims()
+	change(claims)
+	return claims
+}
+
+// staticKeys is a KeySource that holds every test key under its algorithm.
+type staticKeys map[string]any
+
+func (k staticKeys) Key(_ context.Context, _, alg string) (any, error) {
+	return k[alg], nil
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("a very secret key for hs256 tokens")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keys := staticKeys{HS256: secret, RS256: &rsaKey.PublicKey, ES256: &ecKey.PublicKey}
+	verifier := NewVerifier(testIssuer, testAudience, keys)
+
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	tampered := sign(t, HS256, "", secret, validClaims())
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	testData := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "HS256", token: sign(t, HS256, "", secret, validClaims())},
+		{name: "RS256", token: sign(t, RS256, "", rsaKey, validClaims())},
+		{name: "ES256", token: sign(t, ES256, "", ecKey, validClaims())},
+		{name: "audience list", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { c["aud"] = []string{"other", testAudience} }))},
+		{name: "expired within leeway", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { c["exp"] = time.Now().Add(-Leeway / 2).Unix() }))},
+		{name: "no nbf", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { delete(c, "nbf") }))},
+		{name: "none", token: sign(t, "none", "", nil, validClaims()), wantErr: true},
+		{name: "HS512", token: sign(t, "HS512", "", secret, validClaims()), wantErr: true},
+		// the public RSA key is public, it must not work as an HMAC secret
+		{name: "HS256 with RSA key", token: sign(t, HS256, "", rsaPublic, validClaims()), wantErr: true},
+		{name: "other key", token: sign(t, HS256, "", []byte("wrong"), validClaims()), wantErr: true},
+		{name: "tampered", token: tampered, wantErr: true},
+		{name: "other issuer", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { c["iss"] = "https://evil.example.com" })), wantErr: true},
+		{name: "other audience", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { c["aud"] = "other" })), wantErr: true},
+		{name: "no audience", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { delete(c, "aud") })), wantErr: true},
+		{name: "expired", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() })), wantErr: true},
+		{name: "no exp", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { delete(c, "exp") })), wantErr: true},
+		{name: "no sub", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { delete(c, "sub") })), wantErr: true},
+		{name: "empty sub", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { c["sub"] = " " })), wantErr: true},
+		{name: "not yet valid", token: sign(t, RS256, "", rsaKey, with(func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() })), wantErr: true},
+		{name: "malformed", token: "not.a.jwt", wantErr: true},
+		{name: "api key", token: "jusgo_abcdef", wantErr: true},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tc.token)
+			if tc.wantErr {
+				require.ErrorIs(t, err, ErrInvalidToken)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "alice", claims.Subject)
+			require.Equal(t, testIssuer, claims.Issuer)
+			require.Contains(t, claims.Audience, testAudience)
+			require.Equal(t, []string{"editor"}, claims.Strings("roles"))
+		})
+	}
+}
+
+func TestClaimsStrings(t *testing.T) {
+	secret := []byte("secret")
+	token := sign(t, HS256, "", secret, with(func(c map[string]any) {
+		c["scope"] = "openid jokes:write"
+		c["realm_access"] = map[string]any{"roles": []string{"admin", "editor"}}
+		c["number"] = 4
+	}))
+	claims, err := NewVerifier(testIssuer, testAudience, HMACKey(secret)).Verify(context.Background(), token)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"openid", "jokes:write"}, claims.Strings("scope"))
+	require.Equal(t, []string{"admin", "editor"}, claims.Strings("realm_access.roles"))
+	require.Nil(t, claims.Strings("number"))
+	require.Nil(t, claims.Strings("missing"))
+	require.Nil(t, claims.Strings("missing.roles"))
+	require.Nil(t, claims.Strings("sub.roles"))
+}
@@ -12,9 +12,9 @@ package mockproviders
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/zde37/Jusgo/internal/models"
-	primitive "go.mongodb.org/mongo-driver/bson/primitive"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -41,6 +41,80 @@ func (m *MockRepositoryProvider) EXPECT() *MockRepositoryProviderMockRecorder {
 	return m.recorder
 }
 
+// AddRevision mocks base method.
+func (m *MockRepositoryProvider) AddRevision(arg0 context.Context, arg1 models.Revision) (models.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddRevision", arg0, arg1)
+	ret0, _ := ret[0].(models.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddRevision indicates an expected call of AddRevision.
+func (mr *MockRepositoryProviderMockRecorder) AddRevision(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddRevision", reflect.TypeOf((*MockRepositoryProvider)(nil).AddRevision), arg0, arg1)
+}
+
+// AddVote mocks base method.
+func (m *MockRepositoryProvider) AddVote(arg0 context.Context, arg1 models.Vote) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddVote", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddVote indicates an expected call of AddVote.
+func (mr *MockRepositoryProviderMockRecorder) AddVote(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddVote", reflect.TypeOf((*MockRepositoryProvider)(nil).AddVote), arg0, arg1)
+}
+
+// Count mocks base method.
+func (m *MockRepositoryProvider) Count(arg0 context.Context, arg1 models.JokeFilter) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Count", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Count indicates an expected call of Count.
+func (mr *MockRepositoryProviderMockRecorder) Count(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockRepositoryProvider)(nil).Count), arg0, arg1)
+}
+
+// CountRevisions mocks base method.
+func (m *MockRepositoryProvider) CountRevisions(arg0 context.Context, arg1 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountRevisions", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountRevisions indicates an expected call of CountRevisions.
+func (mr *MockRepositoryProviderMockRecorder) CountRevisions(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountRevisions", reflect.TypeOf((*MockRepositoryProvider)(nil).CountRevisions), arg0, arg1)
+}
+
+// CountSubmissions mocks base method.
+func (m *MockRepositoryProvider) CountSubmissions(arg0 context.Context, arg1 string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountSubmissions", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountSubmissions indicates an expected call of CountSubmissions.
+func (mr *MockRepositoryProviderMockRecorder) CountSubmissions(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountSubmissions", reflect.TypeOf((*MockRepositoryProvider)(nil).CountSubmissions), arg0, arg1)
+}
+
 // Create mocks base method.
 func (m *MockRepositoryProvider) Create(arg0 context.Context, arg1 models.Jusgo) (models.Jusgo, error) {
 	m.ctrl.T.Helper()
@@ -56,22 +130,67 @@ func (mr *MockRepositoryProviderMockRecorder) Create(arg0, arg1 any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepositoryProvider)(nil).Create), arg0, arg1)
 }
 
+// CreateAPIKey mocks base method.
+func (m *MockRepositoryProvider) CreateAPIKey(arg0 context.Context, arg1 models.APIKey) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockRepositoryProviderMockRecorder) CreateAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockRepositoryProvider)(nil).CreateAPIKey), arg0, arg1)
+}
+
+// CreateMany mocks base method.
+func (m *MockRepositoryProvider) CreateMany(arg0 context.Context, arg1 []models.Jusgo) ([]error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMany", arg0, arg1)
+	ret0, _ := ret[0].([]error)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMany indicates an expected call of CreateMany.
+func (mr *MockRepositoryProviderMockRecorder) CreateMany(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMany", reflect.TypeOf((*MockRepositoryProvider)(nil).CreateMany), arg0, arg1)
+}
+
+// CreateSubmission mocks base method.
+func (m *MockRepositoryProvider) CreateSubmission(arg0 context.Context, arg1 models.Submission) (models.Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubmission", arg0, arg1)
+	ret0, _ := ret[0].(models.Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSubmission indicates an expected call of CreateSubmission.
+func (mr *MockRepositoryProviderMockRecorder) CreateSubmission(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubmission", reflect.TypeOf((*MockRepositoryProvider)(nil).CreateSubmission), arg0, arg1)
+}
+
 // Delete mocks base method.
-func (m *MockRepositoryProvider) Delete(arg0 context.Context, arg1 primitive.ObjectID) error {
+func (m *MockRepositoryProvider) Delete(arg0 context.Context, arg1 string, arg2 int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", arg0, arg1)
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockRepositoryProviderMockRecorder) Delete(arg0, arg1 any) *gomock.Call {
+func (mr *MockRepositoryProviderMockRecorder) Delete(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepositoryProvider)(nil).Delete), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockRepositoryProvider)(nil).Delete), arg0, arg1, arg2)
 }
 
 // Get mocks base method.
-func (m *MockRepositoryProvider) Get(arg0 context.Context, arg1 primitive.ObjectID) (models.Jusgo, error) {
+func (m *MockRepositoryProvider) Get(arg0 context.Context, arg1 string) (models.Jusgo, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Get", arg0, arg1)
 	ret0, _ := ret[0].(models.Jusgo)
@@ -85,19 +204,316 @@ func (mr *MockRepositoryProviderMockRecorder) Get(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepositoryProvider)(nil).Get), arg0, arg1)
 }
 
+// GetAPIKeyByHash mocks base method.
+func (m *MockRepositoryProvider) GetAPIKeyByHash(arg0 context.Context, arg1 string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeyByHash", arg0, arg1)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeyByHash indicates an expected call of GetAPIKeyByHash.
+func (mr *MockRepositoryProviderMockRecorder) GetAPIKeyByHash(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeyByHash", reflect.TypeOf((*MockRepositoryProvider)(nil).GetAPIKeyByHash), arg0, arg1)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockRepositoryProvider) GetAPIKeys(arg0 context.Context) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", arg0)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockRepositoryProviderMockRecorder) GetAPIKeys(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockRepositoryProvider)(nil).GetAPIKeys), arg0)
+}
+
+// GetAfter mocks base method.
+func (m *MockRepositoryProvider) GetAfter(arg0 context.Context, arg1 models.JokeFilter, arg2 string, arg3 int64) ([]models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAfter", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAfter indicates an expected call of GetAfter.
+func (mr *MockRepositoryProviderMockRecorder) GetAfter(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAfter", reflect.TypeOf((*MockRepositoryProvider)(nil).GetAfter), arg0, arg1, arg2, arg3)
+}
+
 // GetAll mocks base method.
-func (m *MockRepositoryProvider) GetAll(arg0 context.Context, arg1, arg2 int64) ([]models.Jusgo, error) {
+func (m *MockRepositoryProvider) GetAll(arg0 context.Context, arg1 models.JokeFilter, arg2 models.JokeSort, arg3, arg4 int64) ([]models.Jusgo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "GetAll", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].([]models.Jusgo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetAll indicates an expected call of GetAll.
-func (mr *MockRepositoryProviderMockRecorder) GetAll(arg0, arg1, arg2 any) *gomock.Call {
+func (mr *MockRepositoryProviderMockRecorder) GetAll(arg0, arg1, arg2, arg3, arg4 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockRepositoryProvider)(nil).GetAll), arg0, arg1, arg2, arg3, arg4)
+}
+
+// GetByNormalized mocks base method.
+func (m *MockRepositoryProvider) GetByNormalized(arg0 context.Context, arg1 string) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByNormalized", arg0, arg1)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByNormalized indicates an expected call of GetByNormalized.
+func (mr *MockRepositoryProviderMockRecorder) GetByNormalized(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByNormalized", reflect.TypeOf((*MockRepositoryProvider)(nil).GetByNormalized), arg0, arg1)
+}
+
+// GetDeleted mocks base method.
+func (m *MockRepositoryProvider) GetDeleted(arg0 context.Context, arg1 string) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeleted", arg0, arg1)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeleted indicates an expected call of GetDeleted.
+func (mr *MockRepositoryProviderMockRecorder) GetDeleted(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeleted", reflect.TypeOf((*MockRepositoryProvider)(nil).GetDeleted), arg0, arg1)
+}
+
+// GetRandom mocks base method.
+func (m *MockRepositoryProvider) GetRandom(arg0 context.Context, arg1 models.JokeFilter, arg2 int64, arg3 []string) ([]models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRandom", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRandom indicates an expected call of GetRandom.
+func (mr *MockRepositoryProviderMockRecorder) GetRandom(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRandom", reflect.TypeOf((*MockRepositoryProvider)(nil).GetRandom), arg0, arg1, arg2, arg3)
+}
+
+// GetRevision mocks base method.
+func (m *MockRepositoryProvider) GetRevision(arg0 context.Context, arg1 string, arg2 int64) (models.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevision", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevision indicates an expected call of GetRevision.
+func (mr *MockRepositoryProviderMockRecorder) GetRevision(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevision", reflect.TypeOf((*MockRepositoryProvider)(nil).GetRevision), arg0, arg1, arg2)
+}
+
+// GetRevisions mocks base method.
+func (m *MockRepositoryProvider) GetRevisions(arg0 context.Context, arg1 string, arg2, arg3 int64) ([]models.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevisions", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevisions indicates an expected call of GetRevisions.
+func (mr *MockRepositoryProviderMockRecorder) GetRevisions(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevisions", reflect.TypeOf((*MockRepositoryProvider)(nil).GetRevisions), arg0, arg1, arg2, arg3)
+}
+
+// GetSimilar mocks base method.
+func (m *MockRepositoryProvider) GetSimilar(arg0 context.Context, arg1 []string, arg2 int64) ([]models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSimilar", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSimilar indicates an expected call of GetSimilar.
+func (mr *MockRepositoryProviderMockRecorder) GetSimilar(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSimilar", reflect.TypeOf((*MockRepositoryProvider)(nil).GetSimilar), arg0, arg1, arg2)
+}
+
+// GetSubmission mocks base method.
+func (m *MockRepositoryProvider) GetSubmission(arg0 context.Context, arg1 string) (models.Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubmission", arg0, arg1)
+	ret0, _ := ret[0].(models.Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubmission indicates an expected call of GetSubmission.
+func (mr *MockRepositoryProviderMockRecorder) GetSubmission(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubmission", reflect.TypeOf((*MockRepositoryProvider)(nil).GetSubmission), arg0, arg1)
+}
+
+// GetSubmissions mocks base method.
+func (m *MockRepositoryProvider) GetSubmissions(arg0 context.Context, arg1 string, arg2, arg3 int64) ([]models.Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubmissions", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubmissions indicates an expected call of GetSubmissions.
+func (mr *MockRepositoryProviderMockRecorder) GetSubmissions(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubmissions", reflect.TypeOf((*MockRepositoryProvider)(nil).GetSubmissions), arg0, arg1, arg2, arg3)
+}
+
+// GetVoteTotals mocks base method.
+func (m *MockRepositoryProvider) GetVoteTotals(arg0 context.Context, arg1 time.Time) ([]models.VoteTotal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVoteTotals", arg0, arg1)
+	ret0, _ := ret[0].([]models.VoteTotal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVoteTotals indicates an expected call of GetVoteTotals.
+func (mr *MockRepositoryProviderMockRecorder) GetVoteTotals(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVoteTotals", reflect.TypeOf((*MockRepositoryProvider)(nil).GetVoteTotals), arg0, arg1)
+}
+
+// IncrementVotes mocks base method.
+func (m *MockRepositoryProvider) IncrementVotes(arg0 context.Context, arg1 string, arg2, arg3 int64) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementVotes", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrementVotes indicates an expected call of IncrementVotes.
+func (mr *MockRepositoryProviderMockRecorder) IncrementVotes(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementVotes", reflect.TypeOf((*MockRepositoryProvider)(nil).IncrementVotes), arg0, arg1, arg2, arg3)
+}
+
+// Purge mocks base method.
+func (m *MockRepositoryProvider) Purge(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockRepositoryProviderMockRecorder) Purge(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockRepositoryProvider)(nil).Purge), arg0, arg1)
+}
+
+// PurgeDeletedBefore mocks base method.
+func (m *MockRepositoryProvider) PurgeDeletedBefore(arg0 context.Context, arg1 time.Time, arg2 int64) ([]models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedBefore", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedBefore indicates an expected call of PurgeDeletedBefore.
+func (mr *MockRepositoryProviderMockRecorder) PurgeDeletedBefore(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedBefore", reflect.TypeOf((*MockRepositoryProvider)(nil).PurgeDeletedBefore), arg0, arg1, arg2)
+}
+
+// Restore mocks base method.
+func (m *MockRepositoryProvider) Restore(arg0 context.Context, arg1, arg2 string) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockRepositoryProviderMockRecorder) Restore(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockRepositoryProvider)(nil).Restore), arg0, arg1, arg2)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockRepositoryProvider) RevokeAPIKey(arg0 context.Context, arg1 string, arg2 time.Time) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockRepositoryProviderMockRecorder) RevokeAPIKey(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockRepositoryProvider)(nil).RevokeAPIKey), arg0, arg1, arg2)
+}
+
+// RunInTransaction mocks base method.
+func (m *MockRepositoryProvider) RunInTransaction(arg0 context.Context, arg1 func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunInTransaction", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RunInTransaction indicates an expected call of RunInTransaction.
+func (mr *MockRepositoryProviderMockRecorder) RunInTransaction(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunInTransaction", reflect.TypeOf((*MockRepositoryProvider)(nil).RunInTransaction), arg0, arg1)
+}
+
+// Search mocks base method.
+func (m *MockRepositoryProvider) Search(arg0 context.Context, arg1 string, arg2, arg3 int64) ([]models.SearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.SearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockRepositoryProviderMockRecorder) Search(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockRepositoryProvider)(nil).Search), arg0, arg1, arg2, arg3)
+}
+
+// SetSignature mocks base method.
+func (m *MockRepositoryProvider) SetSignature(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSignature", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSignature indicates an expected call of SetSignature.
+func (mr *MockRepositoryProviderMockRecorder) SetSignature(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockRepositoryProvider)(nil).GetAll), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSignature", reflect.TypeOf((*MockRepositoryProvider)(nil).SetSignature), arg0, arg1, arg2)
 }
 
 // Update mocks base method.
@@ -114,3 +530,18 @@ func (mr *MockRepositoryProviderMockRecorder) Update(arg0, arg1 any) *gomock.Cal
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockRepositoryProvider)(nil).Update), arg0, arg1)
 }
+
+// UpdateSubmission mocks base method.
+func (m *MockRepositoryProvider) UpdateSubmission(arg0 context.Context, arg1 models.Submission) (models.Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSubmission", arg0, arg1)
+	ret0, _ := ret[0].(models.Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSubmission indicates an expected call of UpdateSubmission.
+func (mr *MockRepositoryProviderMockRecorder) UpdateSubmission(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSubmission", reflect.TypeOf((*MockRepositoryProvider)(nil).UpdateSubmission), arg0, arg1)
+}
@@ -12,6 +12,7 @@ package mockproviders
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/zde37/Jusgo/internal/models"
 	gomock "go.uber.org/mock/gomock"
@@ -40,48 +41,171 @@ func (m *MockServiceProvider) EXPECT() *MockServiceProviderMockRecorder {
 	return m.recorder
 }
 
+// ApproveSubmission mocks base method.
+func (m *MockServiceProvider) ApproveSubmission(arg0 context.Context, arg1 string, arg2 bool) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveSubmission", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveSubmission indicates an expected call of ApproveSubmission.
+func (mr *MockServiceProviderMockRecorder) ApproveSubmission(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveSubmission", reflect.TypeOf((*MockServiceProvider)(nil).ApproveSubmission), arg0, arg1, arg2)
+}
+
+// AuthenticateAPIKey mocks base method.
+func (m *MockServiceProvider) AuthenticateAPIKey(arg0 context.Context, arg1 string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateAPIKey indicates an expected call of AuthenticateAPIKey.
+func (mr *MockServiceProviderMockRecorder) AuthenticateAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateAPIKey", reflect.TypeOf((*MockServiceProvider)(nil).AuthenticateAPIKey), arg0, arg1)
+}
+
+// BootstrapAPIKey mocks base method.
+func (m *MockServiceProvider) BootstrapAPIKey(arg0 context.Context, arg1 string) (models.APIKey, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BootstrapAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// BootstrapAPIKey indicates an expected call of BootstrapAPIKey.
+func (mr *MockServiceProviderMockRecorder) BootstrapAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BootstrapAPIKey", reflect.TypeOf((*MockServiceProvider)(nil).BootstrapAPIKey), arg0, arg1)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockServiceProvider) CreateAPIKey(arg0 context.Context, arg1 string, arg2 []string, arg3 *time.Time) (models.APIKey, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockServiceProviderMockRecorder) CreateAPIKey(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockServiceProvider)(nil).CreateAPIKey), arg0, arg1, arg2, arg3)
+}
+
 // CreateJoke mocks base method.
-func (m *MockServiceProvider) CreateJoke(arg0 context.Context, arg1 models.Jusgo) (models.Jusgo, error) {
+func (m *MockServiceProvider) CreateJoke(arg0 context.Context, arg1 models.Jusgo, arg2 bool) (models.Jusgo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateJoke", arg0, arg1)
+	ret := m.ctrl.Call(m, "CreateJoke", arg0, arg1, arg2)
 	ret0, _ := ret[0].(models.Jusgo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateJoke indicates an expected call of CreateJoke.
-func (mr *MockServiceProviderMockRecorder) CreateJoke(arg0, arg1 any) *gomock.Call {
+func (mr *MockServiceProviderMockRecorder) CreateJoke(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateJoke", reflect.TypeOf((*MockServiceProvider)(nil).CreateJoke), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateJoke", reflect.TypeOf((*MockServiceProvider)(nil).CreateJoke), arg0, arg1, arg2)
+}
+
+// CreateJokes mocks base method.
+func (m *MockServiceProvider) CreateJokes(arg0 context.Context, arg1 []models.Jusgo) ([]models.Jusgo, []error, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateJokes", arg0, arg1)
+	ret0, _ := ret[0].([]models.Jusgo)
+	ret1, _ := ret[1].([]error)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateJokes indicates an expected call of CreateJokes.
+func (mr *MockServiceProviderMockRecorder) CreateJokes(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateJokes", reflect.TypeOf((*MockServiceProvider)(nil).CreateJokes), arg0, arg1)
 }
 
 // DeleteJoke mocks base method.
-func (m *MockServiceProvider) DeleteJoke(arg0 context.Context, arg1 string) error {
+func (m *MockServiceProvider) DeleteJoke(arg0 context.Context, arg1 string, arg2 int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteJoke", arg0, arg1)
+	ret := m.ctrl.Call(m, "DeleteJoke", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteJoke indicates an expected call of DeleteJoke.
-func (mr *MockServiceProviderMockRecorder) DeleteJoke(arg0, arg1 any) *gomock.Call {
+func (mr *MockServiceProviderMockRecorder) DeleteJoke(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteJoke", reflect.TypeOf((*MockServiceProvider)(nil).DeleteJoke), arg0, arg1, arg2)
+}
+
+// ExportJokes mocks base method.
+func (m *MockServiceProvider) ExportJokes(arg0 context.Context, arg1 func(models.Jusgo) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExportJokes", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExportJokes indicates an expected call of ExportJokes.
+func (mr *MockServiceProviderMockRecorder) ExportJokes(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExportJokes", reflect.TypeOf((*MockServiceProvider)(nil).ExportJokes), arg0, arg1)
+}
+
+// GetAPIKeys mocks base method.
+func (m *MockServiceProvider) GetAPIKeys(arg0 context.Context) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeys", arg0)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKeys indicates an expected call of GetAPIKeys.
+func (mr *MockServiceProviderMockRecorder) GetAPIKeys(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteJoke", reflect.TypeOf((*MockServiceProvider)(nil).DeleteJoke), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeys", reflect.TypeOf((*MockServiceProvider)(nil).GetAPIKeys), arg0)
 }
 
 // GetAllJokes mocks base method.
-func (m *MockServiceProvider) GetAllJokes(arg0 context.Context, arg1, arg2 int) ([]models.Jusgo, error) {
+func (m *MockServiceProvider) GetAllJokes(arg0 context.Context, arg1 models.JokeQuery) ([]models.Jusgo, int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllJokes", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "GetAllJokes", arg0, arg1)
 	ret0, _ := ret[0].([]models.Jusgo)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetAllJokes indicates an expected call of GetAllJokes.
+func (mr *MockServiceProviderMockRecorder) GetAllJokes(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllJokes", reflect.TypeOf((*MockServiceProvider)(nil).GetAllJokes), arg0, arg1)
+}
+
+// GetDailyJoke mocks base method.
+func (m *MockServiceProvider) GetDailyJoke(arg0 context.Context, arg1 time.Time) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDailyJoke", arg0, arg1)
+	ret0, _ := ret[0].(models.Jusgo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAllJokes indicates an expected call of GetAllJokes.
-func (mr *MockServiceProviderMockRecorder) GetAllJokes(arg0, arg1, arg2 any) *gomock.Call {
+// GetDailyJoke indicates an expected call of GetDailyJoke.
+func (mr *MockServiceProviderMockRecorder) GetDailyJoke(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllJokes", reflect.TypeOf((*MockServiceProvider)(nil).GetAllJokes), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDailyJoke", reflect.TypeOf((*MockServiceProvider)(nil).GetDailyJoke), arg0, arg1)
 }
 
 // GetJoke mocks base method.
@@ -99,17 +223,289 @@ func (mr *MockServiceProviderMockRecorder) GetJoke(arg0, arg1 any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJoke", reflect.TypeOf((*MockServiceProvider)(nil).GetJoke), arg0, arg1)
 }
 
+// GetJokeHistory mocks base method.
+func (m *MockServiceProvider) GetJokeHistory(arg0 context.Context, arg1 string, arg2, arg3 int) ([]models.Revision, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJokeHistory", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Revision)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetJokeHistory indicates an expected call of GetJokeHistory.
+func (mr *MockServiceProviderMockRecorder) GetJokeHistory(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJokeHistory", reflect.TypeOf((*MockServiceProvider)(nil).GetJokeHistory), arg0, arg1, arg2, arg3)
+}
+
+// GetJokesAfter mocks base method.
+func (m *MockServiceProvider) GetJokesAfter(arg0 context.Context, arg1 models.JokeFilter, arg2 string, arg3 int) ([]models.Jusgo, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJokesAfter", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Jusgo)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetJokesAfter indicates an expected call of GetJokesAfter.
+func (mr *MockServiceProviderMockRecorder) GetJokesAfter(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJokesAfter", reflect.TypeOf((*MockServiceProvider)(nil).GetJokesAfter), arg0, arg1, arg2, arg3)
+}
+
+// GetRandomJokes mocks base method.
+func (m *MockServiceProvider) GetRandomJokes(arg0 context.Context, arg1 models.JokeFilter, arg2 int, arg3 []string) ([]models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRandomJokes", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRandomJokes indicates an expected call of GetRandomJokes.
+func (mr *MockServiceProviderMockRecorder) GetRandomJokes(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRandomJokes", reflect.TypeOf((*MockServiceProvider)(nil).GetRandomJokes), arg0, arg1, arg2, arg3)
+}
+
+// GetSubmission mocks base method.
+func (m *MockServiceProvider) GetSubmission(arg0 context.Context, arg1 string) (models.Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubmission", arg0, arg1)
+	ret0, _ := ret[0].(models.Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubmission indicates an expected call of GetSubmission.
+func (mr *MockServiceProviderMockRecorder) GetSubmission(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubmission", reflect.TypeOf((*MockServiceProvider)(nil).GetSubmission), arg0, arg1)
+}
+
+// GetSubmissions mocks base method.
+func (m *MockServiceProvider) GetSubmissions(arg0 context.Context, arg1 string, arg2, arg3 int) ([]models.Submission, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubmissions", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.Submission)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetSubmissions indicates an expected call of GetSubmissions.
+func (mr *MockServiceProviderMockRecorder) GetSubmissions(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubmissions", reflect.TypeOf((*MockServiceProvider)(nil).GetSubmissions), arg0, arg1, arg2, arg3)
+}
+
+// GetTopJokes mocks base method.
+func (m *MockServiceProvider) GetTopJokes(arg0 context.Context, arg1 time.Time, arg2 int) ([]models.RankedJoke, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTopJokes", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.RankedJoke)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTopJokes indicates an expected call of GetTopJokes.
+func (mr *MockServiceProviderMockRecorder) GetTopJokes(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTopJokes", reflect.TypeOf((*MockServiceProvider)(nil).GetTopJokes), arg0, arg1, arg2)
+}
+
+// PatchJoke mocks base method.
+func (m *MockServiceProvider) PatchJoke(arg0 context.Context, arg1 string, arg2 func(*models.Jusgo) error, arg3 int64, arg4 bool) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchJoke", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PatchJoke indicates an expected call of PatchJoke.
+func (mr *MockServiceProviderMockRecorder) PatchJoke(arg0, arg1, arg2, arg3, arg4 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchJoke", reflect.TypeOf((*MockServiceProvider)(nil).PatchJoke), arg0, arg1, arg2, arg3, arg4)
+}
+
+// PurgeJoke mocks base method.
+func (m *MockServiceProvider) PurgeJoke(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeJoke", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeJoke indicates an expected call of PurgeJoke.
+func (mr *MockServiceProviderMockRecorder) PurgeJoke(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeJoke", reflect.TypeOf((*MockServiceProvider)(nil).PurgeJoke), arg0, arg1)
+}
+
+// PurgeTrash mocks base method.
+func (m *MockServiceProvider) PurgeTrash(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTrash", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTrash indicates an expected call of PurgeTrash.
+func (mr *MockServiceProviderMockRecorder) PurgeTrash(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTrash", reflect.TypeOf((*MockServiceProvider)(nil).PurgeTrash), arg0, arg1)
+}
+
+// RejectSubmission mocks base method.
+func (m *MockServiceProvider) RejectSubmission(arg0 context.Context, arg1, arg2 string) (models.Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectSubmission", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RejectSubmission indicates an expected call of RejectSubmission.
+func (mr *MockServiceProviderMockRecorder) RejectSubmission(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectSubmission", reflect.TypeOf((*MockServiceProvider)(nil).RejectSubmission), arg0, arg1, arg2)
+}
+
+// RestoreJoke mocks base method.
+func (m *MockServiceProvider) RestoreJoke(arg0 context.Context, arg1 string) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreJoke", arg0, arg1)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreJoke indicates an expected call of RestoreJoke.
+func (mr *MockServiceProviderMockRecorder) RestoreJoke(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreJoke", reflect.TypeOf((*MockServiceProvider)(nil).RestoreJoke), arg0, arg1)
+}
+
+// RevertJoke mocks base method.
+func (m *MockServiceProvider) RevertJoke(arg0 context.Context, arg1 string, arg2 int64, arg3 bool) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevertJoke", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevertJoke indicates an expected call of RevertJoke.
+func (mr *MockServiceProviderMockRecorder) RevertJoke(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevertJoke", reflect.TypeOf((*MockServiceProvider)(nil).RevertJoke), arg0, arg1, arg2, arg3)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockServiceProvider) RevokeAPIKey(arg0 context.Context, arg1 string) (models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockServiceProviderMockRecorder) RevokeAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockServiceProvider)(nil).RevokeAPIKey), arg0, arg1)
+}
+
+// SearchJokes mocks base method.
+func (m *MockServiceProvider) SearchJokes(arg0 context.Context, arg1 string, arg2, arg3 int) ([]models.SearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchJokes", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.SearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchJokes indicates an expected call of SearchJokes.
+func (mr *MockServiceProviderMockRecorder) SearchJokes(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchJokes", reflect.TypeOf((*MockServiceProvider)(nil).SearchJokes), arg0, arg1, arg2, arg3)
+}
+
+// SignJokes mocks base method.
+func (m *MockServiceProvider) SignJokes(arg0 context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SignJokes", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SignJokes indicates an expected call of SignJokes.
+func (mr *MockServiceProviderMockRecorder) SignJokes(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignJokes", reflect.TypeOf((*MockServiceProvider)(nil).SignJokes), arg0)
+}
+
+// SubmitJoke mocks base method.
+func (m *MockServiceProvider) SubmitJoke(arg0 context.Context, arg1 models.Submission) (models.Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubmitJoke", arg0, arg1)
+	ret0, _ := ret[0].(models.Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubmitJoke indicates an expected call of SubmitJoke.
+func (mr *MockServiceProviderMockRecorder) SubmitJoke(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitJoke", reflect.TypeOf((*MockServiceProvider)(nil).SubmitJoke), arg0, arg1)
+}
+
 // UpdateJoke mocks base method.
-func (m *MockServiceProvider) UpdateJoke(arg0 context.Context, arg1 models.Jusgo) (models.Jusgo, error) {
+func (m *MockServiceProvider) UpdateJoke(arg0 context.Context, arg1 models.Jusgo, arg2 int64, arg3 bool) (models.Jusgo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateJoke", arg0, arg1)
+	ret := m.ctrl.Call(m, "UpdateJoke", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].(models.Jusgo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UpdateJoke indicates an expected call of UpdateJoke.
-func (mr *MockServiceProviderMockRecorder) UpdateJoke(arg0, arg1 any) *gomock.Call {
+func (mr *MockServiceProviderMockRecorder) UpdateJoke(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateJoke", reflect.TypeOf((*MockServiceProvider)(nil).UpdateJoke), arg0, arg1, arg2, arg3)
+}
+
+// UpdateSubmission mocks base method.
+func (m *MockServiceProvider) UpdateSubmission(arg0 context.Context, arg1 string, arg2 models.Submission) (models.Submission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSubmission", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Submission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSubmission indicates an expected call of UpdateSubmission.
+func (mr *MockServiceProviderMockRecorder) UpdateSubmission(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSubmission", reflect.TypeOf((*MockServiceProvider)(nil).UpdateSubmission), arg0, arg1, arg2)
+}
+
+// Vote mocks base method.
+func (m *MockServiceProvider) Vote(arg0 context.Context, arg1, arg2 string, arg3 bool) (models.Jusgo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Vote", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(models.Jusgo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Vote indicates an expected call of Vote.
+func (mr *MockServiceProviderMockRecorder) Vote(arg0, arg1, arg2, arg3 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateJoke", reflect.TypeOf((*MockServiceProvider)(nil).UpdateJoke), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Vote", reflect.TypeOf((*MockServiceProvider)(nil).Vote), arg0, arg1, arg2, arg3)
 }
@@ -1,18 +1,371 @@
 package models
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"time"
-
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// JokeRequest is a single line joke, or a two part joke when Type is TypeTwoPart.
 type JokeRequest struct {
-	Joke string `json:"joke" validate:"required"`
+	Type     string   `json:"type" validate:"omitempty,oneof=single twopart"`
+	Joke     string   `json:"joke" validate:"required_unless=Type twopart,excluded_if=Type twopart"`
+	Setup    string   `json:"setup" validate:"required_if=Type twopart,excluded_unless=Type twopart"`
+	Delivery string   `json:"delivery" validate:"required_if=Type twopart,excluded_unless=Type twopart"`
+	Category string   `json:"category" validate:"omitempty,category"`
+	Tags     []string `json:"tags" validate:"max=5,dive,joketag"`
+}
+
+// JokePatch is a JSON merge patch (RFC 7396) of a joke. A field missing from the patch is nil and
+// keeps its value, a field set to null points to the zero value, which resets it to its default.
+type JokePatch struct {
+	Type     *string
+	Joke     *string
+	Setup    *string
+	Delivery *string
+	Category *string
+	Tags     *[]string
+}
+
+// UnmarshalJSON decodes a merge patch, patches of fields other than those of a JokeRequest are rejected.
+func (p *JokePatch) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if fields == nil {
+		return errors.New("patch must be a JSON object")
+	}
+
+	*p = JokePatch{}
+	for name, value := range fields {
+		var err error
+		switch name {
+		case "type":
+			p.Type, err = patchField[string](value)
+		case "joke":
+			p.Joke, err = patchField[string](value)
+		case "setup":
+			p.Setup, err = patchField[string](value)
+		case "delivery":
+			p.Delivery, err = patchField[string](value)
+		case "category":
+			p.Category, err = patchField[string](value)
+		case "tags":
+			p.Tags, err = patchField[[]string](value)
+		default:
+			return fmt.Errorf("field %q can't be patched", name)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func patchField[T any](value json.RawMessage) (*T, error) {
+	v := new(T)
+	if bytes.Equal(value, []byte("null")) {
+		return v, nil
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Apply sets the fields of joke the patch has.
+func (p JokePatch) Apply(joke *Jusgo) {
+	set := func(field *string, value *string) {
+		if value != nil {
+			*field = *value
+		}
+	}
+	set(&joke.Type, p.Type)
+	set(&joke.Joke, p.Joke)
+	set(&joke.Setup, p.Setup)
+	set(&joke.Delivery, p.Delivery)
+	set(&joke.Category, p.Category)
+	if p.Tags != nil {
+		joke.Tags = *p.Tags
+	}
 }
 
+// Jusgo is a stored joke. Like that of a submission, revision and API key, its ID is given to it by the
+// repository it is created in, see repository.NewID.
 type Jusgo struct {
-	ID        primitive.ObjectID `bson:"_id" json:"id"`
-	Joke      string             `bson:"joke" json:"joke"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID        string    `bson:"_id" json:"id"`
+	Type      string    `bson:"type" json:"type"`
+	Joke      string    `bson:"joke" json:"joke,omitempty"`
+	Setup     string    `bson:"setup,omitempty" json:"setup,omitempty"`
+	Delivery  string    `bson:"delivery,omitempty" json:"delivery,omitempty"`
+	Category  string    `bson:"category" json:"category,omitempty"`
+	Tags      []string  `bson:"tags" json:"tags,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+	// Normalized is the text of the joke as dedupe.Normalize returns it. It is unique among stored jokes
+	// unless empty, jokes stored before it existed don't have it until they are updated.
+	Normalized string `bson:"normalized,omitempty" json:"-"`
+	// Signature holds the dedupe.Signature buckets of Normalized, jokes sharing one with another are
+	// compared to it for near duplicates. Jokes stored before it existed get it in the background, see
+	// JokeFilter.Unsigned.
+	Signature []string `bson:"signature,omitempty" json:"-"`
+	// Upvotes, Downvotes and Rating, the rating.Wilson of the two, only change through votes.
+	// Jokes stored before votes existed don't have them until they are voted on.
+	Upvotes   int64   `bson:"upvotes,omitempty" json:"upvotes"`
+	Downvotes int64   `bson:"downvotes,omitempty" json:"downvotes"`
+	Rating    float64 `bson:"rating,omitempty" json:"rating"`
+	// DeletedAt is set while the joke is in the trash. Jokes in the trash are left out everywhere
+	// but the trash itself and lose their Normalized text so they don't block new jokes.
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	// Version goes up by one with every update, delete and restore, but not with votes. Updates and
+	// deletes only go through if the joke is still at the version the caller saw, see repository.ErrVersionConflict.
+	// Jokes stored before versions existed are at version 0.
+	Version int64 `bson:"version,omitempty" json:"version"`
+}
+
+// Vote is an up or down vote on a joke. A client gets one vote per joke in every window
+// of VoteWindow, Window is the start of the window the vote was cast in.
+type Vote struct {
+	JokeID    string    `bson:"joke_id"`
+	Client    string    `bson:"client"` // a hash of whoever voted
+	Up        bool      `bson:"up"`
+	Window    time.Time `bson:"window"`
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+// VoteWindow is how often a client can vote on the same joke.
+const VoteWindow = 24 * time.Hour
+
+// VoteTotal is the number of votes a joke got in some period.
+type VoteTotal struct {
+	JokeID    string `bson:"_id"`
+	Upvotes   int64  `bson:"upvotes"`
+	Downvotes int64  `bson:"downvotes"`
+}
+
+// RankedJoke is a joke in a top rated listing, Score is its rating.Wilson for the listed period.
+type RankedJoke struct {
+	Jusgo `bson:",inline"`
+	Score float64 `bson:"score" json:"score"`
+}
+
+// SearchResult is a joke found by a search, the better it matched the higher its Score.
+type SearchResult struct {
+	Jusgo     `bson:",inline"`
+	Score     float64 `bson:"score" json:"score"`
+	Highlight string  `bson:"-" json:"highlight"` // the joke as HTML, with the matched words in <mark> tags
+}
+
+// Text returns the whole joke, for two part jokes the setup followed by the delivery.
+func (j Jusgo) Text() string {
+	if j.Type == TypeTwoPart {
+		return j.Setup + " " + j.Delivery
+	}
+	return j.Joke
+}
+
+// Content returns the part of the joke revisions keep.
+func (j Jusgo) Content() *JokeContent {
+	return &JokeContent{
+		Type:     j.Type,
+		Joke:     j.Joke,
+		Setup:    j.Setup,
+		Delivery: j.Delivery,
+		Category: j.Category,
+		Tags:     slices.Clone(j.Tags),
+	}
+}
+
+// SetContent replaces the text, category and tags of the joke with c.
+func (j *Jusgo) SetContent(c JokeContent) {
+	j.Type = c.Type
+	j.Joke = c.Joke
+	j.Setup = c.Setup
+	j.Delivery = c.Delivery
+	j.Category = c.Category
+	j.Tags = slices.Clone(c.Tags)
+}
+
+// Request returns the joke as the JokeRequest that would create it, to validate it.
+func (j Jusgo) Request() JokeRequest {
+	return JokeRequest{Type: j.Type, Joke: j.Joke, Setup: j.Setup, Delivery: j.Delivery, Category: j.Category, Tags: j.Tags}
+}
+
+const (
+	// TypeSingle jokes are a single line in Joke. Jokes stored before types existed are single jokes.
+	TypeSingle = "single"
+	// TypeTwoPart jokes have a Setup and a Delivery (the punchline).
+	TypeTwoPart = "twopart"
+)
+
+// JokeFilter narrows down which jokes are listed. Empty fields match every joke.
+type JokeFilter struct {
+	Type         string   // TypeSingle or TypeTwoPart
+	Categories   []string // jokes in any of these categories
+	Tags         []string
+	MatchAllTags bool // jokes need every tag instead of at least one
+
+	CreatedAfter  time.Time // jokes created after this time, exclusive
+	CreatedBefore time.Time // jokes created before this time, exclusive
+	UpdatedSince  time.Time // jokes updated at or after this time
+
+	Deleted  bool // jokes in the trash instead of the live ones
+	Unsigned bool // jokes without a Signature
+}
+
+// Fields jokes can be sorted by.
+const (
+	SortCreatedAt = "created_at"
+	SortUpdatedAt = "updated_at"
+	SortRating    = "rating"
+	SortDeletedAt = "deleted_at" // only for jokes in the trash
+)
+
+// JokeSort is the order jokes are listed in. The zero value lists them by ID, which is
+// the order they were created in. Jokes with equal Field values are ordered by ID.
+type JokeSort struct {
+	Field      string // SortCreatedAt, SortUpdatedAt or SortRating
+	Descending bool
+}
+
+// JokeQuery is a page of a joke listing.
+type JokeQuery struct {
+	Filter JokeFilter
+	Sort   JokeSort
+	Page   int // starts at 1
+	Limit  int
+}
+
+// Statuses of a Submission.
+const (
+	SubmissionPending  = "pending"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+// Submission is a joke sent in by anyone through POST /submissions. It waits as SubmissionPending
+// until an admin approves it, which stores it as a joke, or rejects it. It only has the fields a
+// JokeRequest sets, votes and the like belong to the joke it becomes.
+type Submission struct {
+	ID        string    `bson:"_id" json:"id"`
+	Type      string    `bson:"type" json:"type"`
+	Joke      string    `bson:"joke" json:"joke,omitempty"`
+	Setup     string    `bson:"setup,omitempty" json:"setup,omitempty"`
+	Delivery  string    `bson:"delivery,omitempty" json:"delivery,omitempty"`
+	Category  string    `bson:"category" json:"category,omitempty"`
+	Tags      []string  `bson:"tags" json:"tags,omitempty"`
+	Status    string    `bson:"status" json:"status"`
+	Reason    string    `bson:"reason,omitempty" json:"reason,omitempty"`   // why it was rejected
+	JokeID    string    `bson:"joke_id,omitempty" json:"joke_id,omitempty"` // the joke it was approved as
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Actions a Revision records.
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"  // moved to the trash
+	RevisionRestore = "restore" // taken out of the trash
+	RevisionRevert  = "revert"
+	RevisionPurge   = "purge" // removed from the trash for good
+)
+
+// JokeContent is the part of a joke that is edited, the text revisions keep a copy of.
+type JokeContent struct {
+	Type     string   `bson:"type" json:"type"`
+	Joke     string   `bson:"joke,omitempty" json:"joke,omitempty"`
+	Setup    string   `bson:"setup,omitempty" json:"setup,omitempty"`
+	Delivery string   `bson:"delivery,omitempty" json:"delivery,omitempty"`
+	Category string   `bson:"category,omitempty" json:"category,omitempty"`
+	Tags     []string `bson:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// Revision is an immutable record of a change to a joke. Revisions of a joke are numbered from 1
+// in the order they were made and outlive the joke when it is purged from the trash.
+type Revision struct {
+	ID        string       `bson:"_id" json:"id"`
+	JokeID    string       `bson:"joke_id" json:"joke_id"`
+	Number    int64        `bson:"number" json:"rev"`
+	Action    string       `bson:"action" json:"action"`
+	Old       *JokeContent `bson:"old,omitempty" json:"old,omitempty"`           // nil when the joke was created or restored
+	New       *JokeContent `bson:"new,omitempty" json:"new,omitempty"`           // nil when the joke was deleted or purged
+	Reverted  int64        `bson:"reverted,omitempty" json:"reverted,omitempty"` // the revision a RevisionRevert went back to
+	Actor     string       `bson:"actor" json:"actor"`
+	RequestID string       `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	CreatedAt time.Time    `bson:"created_at" json:"created_at"`
+}
+
+// DefaultCategory is used for jokes created without a category.
+const DefaultCategory = "general"
+
+// Categories are the categories a joke can be filed under.
+var Categories = []string{
+	DefaultCategory, "git", "javascript", "python", "go", "java", "web", "database", "devops", "career",
+}
+
+// Tags are the tags a joke can be labelled with.
+var Tags = []string{
+	"pun", "one-liner", "recursion", "bugs", "testing", "naming", "regex", "frontend",
+	"backend", "security", "ai", "hardware", "meetings", "coffee",
+}
+
+// IsCategory reports whether c is one of Categories.
+func IsCategory(c string) bool {
+	return slices.Contains(Categories, c)
+}
+
+// IsTag reports whether t is one of Tags.
+func IsTag(t string) bool {
+	return slices.Contains(Tags, t)
+}
+
+// Scopes an APIKey can have, each allows a group of admin endpoints.
+const (
+	ScopeJokesWrite          = "jokes:write"          // add, edit, restore and revert jokes, export them and see their history
+	ScopeJokesDelete         = "jokes:delete"         // move jokes to the trash, list the trash and purge it
+	ScopeSubmissionsModerate = "submissions:moderate" // list, edit, approve and reject submissions
+	ScopeKeysManage          = "keys:manage"          // create, list and revoke API keys
+)
+
+// Scopes are all the scopes an APIKey can have.
+var Scopes = []string{ScopeJokesWrite, ScopeJokesDelete, ScopeSubmissionsModerate, ScopeKeysManage}
+
+// IsScope reports whether s is one of Scopes.
+func IsScope(s string) bool {
+	return slices.Contains(Scopes, s)
+}
+
+// APIKeyRequest asks for a new API key for Name. Without ExpiresAt the key works until it is revoked.
+type APIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,scope"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKey lets whoever holds its secret use the admin endpoints its Scopes allow. The secret is only
+// shown when the key is created, just its SHA-256 Hash is stored, and Prefix, its start, to tell keys apart.
+type APIKey struct {
+	ID        string     `bson:"_id" json:"id"`
+	Name      string     `bson:"name" json:"name"`
+	Prefix    string     `bson:"prefix" json:"prefix"`
+	Hash      string     `bson:"hash" json:"-"`
+	Scopes    []string   `bson:"scopes" json:"scopes"`
+	CreatedAt time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	RevokedAt *time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the key allows scope.
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// Active reports whether the key works at now, it must be neither revoked nor expired.
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
 }
@@ -0,0 +1,21 @@
+// Package rating ranks jokes by their votes. A joke's rating is the lower bound of the Wilson score
+// interval of its share of upvotes, so a joke with 90 of 100 votes up outranks one with its only vote up.
+package rating
+
+import "math"
+
+// z is the quantile of the standard normal distribution for 95% confidence.
+const z = 1.96
+
+// Wilson returns the lower bound of the Wilson score interval for up out of up+down votes,
+// from 0 for no votes to just below 1.
+func Wilson(up, down int64) float64 {
+	if up <= 0 { // the bound is 0, but rounding may leave a speck above it
+		return 0
+	}
+
+	n := float64(up + down)
+
+	p := float64(up) / n
+	return (p + z*z/(2*n) - z*math.Sqrt((p*(1-p)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
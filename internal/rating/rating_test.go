@@ -0,0 +1,21 @@
+package rating
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWilson(t *testing.T) {
+	require.Zero(t, Wilson(0, 0))
+	require.Zero(t, Wilson(0, 10))
+	require.Zero(t, Wilson(0, 1))
+	require.InDelta(t, 0.2065, Wilson(1, 0), 0.0001)
+	require.InDelta(t, 0.8256, Wilson(90, 10), 0.0001)
+
+	// more votes make for more confidence in the same share
+	require.Greater(t, Wilson(90, 10), Wilson(9, 1))
+	// a single upvote isn't worth more than a well liked joke
+	require.Greater(t, Wilson(8, 2), Wilson(1, 0))
+	require.Less(t, Wilson(1000, 0), 1.0)
+}
@@ -2,26 +2,269 @@ package repository
 
 import (
 	"context"
- 
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
 	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/search"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+var (
+	// ErrNotFound is returned when there is no joke, submission, revision or API key to read or change.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicateKey is returned by Create when a joke with the same ID already exists.
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrInvalidID is returned when an ID is not a hex encoded ObjectID.
+	ErrInvalidID = errors.New("invalid id")
+	// ErrVersionConflict is returned by Update and Delete when the joke was changed since the
+	// version they were given, see models.Jusgo.Version.
+	ErrVersionConflict = errors.New("joke was changed in the meantime")
+	// ErrNegativeSkip is returned by the paged listings, like GetAll, for a negative skip.
+	ErrNegativeSkip = errors.New("skip must not be negative")
+	// ErrNotPending is returned by UpdateSubmission when the submission was already approved or rejected.
+	ErrNotPending = errors.New("submission was already approved or rejected")
+)
+
 type RepositoryProvider interface {
+	// RunInTransaction calls fn with a context that makes the repository calls fn makes with it one
+	// transaction. The transaction is committed when fn returns nil and rolled back otherwise, fn may be
+	// called again when the database asks for a retry. Calls nested in fn join the outer transaction.
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+
+	// Create stores data and returns it with its ID, see NewID.
 	Create(ctx context.Context, data models.Jusgo) (models.Jusgo, error)
-	Get(ctx context.Context, id primitive.ObjectID) (models.Jusgo, error)
+	// CreateMany stores data in one go. It doesn't stop at the first joke that fails, errs[i] holds the
+	// error for data[i] or nil if it was stored. err is only set when the batch as a whole failed.
+	// Jokes without an ID are given one in data itself.
+	CreateMany(ctx context.Context, data []models.Jusgo) (errs []error, err error)
+	Get(ctx context.Context, id string) (models.Jusgo, error)
+	// GetByNormalized returns the live joke with the normalized text, see models.Jusgo.Normalized.
+	GetByNormalized(ctx context.Context, normalized string) (models.Jusgo, error)
+	// Update replaces the joke with data if it is still at data.Version, and returns the stored joke at the next version.
+	// Its ID, CreatedAt and votes stay as they are. It returns ErrVersionConflict if the joke is at another version
+	// and ErrNotFound if there is no such joke or it is in the trash.
 	Update(ctx context.Context, data models.Jusgo) (models.Jusgo, error)
-	Delete(ctx context.Context, id primitive.ObjectID) error
-	GetAll(ctx context.Context, skip, limit int64) ([]models.Jusgo, error)
+	// Delete moves the joke with id to the trash if it is still at version, see models.Jusgo.DeletedAt.
+	// Only GetAll and Count with JokeFilter.Deleted, GetDeleted, Restore and the purge methods see it from
+	// then on. Like Update, it returns ErrVersionConflict for a joke at another version.
+	Delete(ctx context.Context, id string, version int64) error
+	// GetDeleted returns the joke with id from the trash.
+	GetDeleted(ctx context.Context, id string) (models.Jusgo, error)
+	// Restore takes the joke with id out of the trash, giving it back its normalized text, and moves it to the next version.
+	// It returns ErrDuplicateKey if a live joke has the same normalized text by now.
+	Restore(ctx context.Context, id, normalized string) (models.Jusgo, error)
+	// Purge removes the joke with id from the trash for good.
+	Purge(ctx context.Context, id string) error
+	// PurgeDeletedBefore removes up to limit of the jokes that were moved to the trash before before
+	// for good, those with the lowest IDs, and returns them.
+	PurgeDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]models.Jusgo, error)
+	// GetAll returns a page of the jokes that match filter in the given order.
+	GetAll(ctx context.Context, filter models.JokeFilter, sort models.JokeSort, skip, limit int64) ([]models.Jusgo, error)
+	// GetAfter returns up to limit jokes that match filter with an ID greater than after, ordered by ID.
+	// An empty after starts at the first joke. Unlike GetAll's skip, it costs the same however deep it pages.
+	GetAfter(ctx context.Context, filter models.JokeFilter, after string, limit int64) ([]models.Jusgo, error)
+	// Count returns the number of stored jokes that match filter.
+	Count(ctx context.Context, filter models.JokeFilter) (int64, error)
+	// Search returns the jokes matching a full text query, best matches first.
+	// See the search package for the query syntax.
+	Search(ctx context.Context, query string, skip, limit int64) ([]models.SearchResult, error)
+	// GetSimilar returns up to limit live jokes whose Signature shares a bucket with signature, in no particular order.
+	GetSimilar(ctx context.Context, signature []string, limit int64) ([]models.Jusgo, error)
+	// SetSignature replaces the Signature of the joke with id, in the trash or not. Neither its version nor
+	// UpdatedAt change, it is how jokes stored before signatures existed get one.
+	SetSignature(ctx context.Context, id string, signature []string) error
+	// GetRandom returns up to count distinct jokes that match filter picked at random, leaving out the IDs in exclude.
+	GetRandom(ctx context.Context, filter models.JokeFilter, count int64, exclude []string) ([]models.Jusgo, error)
+
+	// Submissions are kept apart from jokes, none of the methods above return them.
+	// CreateSubmission stores data and returns it with its ID, like Create.
+	CreateSubmission(ctx context.Context, data models.Submission) (models.Submission, error)
+	GetSubmission(ctx context.Context, id string) (models.Submission, error)
+	// UpdateSubmission replaces the submission with data if it is still pending. It returns ErrNotPending if it was
+	// approved or rejected already, even by a transaction that ran at the same time, and ErrNotFound if there is no such submission.
+	UpdateSubmission(ctx context.Context, data models.Submission) (models.Submission, error)
+	// GetSubmissions returns a page of the submissions with the given status, oldest first.
+	// An empty status returns submissions of any status.
+	GetSubmissions(ctx context.Context, status string, skip, limit int64) ([]models.Submission, error)
+	CountSubmissions(ctx context.Context, status string) (int64, error)
+
+	// AddVote stores vote, or returns ErrDuplicateKey if its client already voted on the joke in the same window.
+	AddVote(ctx context.Context, vote models.Vote) error
+	// IncrementVotes atomically adds up and down to the votes of the joke with id and returns the joke
+	// with its new votes and rating, see rating.Wilson. Votes are only ever changed through it, Update leaves them be.
+	IncrementVotes(ctx context.Context, id string, up, down int64) (models.Jusgo, error)
+	// GetVoteTotals sums up the votes cast at or after since by joke.
+	GetVoteTotals(ctx context.Context, since time.Time) ([]models.VoteTotal, error)
+
+	// AddRevision stores rev as the next revision of its joke and returns it with its ID and Number set.
+	AddRevision(ctx context.Context, rev models.Revision) (models.Revision, error)
+	// GetRevision returns revision number of the joke with jokeID.
+	GetRevision(ctx context.Context, jokeID string, number int64) (models.Revision, error)
+	// GetRevisions returns a page of the revisions of the joke with jokeID, oldest first.
+	GetRevisions(ctx context.Context, jokeID string, skip, limit int64) ([]models.Revision, error)
+	CountRevisions(ctx context.Context, jokeID string) (int64, error)
+
+	// CreateAPIKey stores key and returns it with its ID, or returns ErrDuplicateKey if a key with the same Hash exists.
+	CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error)
+	// GetAPIKeyByHash returns the key whose secret hashes to hash, revoked and expired keys too.
+	GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	// GetAPIKeys returns every key, oldest first.
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	// RevokeAPIKey sets the RevokedAt of the key with id to at, unless it is revoked already, and returns the key.
+	RevokeAPIKey(ctx context.Context, id string, at time.Time) (models.APIKey, error)
 }
 
 type Repository struct {
 	Repo RepositoryProvider
 }
 
-func NewRepository(collection *mongo.Collection) *Repository {
+// NewRepository returns a repository that stores jokes, submissions, votes, revisions and API keys in their own
+// mongo collections. Transactions need MongoDB to run as a replica set.
+func NewRepository(collection, submissions, votes, revisions, apiKeys *mongo.Collection) *Repository {
+	return &Repository{
+		Repo: newRepositoryImpl(collection, submissions, votes, revisions, apiKeys),
+	}
+}
+
+// NewMemoryRepository returns a repository that keeps jokes in memory. Nothing is persisted
+// across restarts, it is meant for tests and running the server without a database.
+func NewMemoryRepository() *Repository {
+	return &Repository{
+		Repo: newMemoryRepositoryImpl(),
+	}
+}
+
+// NewSQLiteRepository returns a repository backed by a SQLite database.
+// The schema must already be migrated, database.ConnectToSQLite takes care of that.
+func NewSQLiteRepository(db *sql.DB) *Repository {
+	return &Repository{
+		Repo: newSQLRepositoryImpl(db, sqliteDialect),
+	}
+}
+
+// NewPostgresRepository returns a repository backed by a PostgreSQL database.
+// The schema must already be migrated, database.ConnectToPostgres takes care of that.
+func NewPostgresRepository(db *sql.DB) *Repository {
+	return &Repository{
+		Repo: newSQLRepositoryImpl(db, postgresDialect),
+	}
+}
+
+// NewCachedRepository returns repo with Get answered from cache, where jokes are kept for up to ttl.
+// Changes made through the returned repository drop the jokes they change from the cache.
+func NewCachedRepository(repo *Repository, cache Cache, ttl time.Duration) *Repository {
 	return &Repository{
-		Repo: newRepositoryImpl(collection),
+		Repo: newCachedRepositoryImpl(repo.Repo, cache, ttl),
+	}
+}
+
+// CacheStats returns the cache hits and misses of a repository made by NewCachedRepository,
+// ok is false for other repositories.
+func (r *Repository) CacheStats() (stats CacheStats, ok bool) {
+	cached, ok := r.Repo.(*cachedRepositoryImpl)
+	if !ok {
+		return CacheStats{}, false
+	}
+	return cached.CacheStats(), true
+}
+
+// NewID returns a new ID. IDs are hex encoded ObjectIDs in every backend, so they sort in the order
+// they were made and jokes keep theirs when they are exported from one backend and imported into another.
+// The Create methods give what they store one unless it has an ID already.
+func NewID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+// assignID returns checkID(id), or a new ID if id is empty.
+func assignID(id string) (string, error) {
+	if id == "" {
+		return NewID(), nil
+	}
+	return checkID(id)
+}
+
+// checkID returns id in the lower case hex IDs are stored in, or ErrInvalidID if it is not a hex encoded ObjectID.
+func checkID(id string) (string, error) {
+	objectID, err := parseID(id)
+	if err != nil {
+		return id, err
+	}
+	return objectID.Hex(), nil
+}
+
+// checkIDs is checkID for every ID in ids.
+func checkIDs(ids []string) ([]string, error) {
+	checked := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id, err := checkID(id)
+		if err != nil {
+			return nil, err
+		}
+		checked = append(checked, id)
+	}
+	return checked, nil
+}
+
+// parseID converts a hex encoded ID to the ObjectID jokes are keyed by.
+func parseID(id string) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return objectID, ErrInvalidID
+	}
+	return objectID, nil
+}
+
+// parseIDs converts every ID in ids, see parseID.
+func parseIDs(ids []string) ([]primitive.ObjectID, error) {
+	objectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objectID, err := parseID(id)
+		if err != nil {
+			return nil, err
+		}
+		objectIDs = append(objectIDs, objectID)
+	}
+	return objectIDs, nil
+}
+
+// withDefaults fills in the fields jokes stored by older versions don't have.
+func withDefaults(joke models.Jusgo) models.Jusgo {
+	if joke.Type == "" {
+		joke.Type = models.TypeSingle
+	}
+	if joke.Category == "" {
+		joke.Category = models.DefaultCategory
+	}
+	return joke
+}
+
+// submissionWithDefaults is withDefaults for a submission.
+func submissionWithDefaults(submission models.Submission) models.Submission {
+	if submission.Type == "" {
+		submission.Type = models.TypeSingle
 	}
+	if submission.Category == "" {
+		submission.Category = models.DefaultCategory
+	}
+	return submission
+}
+
+// rankSearchResults scores jokes against query for the backends without full text search of their own.
+// Jokes that don't match are dropped, the rest are sorted best match first.
+func rankSearchResults(jokes []models.Jusgo, query search.Query) []models.SearchResult {
+	results := []models.SearchResult{}
+	for _, joke := range jokes {
+		if score := query.Score(joke.Text()); score > 0 {
+			results = append(results, models.SearchResult{Jusgo: joke, Score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
 }
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache stores encoded jokes for a cached repository. NewLRUCache returns one that lives in the process,
+// a cache shared by several instances, like Redis or memcached, only has to implement the three methods.
+type Cache interface {
+	// Get returns the value stored under key, ok is false if there is none or it expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key until ttl has passed.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the value stored under key, if any.
+	Delete(ctx context.Context, key string) error
+}
+
+// CacheStats counts how the Get calls of a cached repository went.
+type CacheStats struct {
+	Hits   int64 // answered from the cache
+	Misses int64 // passed on to the wrapped repository
+}
+
+// cachedRepositoryImpl answers Get from a Cache and passes everything else on to the repository it wraps.
+// A joke is dropped from the cache when it is changed through the cached repository, so changes made around
+// it, by another instance with an in-process cache for example, show up once the ttl passed.
+type cachedRepositoryImpl struct {
+	RepositoryProvider
+
+	cache Cache
+	ttl   time.Duration
+	loads singleflight.Group
+
+	// generation goes up with every invalidation, a load that saw it change doesn't cache what it read
+	generation   atomic.Uint64
+	hits, misses atomic.Int64
+}
+
+func newCachedRepositoryImpl(repo RepositoryProvider, cache Cache, ttl time.Duration) *cachedRepositoryImpl {
+	return &cachedRepositoryImpl{RepositoryProvider: repo, cache: cache, ttl: ttl}
+}
+
+// cacheTxKey is the context key of the cacheTx of the transaction a context is part of.
+type cacheTxKey struct{}
+
+// cacheTx collects the jokes a transaction invalidated, which are invalidated again once it is over.
+// Until then, a reader outside of the transaction can still load and cache the version before it.
+type cacheTx struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (r *cachedRepositoryImpl) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(cacheTxKey{}) != nil {
+		return r.RepositoryProvider.RunInTransaction(ctx, fn)
+	}
+
+	tx := &cacheTx{}
+	err := r.RepositoryProvider.RunInTransaction(ctx, func(ctx context.Context) error {
+		return fn(context.WithValue(ctx, cacheTxKey{}, tx))
+	})
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	for _, key := range tx.keys {
+		r.invalidate(context.WithoutCancel(ctx), key)
+	}
+	return err
+}
+
+// Get reads through the cache, concurrent misses of the same joke share one read of the wrapped repository.
+// Reads in a transaction skip the cache, they may see changes that are not committed yet.
+func (r *cachedRepositoryImpl) Get(ctx context.Context, id string) (models.Jusgo, error) {
+	key, ok := jokeCacheKey(id)
+	if !ok || ctx.Value(cacheTxKey{}) != nil {
+		return r.RepositoryProvider.Get(ctx, id)
+	}
+
+	// a broken cache is a miss, not an error
+	if value, ok, err := r.cache.Get(ctx, key); err == nil && ok {
+		if joke, err := decodeCachedJoke(value); err == nil {
+			r.hits.Add(1)
+			return joke, nil
+		}
+	}
+	r.misses.Add(1)
+
+	generation := r.generation.Load()
+	// the read is shared, so it must not stop when the context of the caller that started it is canceled
+	loadCtx := context.WithoutCancel(ctx)
+	loaded := r.loads.DoChan(key, func() (any, error) {
+		joke, err := r.RepositoryProvider.Get(loadCtx, id)
+		if err != nil {
+			return joke, err
+		}
+		r.fill(loadCtx, key, joke, generation)
+		return joke, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return models.Jusgo{}, ctx.Err()
+	case result := <-loaded:
+		joke := result.Val.(models.Jusgo)
+		joke.Tags = slices.Clone(joke.Tags) // the result is shared with the other callers
+		return joke, result.Err
+	}
+}
+
+// fill caches joke unless it was invalidated since generation, in which case it may be outdated already.
+func (r *cachedRepositoryImpl) fill(ctx context.Context, key string, joke models.Jusgo, generation uint64) {
+	if r.generation.Load() != generation {
+		return
+	}
+	value, err := encodeCachedJoke(joke)
+	if err != nil {
+		return
+	}
+	if err := r.cache.Set(ctx, key, value, r.ttl); err != nil {
+		return
+	}
+	// an invalidation between the check above and Set may have missed the value, take it back out
+	if r.generation.Load() != generation {
+		r.cache.Delete(ctx, key)
+	}
+}
+
+// invalidate drops the joke with key from the cache. Failing to do so leaves it outdated until the ttl
+// passed, which is no reason to fail the change that was already made.
+func (r *cachedRepositoryImpl) invalidate(ctx context.Context, key string) {
+	r.generation.Add(1)
+	r.cache.Delete(ctx, key)
+	if tx, ok := ctx.Value(cacheTxKey{}).(*cacheTx); ok {
+		tx.mu.Lock()
+		tx.keys = append(tx.keys, key)
+		tx.mu.Unlock()
+	}
+}
+
+func (r *cachedRepositoryImpl) invalidateID(ctx context.Context, id string) {
+	if key, ok := jokeCacheKey(id); ok {
+		r.invalidate(ctx, key)
+	}
+}
+
+func (r *cachedRepositoryImpl) Update(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
+	defer r.invalidateID(ctx, data.ID)
+	return r.RepositoryProvider.Update(ctx, data)
+}
+
+func (r *cachedRepositoryImpl) Delete(ctx context.Context, id string, version int64) error {
+	defer r.invalidateID(ctx, id)
+	return r.RepositoryProvider.Delete(ctx, id, version)
+}
+
+func (r *cachedRepositoryImpl) Restore(ctx context.Context, id, normalized string) (models.Jusgo, error) {
+	defer r.invalidateID(ctx, id)
+	return r.RepositoryProvider.Restore(ctx, id, normalized)
+}
+
+func (r *cachedRepositoryImpl) Purge(ctx context.Context, id string) error {
+	defer r.invalidateID(ctx, id)
+	return r.RepositoryProvider.Purge(ctx, id)
+}
+
+func (r *cachedRepositoryImpl) SetSignature(ctx context.Context, id string, signature []string) error {
+	defer r.invalidateID(ctx, id)
+	return r.RepositoryProvider.SetSignature(ctx, id, signature)
+}
+
+func (r *cachedRepositoryImpl) IncrementVotes(ctx context.Context, id string, up, down int64) (models.Jusgo, error) {
+	defer r.invalidateID(ctx, id)
+	return r.RepositoryProvider.IncrementVotes(ctx, id, up, down)
+}
+
+func (r *cachedRepositoryImpl) CacheStats() CacheStats {
+	return CacheStats{Hits: r.hits.Load(), Misses: r.misses.Load()}
+}
+
+// jokeCacheKey returns the cache key of the joke with id, ok is false for an invalid id.
+func jokeCacheKey(id string) (string, bool) {
+	objectID, err := parseID(id)
+	if err != nil {
+		return "", false
+	}
+	return "joke:" + objectID.Hex(), true
+}
+
+// Cached jokes are gob encoded, unlike JSON it keeps every field and BSON would round the times to milliseconds.
+func encodeCachedJoke(joke models.Jusgo) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(joke)
+	return buf.Bytes(), err
+}
+
+func decodeCachedJoke(value []byte) (models.Jusgo, error) {
+	var joke models.Jusgo
+	err := gob.NewDecoder(bytes.NewReader(value)).Decode(&joke)
+	return joke, err
+}
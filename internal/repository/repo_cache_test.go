@@ -0,0 +1,159 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/repository/repositorytest"
+)
+
+func TestCachedConformance(t *testing.T) {
+	repositorytest.RunConformance(t, func(t *testing.T) repository.RepositoryProvider {
+		return repository.NewCachedRepository(repository.NewMemoryRepository(), repository.NewLRUCache(100), time.Minute).Repo
+	})
+}
+
+func TestCachedRepository(t *testing.T) {
+	ctx := context.Background()
+	memory := repository.NewMemoryRepository()
+	repo := repository.NewCachedRepository(memory, repository.NewLRUCache(100), time.Minute)
+	joke := repositorytest.CreateJoke(t, ctx, repo.Repo)
+
+	requireStats := func(hits, misses int64) {
+		t.Helper()
+		stats, ok := repo.CacheStats()
+		require.True(t, ok)
+		require.Equal(t, repository.CacheStats{Hits: hits, Misses: misses}, stats)
+	}
+
+	for range 3 {
+		cached, err := repo.Repo.Get(ctx, joke.ID)
+		require.NoError(t, err)
+		repositorytest.RequireJokeEqual(t, joke, cached)
+	}
+	requireStats(2, 1)
+
+	// changes drop the joke from the cache
+	joke.Joke = "updated"
+	joke, err := repo.Repo.Update(ctx, joke)
+	require.NoError(t, err)
+	cached, err := repo.Repo.Get(ctx, joke.ID)
+	require.NoError(t, err)
+	require.Equal(t, "updated", cached.Joke)
+
+	voted, err := repo.Repo.IncrementVotes(ctx, joke.ID, 1, 0)
+	require.NoError(t, err)
+	cached, err = repo.Repo.Get(ctx, joke.ID)
+	require.NoError(t, err)
+	require.Equal(t, voted.Upvotes, cached.Upvotes)
+	requireStats(2, 3)
+
+	// as do changes in a transaction, even when it is rolled back
+	errFailed := errors.New("failed")
+	err = repo.Repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		joke.Joke = "rolled back"
+		if _, err := repo.Repo.Update(ctx, joke); err != nil {
+			return err
+		}
+		inTx, err := repo.Repo.Get(ctx, joke.ID)
+		require.NoError(t, err)
+		require.Equal(t, "rolled back", inTx.Joke)
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+	cached, err = repo.Repo.Get(ctx, joke.ID)
+	require.NoError(t, err)
+	require.Equal(t, "updated", cached.Joke)
+
+	require.NoError(t, repo.Repo.Delete(ctx, joke.ID, cached.Version))
+	_, err = repo.Repo.Get(ctx, joke.ID)
+	require.Error(t, err)
+
+	_, ok := memory.CacheStats()
+	require.False(t, ok)
+}
+
+// slowRepository counts the calls to Get, which wait until release is closed.
+type slowRepository struct {
+	repository.RepositoryProvider
+	gets    atomic.Int32
+	release chan struct{}
+}
+
+func (r *slowRepository) Get(ctx context.Context, id string) (models.Jusgo, error) {
+	r.gets.Add(1)
+	<-r.release
+	return r.RepositoryProvider.Get(ctx, id)
+}
+
+func TestCachedRepositoryCollapsesMisses(t *testing.T) {
+	ctx := context.Background()
+	slow := &slowRepository{RepositoryProvider: repository.NewMemoryRepository().Repo, release: make(chan struct{})}
+	repo := repository.NewCachedRepository(&repository.Repository{Repo: slow}, repository.NewLRUCache(100), time.Minute)
+	joke := repositorytest.CreateJoke(t, ctx, slow)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cached, err := repo.Repo.Get(ctx, joke.ID)
+			require.NoError(t, err)
+			require.Equal(t, joke.ID, cached.ID)
+		}()
+	}
+	require.Eventually(t, func() bool {
+		stats, _ := repo.CacheStats()
+		return stats.Misses == 10
+	}, time.Second, time.Millisecond)
+	close(slow.release)
+	wg.Wait()
+	require.Equal(t, int32(1), slow.gets.Load())
+
+	// a caller that gives up doesn't wait for the read
+	slow.release = make(chan struct{})
+	require.NoError(t, repo.Repo.Delete(ctx, joke.ID, joke.Version))
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := repo.Repo.Get(canceled, joke.ID)
+	require.ErrorIs(t, err, context.Canceled)
+	close(slow.release)
+}
+
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+	cache := repository.NewLRUCache(2)
+
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// b is the least recently used
+	require.NoError(t, cache.Set(ctx, "c", []byte("3"), time.Minute))
+	_, ok, _ = cache.Get(ctx, "b")
+	require.False(t, ok)
+	value, ok, _ := cache.Get(ctx, "a")
+	require.True(t, ok)
+	require.Equal(t, []byte("1"), value)
+
+	require.NoError(t, cache.Delete(ctx, "a"))
+	_, ok, _ = cache.Get(ctx, "a")
+	require.False(t, ok)
+	require.NoError(t, cache.Delete(ctx, "missing"))
+
+	require.NoError(t, cache.Set(ctx, "d", []byte("4"), 10*time.Millisecond))
+	_, ok, _ = cache.Get(ctx, "d")
+	require.True(t, ok)
+	time.Sleep(20 * time.Millisecond)
+	_, ok, _ = cache.Get(ctx, "d")
+	require.False(t, ok)
+}
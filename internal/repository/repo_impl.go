@@ -2,8 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"time"
 
 	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/rating"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -11,43 +15,356 @@ import (
 )
 
 type repositoryImpl struct {
-	collection *mongo.Collection
+	collection  *mongo.Collection
+	submissions *mongo.Collection
+	votes       *mongo.Collection
+	revisions   *mongo.Collection
+	apiKeys     *mongo.Collection
 }
 
-func newRepositoryImpl(c *mongo.Collection) *repositoryImpl {
+func newRepositoryImpl(c, submissions, votes, revisions, apiKeys *mongo.Collection) *repositoryImpl {
 	return &repositoryImpl{
-		collection: c,
+		collection:  c,
+		submissions: submissions,
+		votes:       votes,
+		revisions:   revisions,
+		apiKeys:     apiKeys,
 	}
 }
 
+// RunInTransaction runs fn in a session transaction. The mongo driver carries the session in the
+// context, so every call fn makes with it is part of the transaction.
+func (r *repositoryImpl) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := r.collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (any, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
 func (r *repositoryImpl) Create(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
-	_, err := r.collection.InsertOne(ctx, data)
+	data = withDefaults(data)
+	var err error
+	if data.ID, err = assignID(data.ID); err != nil {
+		return data, err
+	}
+	doc, err := document(data, "_id")
+	if err != nil {
+		return data, err
+	}
+	_, err = r.collection.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return data, ErrDuplicateKey
+	}
 	return data, err
 }
 
-func (r *repositoryImpl) Get(ctx context.Context, id primitive.ObjectID) (models.Jusgo, error) {
+// CreateMany leaves the jokes that would collide with a stored one, or an earlier one of data, out before
+// inserting the rest: a duplicate key error aborts the transaction CreateMany may be part of.
+func (r *repositoryImpl) CreateMany(ctx context.Context, data []models.Jusgo) ([]error, error) {
+	errs := make([]error, len(data))
+	if len(data) == 0 {
+		return errs, nil
+	}
+	for i := range data {
+		var err error
+		if data[i].ID, err = assignID(data[i].ID); err != nil {
+			errs[i] = err
+		}
+	}
+
+	takenIDs, takenTexts, err := r.takenKeys(ctx, data)
+	if err != nil {
+		return errs, err
+	}
+
+	documents := []any{}
+	indexes := []int{} // the index in data of each document
+	for i, joke := range data {
+		if errs[i] != nil {
+			continue
+		}
+		if takenIDs[joke.ID] || (joke.Normalized != "" && takenTexts[joke.Normalized]) {
+			errs[i] = ErrDuplicateKey
+			continue
+		}
+		takenIDs[joke.ID] = true
+		if joke.Normalized != "" {
+			takenTexts[joke.Normalized] = true
+		}
+		doc, err := document(withDefaults(joke), "_id")
+		if err != nil {
+			return errs, err
+		}
+		documents = append(documents, doc)
+		indexes = append(indexes, i)
+	}
+	if len(documents) == 0 {
+		return errs, nil
+	}
+
+	// unordered so one bad joke doesn't keep the rest out, outside of a transaction a joke stored
+	// since the check above still fails on its own
+	_, err = r.collection.InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return errs, err
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		if mongo.IsDuplicateKeyError(writeErr) {
+			errs[indexes[writeErr.Index]] = ErrDuplicateKey
+		} else {
+			errs[indexes[writeErr.Index]] = writeErr
+		}
+	}
+	return errs, nil
+}
+
+// takenKeys returns the IDs and normalized texts of data that stored jokes already have.
+func (r *repositoryImpl) takenKeys(ctx context.Context, data []models.Jusgo) (map[string]bool, map[string]bool, error) {
+	ids := make([]primitive.ObjectID, 0, len(data))
+	normalized := []string{}
+	for _, joke := range data {
+		if id, err := parseID(joke.ID); err == nil {
+			ids = append(ids, id)
+		}
+		if joke.Normalized != "" {
+			normalized = append(normalized, joke.Normalized)
+		}
+	}
+
+	options := options.Find().SetProjection(bson.M{"_id": 1, "normalized": 1})
+	cursor, err := r.collection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"normalized": bson.M{"$in": normalized}},
+	}}, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stored []models.Jusgo
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, nil, err
+	}
+
+	takenIDs, takenTexts := make(map[string]bool), make(map[string]bool)
+	for _, joke := range stored {
+		takenIDs[joke.ID] = true
+		if joke.Normalized != "" {
+			takenTexts[joke.Normalized] = true
+		}
+	}
+	return takenIDs, takenTexts, nil
+}
+
+func (r *repositoryImpl) Get(ctx context.Context, id string) (models.Jusgo, error) {
+	objectID, err := parseID(id)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
 	var jusgo models.Jusgo
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&jusgo)
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID, "deleted_at": nil}).Decode(&jusgo)
 
-	return jusgo, err
+	return withDefaults(jusgo), notFound(err)
+}
+
+func (r *repositoryImpl) GetByNormalized(ctx context.Context, normalized string) (models.Jusgo, error) {
+	if normalized == "" {
+		return models.Jusgo{}, ErrNotFound
+	}
+
+	var jusgo models.Jusgo
+	err := r.collection.FindOne(ctx, bson.M{"normalized": normalized, "deleted_at": nil}).Decode(&jusgo)
+	return withDefaults(jusgo), notFound(err)
 }
 
 func (r *repositoryImpl) Update(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
-	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": data.ID}, bson.M{"$set": data})
-	return data, err
+	data = withDefaults(data)
+	objectID, err := parseID(data.ID)
+	if err != nil {
+		return data, err
+	}
+	version := data.Version
+	data.Version++
+	update, err := jokeUpdate(data)
+	if err != nil {
+		return data, err
+	}
+
+	var joke models.Jusgo
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID, "deleted_at": nil, "version": versionFilter(version)},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&joke)
+	if mongo.IsDuplicateKeyError(err) {
+		return data, ErrDuplicateKey
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return data, r.versionConflict(ctx, objectID)
+	}
+	if err != nil {
+		return data, err
+	}
+	return withDefaults(joke), nil
 }
 
-func (r *repositoryImpl) Delete(ctx context.Context, id primitive.ObjectID) error {
-	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
-	return err
+func (r *repositoryImpl) Delete(ctx context.Context, id string, version int64) error {
+	objectID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "deleted_at": nil, "version": versionFilter(version)},
+		bson.M{
+			"$set":   bson.M{"deleted_at": time.Now()},
+			"$unset": bson.M{"normalized": ""},
+			"$inc":   bson.M{"version": 1},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if err := r.versionConflict(ctx, objectID); !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *repositoryImpl) GetAll(ctx context.Context, skip, limit int64) ([]models.Jusgo, error) {
+// versionFilter matches jokes at version. Jokes stored before versions existed have none, they are at version 0.
+func versionFilter(version int64) any {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+// versionConflict is called when a compare-and-set on the joke with id matched nothing. It returns
+// ErrVersionConflict if the joke is live, so it must have been at another version, and ErrNotFound otherwise.
+func (r *repositoryImpl) versionConflict(ctx context.Context, id primitive.ObjectID) error {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": id, "deleted_at": nil}, options.Count().SetLimit(1))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrVersionConflict
+	}
+	return ErrNotFound
+}
+
+func (r *repositoryImpl) GetDeleted(ctx context.Context, id string) (models.Jusgo, error) {
+	objectID, err := parseID(id)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	var jusgo models.Jusgo
+	err = r.collection.FindOne(ctx, bson.M{"_id": objectID, "deleted_at": bson.M{"$ne": nil}}).Decode(&jusgo)
+	return withDefaults(jusgo), notFound(err)
+}
+
+func (r *repositoryImpl) Restore(ctx context.Context, id, normalized string) (models.Jusgo, error) {
+	objectID, err := parseID(id)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	unset := bson.M{"deleted_at": ""}
+	update := bson.M{"$unset": unset, "$inc": bson.M{"version": 1}}
+	if normalized != "" {
+		update["$set"] = bson.M{"normalized": normalized}
+	} else {
+		unset["normalized"] = ""
+	}
+
+	var jusgo models.Jusgo
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID, "deleted_at": bson.M{"$ne": nil}},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&jusgo)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.Jusgo{}, ErrDuplicateKey
+	}
+	return withDefaults(jusgo), notFound(err)
+}
+
+func (r *repositoryImpl) Purge(ctx context.Context, id string) error {
+	objectID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objectID, "deleted_at": bson.M{"$ne": nil}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PurgeDeletedBefore finds the jokes before removing them, it should run in a transaction so they can't be
+// restored in between.
+func (r *repositoryImpl) PurgeDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]models.Jusgo, error) {
 	options := options.Find()
+	options.SetSort(bson.D{{Key: "_id", Value: 1}})
+	options.SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, bson.M{"deleted_at": bson.M{"$ne": nil, "$lt": before}}, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	purged := []models.Jusgo{}
+	if err = cursor.All(ctx, &purged); err != nil {
+		return nil, err
+	}
+	if len(purged) == 0 {
+		return purged, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(purged))
+	for i := range purged {
+		purged[i] = withDefaults(purged[i])
+		if ids[i], err = parseID(purged[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	_, err = r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$ne": nil}})
+	if err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
+
+func (r *repositoryImpl) GetAll(ctx context.Context, filter models.JokeFilter, sort models.JokeSort, skip, limit int64) ([]models.Jusgo, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	options := options.Find()
+	options.SetSort(mongoSort(sort))
 	options.SetSkip(skip)
 	options.SetLimit(limit)
 
-	cursor, err := r.collection.Find(ctx, bson.M{}, options)
+	cursor, err := r.collection.Find(ctx, mongoFilter(filter), options)
 	if err != nil {
 		return nil, err
 	}
@@ -57,6 +374,562 @@ func (r *repositoryImpl) GetAll(ctx context.Context, skip, limit int64) ([]model
 	if err = cursor.All(ctx, &jokes); err != nil {
 		return nil, err
 	}
+	for i := range jokes {
+		jokes[i] = withDefaults(jokes[i])
+	}
+
+	return jokes, nil
+}
+
+func (r *repositoryImpl) GetAfter(ctx context.Context, filter models.JokeFilter, after string, limit int64) ([]models.Jusgo, error) {
+	query := mongoFilter(filter)
+	if after != "" {
+		afterID, err := parseID(after)
+		if err != nil {
+			return nil, err
+		}
+		query["_id"] = bson.M{"$gt": afterID}
+	}
+
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "_id", Value: 1}})
+	options.SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, query, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jokes := []models.Jusgo{}
+	if err = cursor.All(ctx, &jokes); err != nil {
+		return nil, err
+	}
+	for i := range jokes {
+		jokes[i] = withDefaults(jokes[i])
+	}
+
+	return jokes, nil
+}
+
+// Search uses the text index, see database.CreateMongoIndexes.
+func (r *repositoryImpl) Search(ctx context.Context, query string, skip, limit int64) ([]models.SearchResult, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	score := bson.M{"$meta": "textScore"}
+	options := options.Find()
+	options.SetProjection(bson.M{"score": score})
+	options.SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}})
+	options.SetSkip(skip)
+	options.SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, bson.M{"$text": bson.M{"$search": query}, "deleted_at": nil}, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.SearchResult{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Jusgo = withDefaults(results[i].Jusgo)
+	}
+
+	return results, nil
+}
+
+func (r *repositoryImpl) Count(ctx context.Context, filter models.JokeFilter) (int64, error) {
+	return r.collection.CountDocuments(ctx, mongoFilter(filter))
+}
+
+// GetSimilar uses the multikey index on signature, see database.CreateMongoIndexes.
+func (r *repositoryImpl) GetSimilar(ctx context.Context, signature []string, limit int64) ([]models.Jusgo, error) {
+	if len(signature) == 0 {
+		return []models.Jusgo{}, nil
+	}
+
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"signature": bson.M{"$in": signature}, "deleted_at": nil},
+		options.Find().SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
 
+	jokes := []models.Jusgo{}
+	if err = cursor.All(ctx, &jokes); err != nil {
+		return nil, err
+	}
+	for i := range jokes {
+		jokes[i] = withDefaults(jokes[i])
+	}
 	return jokes, nil
 }
+
+func (r *repositoryImpl) SetSignature(ctx context.Context, id string, signature []string) error {
+	objectID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"signature": signature}}
+	if len(signature) == 0 {
+		update = bson.M{"$unset": bson.M{"signature": ""}}
+	}
+	result, err := r.collection.UpdateByID(ctx, objectID, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *repositoryImpl) GetRandom(ctx context.Context, filter models.JokeFilter, count int64, exclude []string) ([]models.Jusgo, error) {
+	excludeIDs, err := parseIDs(exclude)
+	if err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		return []models.Jusgo{}, nil
+	}
+
+	match := mongoFilter(filter)
+	match["_id"] = bson.M{"$nin": excludeIDs}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sample", Value: bson.M{"size": count}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jokes := []models.Jusgo{}
+	if err = cursor.All(ctx, &jokes); err != nil {
+		return nil, err
+	}
+
+	// $sample can return the same document more than once on large collections
+	seen := make(map[string]bool, len(jokes))
+	distinct := jokes[:0]
+	for _, joke := range jokes {
+		if !seen[joke.ID] {
+			seen[joke.ID] = true
+			distinct = append(distinct, withDefaults(joke))
+		}
+	}
+
+	return distinct, nil
+}
+
+// mongoFilter builds the query document for filter.
+func mongoFilter(filter models.JokeFilter) bson.M {
+	query := bson.M{}
+	switch filter.Type {
+	case "":
+	case models.TypeSingle:
+		query["type"] = bson.M{"$in": bson.A{models.TypeSingle, nil}} // nil matches jokes stored before types
+	default:
+		query["type"] = filter.Type
+	}
+	if len(filter.Categories) > 0 {
+		query["category"] = bson.M{"$in": filter.Categories}
+	}
+	if len(filter.Tags) > 0 {
+		operator := "$in"
+		if filter.MatchAllTags {
+			operator = "$all"
+		}
+		query["tags"] = bson.M{operator: filter.Tags}
+	}
+
+	created := bson.M{}
+	if !filter.CreatedAfter.IsZero() {
+		created["$gt"] = filter.CreatedAfter
+	}
+	if !filter.CreatedBefore.IsZero() {
+		created["$lt"] = filter.CreatedBefore
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+	if !filter.UpdatedSince.IsZero() {
+		query["updated_at"] = bson.M{"$gte": filter.UpdatedSince}
+	}
+
+	if filter.Unsigned {
+		query["signature"] = bson.M{"$exists": false}
+	}
+
+	if filter.Deleted {
+		query["deleted_at"] = bson.M{"$ne": nil}
+	} else {
+		query["deleted_at"] = nil // null matches a missing field as well
+	}
+	return query
+}
+
+// mongoSort returns the sort document for sort, ties are broken by _id so pages never overlap.
+// ObjectIDs sort in creation order.
+func mongoSort(sort models.JokeSort) bson.D {
+	direction := 1
+	if sort.Descending {
+		direction = -1
+	}
+	if sort.Field == "" {
+		return bson.D{{Key: "_id", Value: direction}}
+	}
+	return bson.D{{Key: sort.Field, Value: direction}, {Key: "_id", Value: direction}}
+}
+
+func (r *repositoryImpl) CreateSubmission(ctx context.Context, data models.Submission) (models.Submission, error) {
+	data = submissionWithDefaults(data)
+	var err error
+	if data.ID, err = assignID(data.ID); err != nil {
+		return data, err
+	}
+	doc, err := document(data, "_id")
+	if err != nil {
+		return data, err
+	}
+	_, err = r.submissions.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return data, ErrDuplicateKey
+	}
+	return data, err
+}
+
+func (r *repositoryImpl) GetSubmission(ctx context.Context, id string) (models.Submission, error) {
+	objectID, err := parseID(id)
+	if err != nil {
+		return models.Submission{}, err
+	}
+
+	var submission models.Submission
+	err = r.submissions.FindOne(ctx, bson.M{"_id": objectID}).Decode(&submission)
+	return submission, notFound(err)
+}
+
+func (r *repositoryImpl) UpdateSubmission(ctx context.Context, data models.Submission) (models.Submission, error) {
+	data = submissionWithDefaults(data)
+	objectID, err := parseID(data.ID)
+	if err != nil {
+		return data, err
+	}
+	set, err := document(data)
+	if err != nil {
+		return data, err
+	}
+	set = slices.DeleteFunc(set, func(e bson.E) bool { return e.Key == "_id" })
+
+	result, err := r.submissions.UpdateOne(ctx, bson.M{"_id": objectID, "status": models.SubmissionPending}, bson.M{"$set": set})
+	if err != nil {
+		return data, err
+	}
+	if result.MatchedCount == 0 {
+		count, err := r.submissions.CountDocuments(ctx, bson.M{"_id": objectID}, options.Count().SetLimit(1))
+		if err != nil {
+			return data, err
+		}
+		if count > 0 {
+			return data, ErrNotPending
+		}
+		return data, ErrNotFound
+	}
+	return data, nil
+}
+
+func (r *repositoryImpl) GetSubmissions(ctx context.Context, status string, skip, limit int64) ([]models.Submission, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "_id", Value: 1}})
+	options.SetSkip(skip)
+	options.SetLimit(limit)
+
+	cursor, err := r.submissions.Find(ctx, submissionFilter(status), options)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	submissions := []models.Submission{}
+	if err = cursor.All(ctx, &submissions); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+func (r *repositoryImpl) CountSubmissions(ctx context.Context, status string) (int64, error) {
+	return r.submissions.CountDocuments(ctx, submissionFilter(status))
+}
+
+func submissionFilter(status string) bson.M {
+	if status == "" {
+		return bson.M{}
+	}
+	return bson.M{"status": status}
+}
+
+func (r *repositoryImpl) AddVote(ctx context.Context, vote models.Vote) error {
+	doc, err := document(vote, "joke_id")
+	if err != nil {
+		return err
+	}
+	_, err = r.votes.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) { // the unique index on joke_id, client and window
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (r *repositoryImpl) IncrementVotes(ctx context.Context, id string, up, down int64) (models.Jusgo, error) {
+	objectID, err := parseID(id)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	var joke models.Jusgo
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID, "deleted_at": nil},
+		bson.M{"$inc": bson.M{"upvotes": up, "downvotes": down}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&joke)
+	if err != nil {
+		return models.Jusgo{}, notFound(err)
+	}
+
+	// only set the rating if no other vote came in since, that vote's update sets it otherwise
+	joke.Rating = rating.Wilson(joke.Upvotes, joke.Downvotes)
+	_, err = r.collection.UpdateOne(ctx,
+		bson.M{"_id": objectID, "upvotes": joke.Upvotes, "downvotes": joke.Downvotes},
+		bson.M{"$set": bson.M{"rating": joke.Rating}},
+	)
+	return withDefaults(joke), err
+}
+
+func (r *repositoryImpl) GetVoteTotals(ctx context.Context, since time.Time) ([]models.VoteTotal, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$joke_id",
+			"upvotes":   bson.M{"$sum": bson.M{"$cond": bson.A{"$up", 1, 0}}},
+			"downvotes": bson.M{"$sum": bson.M{"$cond": bson.A{"$up", 0, 1}}},
+		}}},
+	}
+
+	cursor, err := r.votes.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	totals := []models.VoteTotal{}
+	if err = cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+func (r *repositoryImpl) AddRevision(ctx context.Context, rev models.Revision) (models.Revision, error) {
+	var err error
+	if rev.ID, err = assignID(rev.ID); err != nil {
+		return rev, err
+	}
+	jokeID, err := parseID(rev.JokeID)
+	if err != nil {
+		return rev, err
+	}
+
+	var last models.Revision
+	err = r.revisions.FindOne(ctx, bson.M{"joke_id": jokeID},
+		options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}}),
+	).Decode(&last)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return rev, err
+	}
+
+	// the unique index on joke_id and number fails the insert if another revision got the number first
+	rev.Number = last.Number + 1
+	doc, err := document(rev, "_id", "joke_id")
+	if err != nil {
+		return rev, err
+	}
+	_, err = r.revisions.InsertOne(ctx, doc)
+	return rev, err
+}
+
+func (r *repositoryImpl) GetRevision(ctx context.Context, jokeID string, number int64) (models.Revision, error) {
+	objectID, err := parseID(jokeID)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	var rev models.Revision
+	err = r.revisions.FindOne(ctx, bson.M{"joke_id": objectID, "number": number}).Decode(&rev)
+	return rev, notFound(err)
+}
+
+func (r *repositoryImpl) GetRevisions(ctx context.Context, jokeID string, skip, limit int64) ([]models.Revision, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	objectID, err := parseID(jokeID)
+	if err != nil {
+		return nil, err
+	}
+
+	options := options.Find()
+	options.SetSort(bson.D{{Key: "number", Value: 1}})
+	options.SetSkip(skip)
+	options.SetLimit(limit)
+
+	cursor, err := r.revisions.Find(ctx, bson.M{"joke_id": objectID}, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.Revision{}
+	if err = cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *repositoryImpl) CountRevisions(ctx context.Context, jokeID string) (int64, error) {
+	objectID, err := parseID(jokeID)
+	if err != nil {
+		return 0, err
+	}
+	return r.revisions.CountDocuments(ctx, bson.M{"joke_id": objectID})
+}
+
+func (r *repositoryImpl) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	var err error
+	if key.ID, err = assignID(key.ID); err != nil {
+		return key, err
+	}
+	doc, err := document(key, "_id")
+	if err != nil {
+		return key, err
+	}
+	_, err = r.apiKeys.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) { // the unique index on hash, or the _id
+		return key, ErrDuplicateKey
+	}
+	return key, err
+}
+
+func (r *repositoryImpl) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	var key models.APIKey
+	err := r.apiKeys.FindOne(ctx, bson.M{"hash": hash}).Decode(&key)
+	return key, notFound(err)
+}
+
+func (r *repositoryImpl) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	cursor, err := r.apiKeys.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []models.APIKey{}
+	if err = cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *repositoryImpl) RevokeAPIKey(ctx context.Context, id string, at time.Time) (models.APIKey, error) {
+	objectID, err := parseID(id)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	var key models.APIKey
+	err = r.apiKeys.FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": at}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// revoked already, or there is no such key
+		err = r.apiKeys.FindOne(ctx, bson.M{"_id": objectID}).Decode(&key)
+	}
+	return key, notFound(err)
+}
+
+// notFound turns the driver's mongo.ErrNoDocuments into ErrNotFound, so callers don't need the driver to check for it.
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// document returns v as a document in which the named fields, which models keep as hex strings, are ObjectIDs.
+func document(v any, idFields ...string) (bson.D, error) {
+	encoded, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc bson.D
+	if err := bson.Unmarshal(encoded, &doc); err != nil {
+		return nil, err
+	}
+	for i, e := range doc {
+		if !slices.Contains(idFields, e.Key) {
+			continue
+		}
+		id, ok := e.Value.(string)
+		if !ok {
+			continue
+		}
+		if doc[i].Value, err = parseID(id); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// jokeUpdate returns the update that replaces the stored joke with data, removing the fields data doesn't have.
+// The ID and creation time never change, the votes only change through IncrementVotes and deleted_at
+// only changes through Delete and Restore, so they are left out.
+func jokeUpdate(data models.Jusgo) (bson.M, error) {
+	encoded, err := bson.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var set bson.M
+	if err := bson.Unmarshal(encoded, &set); err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"_id", "created_at", "upvotes", "downvotes", "rating", "deleted_at"} {
+		delete(set, key)
+	}
+
+	update := bson.M{"$set": set}
+	unset := bson.M{}
+	for _, key := range []string{"setup", "delivery", "normalized", "signature"} {
+		if _, ok := set[key]; !ok {
+			unset[key] = ""
+		}
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
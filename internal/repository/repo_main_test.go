@@ -1,40 +1,47 @@
-package repository
+package repository_test
 
 import (
 	"context"
 	"log"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/joho/godotenv"
 	"github.com/zde37/Jusgo/internal/database"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-var testRepo *Repository
+// testDB is nil when DB_SOURCE is not a mongodb uri, tests that need it are skipped.
+var testDB *mongo.Database
 
 func TestMain(m *testing.M) {
-	err := godotenv.Load("../../.env")
-	if err != nil {
-		log.Fatalf("failed to load .env file: %v", err)
+	if err := godotenv.Load("../../.env"); err != nil {
+		log.Printf("failed to load .env file: %v", err)
+	}
+
+	source := os.Getenv("DB_SOURCE")
+	if source == "" || strings.HasPrefix(source, "memory://") {
+		log.Println("DB_SOURCE is not a mongodb uri, skipping mongodb tests")
+		os.Exit(m.Run())
 	}
 
 	// Set up MongoDB connection
 	ctx := context.Background()
-	client, cancel, err := database.ConnectToMongoDB(os.Getenv("DB_SOURCE"), ctx)
+	client, cancel, err := database.ConnectToMongoDB(source, ctx)
 	if err != nil {
 		log.Fatalf("failed to connect to mongodb: %v", err)
 	}
 
-	database, collection := "Renew", "Test"
-	if os.Getenv("DATABASE") != "" || os.Getenv("COLLECTION") != "" {
-		database, collection = os.Getenv("DATABASE"), os.Getenv("COLLECTION")
+	name := "Renew"
+	if os.Getenv("DATABASE") != "" {
+		name = os.Getenv("DATABASE")
 	}
+	testDB = client.Database(name)
 
-	col := client.Database(database).Collection(collection)
-	testRepo = NewRepository(col)
-
-	defer cancel()
-	defer client.Disconnect(ctx)
+	code := m.Run()
 
-	os.Exit(m.Run())
+	client.Disconnect(ctx)
+	cancel()
+	os.Exit(code)
 }
@@ -0,0 +1,930 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/rating"
+	"github.com/zde37/Jusgo/internal/search"
+)
+
+// memoryRepositoryImpl keeps jokes in a map guarded by a mutex. It mimics the
+// behaviour of the mongo implementation so it can stand in for it in tests and local runs.
+type memoryRepositoryImpl struct {
+	mu          sync.RWMutex
+	jokes       map[string]models.Jusgo
+	submissions map[string]models.Submission
+	votes       []models.Vote
+	revisions   []models.Revision
+	apiKeys     []models.APIKey
+
+	txMu sync.Mutex // one transaction at a time
+}
+
+// memoryTxKey marks the context of a running RunInTransaction.
+type memoryTxKey struct{}
+
+// RunInTransaction takes a copy of everything before calling fn and puts it back if fn fails.
+// Transactions run one at a time but aren't isolated from calls made outside of them, which
+// is fine for tests and local runs.
+func (r *memoryRepositoryImpl) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) != nil {
+		return fn(ctx)
+	}
+
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	r.mu.RLock()
+	jokes := maps.Clone(r.jokes)
+	submissions := maps.Clone(r.submissions)
+	votes := slices.Clone(r.votes)
+	revisions := slices.Clone(r.revisions)
+	apiKeys := slices.Clone(r.apiKeys)
+	r.mu.RUnlock()
+
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, true)); err != nil {
+		r.mu.Lock()
+		r.jokes, r.submissions, r.votes, r.revisions, r.apiKeys = jokes, submissions, votes, revisions, apiKeys
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func newMemoryRepositoryImpl() *memoryRepositoryImpl {
+	return &memoryRepositoryImpl{
+		jokes:       make(map[string]models.Jusgo),
+		submissions: make(map[string]models.Submission),
+	}
+}
+
+func (r *memoryRepositoryImpl) Create(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return data, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data = withDefaults(data)
+	var err error
+	if data.ID, err = assignID(data.ID); err != nil {
+		return data, err
+	}
+	if _, exists := r.jokes[data.ID]; exists || r.normalizedTaken(data) {
+		return data, ErrDuplicateKey
+	}
+	r.jokes[data.ID] = data
+	return data, nil
+}
+
+func (r *memoryRepositoryImpl) CreateMany(ctx context.Context, data []models.Jusgo) ([]error, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]error, len(data))
+	for i := range data {
+		var err error
+		if data[i].ID, err = assignID(data[i].ID); err != nil {
+			errs[i] = err
+			continue
+		}
+		joke := data[i]
+		if _, exists := r.jokes[joke.ID]; exists || r.normalizedTaken(joke) {
+			errs[i] = ErrDuplicateKey
+			continue
+		}
+		r.jokes[joke.ID] = withDefaults(joke)
+	}
+	return errs, nil
+}
+
+func (r *memoryRepositoryImpl) Get(ctx context.Context, id string) (models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	joke, exists := r.jokes[id]
+	if !exists || joke.DeletedAt != nil {
+		return models.Jusgo{}, ErrNotFound
+	}
+	return joke, nil
+}
+
+func (r *memoryRepositoryImpl) GetByNormalized(ctx context.Context, normalized string) (models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, joke := range r.jokes {
+		if normalized != "" && joke.Normalized == normalized && joke.DeletedAt == nil {
+			return joke, nil
+		}
+	}
+	return models.Jusgo{}, ErrNotFound
+}
+
+func (r *memoryRepositoryImpl) Update(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return data, err
+	}
+
+	var err error
+	if data.ID, err = checkID(data.ID); err != nil {
+		return data, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data = withDefaults(data)
+	stored, exists := r.jokes[data.ID]
+	if !exists || stored.DeletedAt != nil {
+		return data, ErrNotFound
+	}
+	if stored.Version != data.Version {
+		return data, ErrVersionConflict
+	}
+	if r.normalizedTaken(data) {
+		return data, ErrDuplicateKey
+	}
+
+	data.Version++
+	data.CreatedAt = stored.CreatedAt
+	data.Upvotes, data.Downvotes, data.Rating = stored.Upvotes, stored.Downvotes, stored.Rating
+	data.DeletedAt = nil
+	r.jokes[data.ID] = data
+	return data, nil
+}
+
+func (r *memoryRepositoryImpl) Delete(ctx context.Context, id string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if joke, exists := r.jokes[id]; exists && joke.DeletedAt == nil {
+		if joke.Version != version {
+			return ErrVersionConflict
+		}
+		now := time.Now()
+		joke.DeletedAt = &now
+		joke.Normalized = ""
+		joke.Version++
+		r.jokes[id] = joke
+	}
+	return nil
+}
+
+func (r *memoryRepositoryImpl) GetDeleted(ctx context.Context, id string) (models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	joke, exists := r.jokes[id]
+	if !exists || joke.DeletedAt == nil {
+		return models.Jusgo{}, ErrNotFound
+	}
+	return joke, nil
+}
+
+func (r *memoryRepositoryImpl) Restore(ctx context.Context, id, normalized string) (models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	joke, exists := r.jokes[id]
+	if !exists || joke.DeletedAt == nil {
+		return models.Jusgo{}, ErrNotFound
+	}
+
+	joke.Normalized = normalized
+	if r.normalizedTaken(joke) {
+		return models.Jusgo{}, ErrDuplicateKey
+	}
+	joke.DeletedAt = nil
+	joke.Version++
+	r.jokes[id] = joke
+	return joke, nil
+}
+
+func (r *memoryRepositoryImpl) Purge(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	joke, exists := r.jokes[id]
+	if !exists || joke.DeletedAt == nil {
+		return ErrNotFound
+	}
+	delete(r.jokes, id)
+	return nil
+}
+
+func (r *memoryRepositoryImpl) PurgeDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	purged := []models.Jusgo{}
+	for _, joke := range r.jokes {
+		if joke.DeletedAt != nil && joke.DeletedAt.Before(before) {
+			purged = append(purged, joke)
+		}
+	}
+	slices.SortFunc(purged, func(a, b models.Jusgo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	purged = paginate(purged, 0, limit)
+	for _, joke := range purged {
+		delete(r.jokes, joke.ID)
+	}
+	return purged, nil
+}
+
+func (r *memoryRepositoryImpl) GetAll(ctx context.Context, filter models.JokeFilter, sort models.JokeSort, skip, limit int64) ([]models.Jusgo, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	jokes := make([]models.Jusgo, 0, len(r.jokes))
+	for _, joke := range r.jokes {
+		if matchesFilter(joke, filter) {
+			jokes = append(jokes, joke)
+		}
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(jokes, func(a, b models.Jusgo) int {
+		return compareJokes(a, b, sort)
+	})
+
+	return paginate(jokes, skip, limit), nil
+}
+
+func (r *memoryRepositoryImpl) GetAfter(ctx context.Context, filter models.JokeFilter, after string, limit int64) ([]models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if after != "" { // the empty ID sorts before every other one
+		var err error
+		if after, err = checkID(after); err != nil {
+			return nil, err
+		}
+	}
+
+	r.mu.RLock()
+	jokes := make([]models.Jusgo, 0, len(r.jokes))
+	for id, joke := range r.jokes {
+		if id > after && matchesFilter(joke, filter) {
+			jokes = append(jokes, joke)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(jokes, func(i, j int) bool {
+		return jokes[i].ID < jokes[j].ID
+	})
+
+	return paginate(jokes, 0, limit), nil
+}
+
+func (r *memoryRepositoryImpl) Search(ctx context.Context, query string, skip, limit int64) ([]models.SearchResult, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	jokes, err := r.GetAll(ctx, models.JokeFilter{}, models.JokeSort{}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return paginate(rankSearchResults(jokes, search.Parse(query)), skip, limit), nil
+}
+
+func (r *memoryRepositoryImpl) Count(ctx context.Context, filter models.JokeFilter) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, joke := range r.jokes {
+		if matchesFilter(joke, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetSimilar compares signature to every joke, the memory repository holds too few for an index to pay off.
+func (r *memoryRepositoryImpl) GetSimilar(ctx context.Context, signature []string, limit int64) ([]models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	jokes := []models.Jusgo{}
+	for _, joke := range r.jokes {
+		if joke.DeletedAt == nil && slices.ContainsFunc(joke.Signature, func(bucket string) bool {
+			return slices.Contains(signature, bucket)
+		}) {
+			jokes = append(jokes, joke)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(jokes, func(i, j int) bool {
+		return jokes[i].ID < jokes[j].ID
+	})
+	return paginate(jokes, 0, limit), nil
+}
+
+func (r *memoryRepositoryImpl) SetSignature(ctx context.Context, id string, signature []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	joke, exists := r.jokes[id]
+	if !exists {
+		return ErrNotFound
+	}
+	joke.Signature = slices.Clone(signature)
+	r.jokes[id] = joke
+	return nil
+}
+
+func (r *memoryRepositoryImpl) GetRandom(ctx context.Context, filter models.JokeFilter, count int64, exclude []string) ([]models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	excludeIDs, err := checkIDs(exclude)
+	if err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		return []models.Jusgo{}, nil
+	}
+
+	skipped := make(map[string]bool, len(excludeIDs))
+	for _, id := range excludeIDs {
+		skipped[id] = true
+	}
+
+	r.mu.RLock()
+	jokes := make([]models.Jusgo, 0, len(r.jokes))
+	for id, joke := range r.jokes {
+		if !skipped[id] && matchesFilter(joke, filter) {
+			jokes = append(jokes, joke)
+		}
+	}
+	r.mu.RUnlock()
+
+	rand.Shuffle(len(jokes), func(i, j int) {
+		jokes[i], jokes[j] = jokes[j], jokes[i]
+	})
+
+	if count < int64(len(jokes)) {
+		jokes = jokes[:count]
+	}
+	return jokes, nil
+}
+
+func (r *memoryRepositoryImpl) CreateSubmission(ctx context.Context, data models.Submission) (models.Submission, error) {
+	if err := ctx.Err(); err != nil {
+		return data, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data = submissionWithDefaults(data)
+	var err error
+	if data.ID, err = assignID(data.ID); err != nil {
+		return data, err
+	}
+	if _, exists := r.submissions[data.ID]; exists {
+		return data, ErrDuplicateKey
+	}
+	r.submissions[data.ID] = data
+	return data, nil
+}
+
+func (r *memoryRepositoryImpl) GetSubmission(ctx context.Context, id string) (models.Submission, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Submission{}, err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return models.Submission{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	submission, exists := r.submissions[id]
+	if !exists {
+		return models.Submission{}, ErrNotFound
+	}
+	return submission, nil
+}
+
+func (r *memoryRepositoryImpl) UpdateSubmission(ctx context.Context, data models.Submission) (models.Submission, error) {
+	if err := ctx.Err(); err != nil {
+		return data, err
+	}
+
+	var err error
+	if data.ID, err = checkID(data.ID); err != nil {
+		return data, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	submission, exists := r.submissions[data.ID]
+	if !exists {
+		return data, ErrNotFound
+	}
+	if submission.Status != models.SubmissionPending {
+		return data, ErrNotPending
+	}
+
+	data = submissionWithDefaults(data)
+	r.submissions[data.ID] = data
+	return data, nil
+}
+
+func (r *memoryRepositoryImpl) GetSubmissions(ctx context.Context, status string, skip, limit int64) ([]models.Submission, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	submissions := r.filterSubmissions(status)
+	r.mu.RUnlock()
+
+	slices.SortFunc(submissions, func(a, b models.Submission) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return paginate(submissions, skip, limit), nil
+}
+
+func (r *memoryRepositoryImpl) CountSubmissions(ctx context.Context, status string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.filterSubmissions(status))), nil
+}
+
+// filterSubmissions returns the submissions with status, or all of them if status is empty.
+// The caller must hold the lock.
+func (r *memoryRepositoryImpl) filterSubmissions(status string) []models.Submission {
+	submissions := []models.Submission{}
+	for _, submission := range r.submissions {
+		if status == "" || submission.Status == status {
+			submissions = append(submissions, submission)
+		}
+	}
+	return submissions
+}
+
+func (r *memoryRepositoryImpl) AddVote(ctx context.Context, vote models.Vote) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var err error
+	if vote.JokeID, err = checkID(vote.JokeID); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range r.votes {
+		if v.JokeID == vote.JokeID && v.Client == vote.Client && v.Window.Equal(vote.Window) {
+			return ErrDuplicateKey
+		}
+	}
+	r.votes = append(r.votes, vote)
+	return nil
+}
+
+func (r *memoryRepositoryImpl) IncrementVotes(ctx context.Context, id string, up, down int64) (models.Jusgo, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	joke, exists := r.jokes[id]
+	if !exists || joke.DeletedAt != nil {
+		return models.Jusgo{}, ErrNotFound
+	}
+	joke.Upvotes += up
+	joke.Downvotes += down
+	joke.Rating = rating.Wilson(joke.Upvotes, joke.Downvotes)
+	r.jokes[id] = joke
+	return joke, nil
+}
+
+func (r *memoryRepositoryImpl) GetVoteTotals(ctx context.Context, since time.Time) ([]models.VoteTotal, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	totals := []models.VoteTotal{}
+	index := make(map[string]int)
+	for _, vote := range r.votes {
+		if vote.CreatedAt.Before(since) {
+			continue
+		}
+		i, exists := index[vote.JokeID]
+		if !exists {
+			i = len(totals)
+			index[vote.JokeID] = i
+			totals = append(totals, models.VoteTotal{JokeID: vote.JokeID})
+		}
+		if vote.Up {
+			totals[i].Upvotes++
+		} else {
+			totals[i].Downvotes++
+		}
+	}
+	return totals, nil
+}
+
+// normalizedTaken reports whether another joke has the normalized text of joke, which the
+// unique index of the other backends forbids. The caller must hold the lock.
+func (r *memoryRepositoryImpl) normalizedTaken(joke models.Jusgo) bool {
+	if joke.Normalized == "" {
+		return false
+	}
+	for id, stored := range r.jokes {
+		if id != joke.ID && stored.Normalized == joke.Normalized {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilter reports whether joke would be returned by a mongo query for filter.
+func matchesFilter(joke models.Jusgo, filter models.JokeFilter) bool {
+	if (joke.DeletedAt != nil) != filter.Deleted {
+		return false
+	}
+	if filter.Type != "" && joke.Type != filter.Type {
+		return false
+	}
+	if filter.Unsigned && len(joke.Signature) > 0 {
+		return false
+	}
+	if len(filter.Categories) > 0 && !slices.Contains(filter.Categories, joke.Category) {
+		return false
+	}
+	if !filter.CreatedAfter.IsZero() && !joke.CreatedAt.After(filter.CreatedAfter) {
+		return false
+	}
+	if !filter.CreatedBefore.IsZero() && !joke.CreatedAt.Before(filter.CreatedBefore) {
+		return false
+	}
+	if !filter.UpdatedSince.IsZero() && joke.UpdatedAt.Before(filter.UpdatedSince) {
+		return false
+	}
+	if len(filter.Tags) == 0 {
+		return true
+	}
+
+	for _, tag := range filter.Tags {
+		found := slices.Contains(joke.Tags, tag)
+		if found && !filter.MatchAllTags {
+			return true
+		}
+		if !found && filter.MatchAllTags {
+			return false
+		}
+	}
+	return filter.MatchAllTags
+}
+
+// compareJokes orders a and b like mongo does for sort, ties are broken by ID.
+// IDs sort in creation order.
+func compareJokes(a, b models.Jusgo, sort models.JokeSort) int {
+	c := 0
+	switch sort.Field {
+	case models.SortCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case models.SortUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	case models.SortRating:
+		c = cmp.Compare(a.Rating, b.Rating)
+	case models.SortDeletedAt:
+		c = compareTimes(a.DeletedAt, b.DeletedAt)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if sort.Descending {
+		return -c
+	}
+	return c
+}
+
+// compareTimes orders nil before any time, like mongo orders missing fields before values.
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Compare(*b)
+}
+
+// paginate applies mongo style skip/limit to a slice. A limit of zero means no limit.
+func paginate[T any](items []T, skip, limit int64) []T {
+	if limit < 0 {
+		limit = -limit
+	}
+	if skip >= int64(len(items)) {
+		return []T{}
+	}
+
+	items = items[skip:]
+	if limit > 0 && limit < int64(len(items)) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func (r *memoryRepositoryImpl) AddRevision(ctx context.Context, rev models.Revision) (models.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return rev, err
+	}
+
+	var err error
+	if rev.ID, err = assignID(rev.ID); err != nil {
+		return rev, err
+	}
+	if rev.JokeID, err = checkID(rev.JokeID); err != nil {
+		return rev, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rev.Number = 1
+	for _, stored := range r.revisions {
+		if stored.JokeID == rev.JokeID {
+			rev.Number = max(rev.Number, stored.Number+1)
+		}
+	}
+	r.revisions = append(r.revisions, rev)
+	return rev, nil
+}
+
+func (r *memoryRepositoryImpl) GetRevision(ctx context.Context, jokeID string, number int64) (models.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Revision{}, err
+	}
+
+	jokeID, err := checkID(jokeID)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rev := range r.revisions {
+		if rev.JokeID == jokeID && rev.Number == number {
+			return rev, nil
+		}
+	}
+	return models.Revision{}, ErrNotFound
+}
+
+func (r *memoryRepositoryImpl) GetRevisions(ctx context.Context, jokeID string, skip, limit int64) ([]models.Revision, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	jokeID, err := checkID(jokeID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	revisions := r.jokeRevisions(jokeID)
+	r.mu.RUnlock()
+
+	// revisions are appended in order, so they are sorted by number already
+	return paginate(revisions, skip, limit), nil
+}
+
+func (r *memoryRepositoryImpl) CountRevisions(ctx context.Context, jokeID string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	jokeID, err := checkID(jokeID)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.jokeRevisions(jokeID))), nil
+}
+
+// jokeRevisions returns the revisions of the joke with id. The caller must hold the lock.
+func (r *memoryRepositoryImpl) jokeRevisions(id string) []models.Revision {
+	revisions := []models.Revision{}
+	for _, rev := range r.revisions {
+		if rev.JokeID == id {
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions
+}
+
+func (r *memoryRepositoryImpl) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return key, err
+	}
+
+	var err error
+	if key.ID, err = assignID(key.ID); err != nil {
+		return key, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.apiKeys {
+		if stored.ID == key.ID || stored.Hash == key.Hash {
+			return key, ErrDuplicateKey
+		}
+	}
+	key.Scopes = slices.Clone(key.Scopes)
+	r.apiKeys = append(r.apiKeys, key)
+	return key, nil
+}
+
+func (r *memoryRepositoryImpl) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.apiKeys {
+		if key.Hash == hash {
+			key.Scopes = slices.Clone(key.Scopes)
+			return key, nil
+		}
+	}
+	return models.APIKey{}, ErrNotFound
+}
+
+func (r *memoryRepositoryImpl) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]models.APIKey, len(r.apiKeys))
+	for i, key := range r.apiKeys {
+		key.Scopes = slices.Clone(key.Scopes)
+		keys[i] = key
+	}
+	slices.SortFunc(keys, func(a, b models.APIKey) int { return strings.Compare(a.ID, b.ID) })
+	return keys, nil
+}
+
+func (r *memoryRepositoryImpl) RevokeAPIKey(ctx context.Context, id string, at time.Time) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	id, err := checkID(id)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, key := range r.apiKeys {
+		if key.ID != id {
+			continue
+		}
+		if key.RevokedAt == nil {
+			key.RevokedAt = &at
+			r.apiKeys[i] = key
+		}
+		key.Scopes = slices.Clone(key.Scopes)
+		return key, nil
+	}
+	return models.APIKey{}, ErrNotFound
+}
@@ -0,0 +1,35 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/repository/repositorytest"
+)
+
+func TestMemoryConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository()
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			joke := repositorytest.CreateJoke(t, ctx, repo.Repo)
+
+			_, err := repo.Repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{}, 0, 10)
+			require.NoError(t, err)
+			require.NoError(t, repo.Repo.Delete(ctx, joke.ID, joke.Version))
+		}()
+	}
+	wg.Wait()
+
+	jokes, err := repo.Repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{}, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, jokes)
+}
@@ -0,0 +1,1150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/rating"
+	"github.com/zde37/Jusgo/internal/search"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqlDialect holds the differences between the SQL databases jokes can be stored in.
+type sqlDialect struct {
+	// numberedParams is set when placeholders are written $1, $2... instead of ?
+	numberedParams bool
+	// noLimit is the LIMIT argument that returns every row
+	noLimit           any
+	isUniqueViolation func(err error) bool
+}
+
+var sqliteDialect = sqlDialect{
+	noLimit: -1,
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) {
+			return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+		}
+		return false
+	},
+}
+
+var postgresDialect = sqlDialect{
+	numberedParams: true,
+	noLimit:        nil, // LIMIT NULL
+	isUniqueViolation: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == "23505" // unique_violation
+	},
+}
+
+// rebind rewrites the ? placeholders in query to the style of the dialect.
+func (d sqlDialect) rebind(query string) string {
+	if !d.numberedParams {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// sqlRepositoryImpl stores jokes in the jokes table created by the database migrations.
+// IDs are kept as hex strings so they sort the same way ObjectIDs do.
+type sqlRepositoryImpl struct {
+	db      *sql.DB
+	dialect sqlDialect
+}
+
+func newSQLRepositoryImpl(db *sql.DB, dialect sqlDialect) *sqlRepositoryImpl {
+	return &sqlRepositoryImpl{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// sqlConn is implemented by both *sql.DB and *sql.Tx.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// txKey is the context key RunInTransaction stores its *sql.Tx under.
+type txKey struct{}
+
+// conn returns the transaction ctx carries, or the database outside of one.
+func (r *sqlRepositoryImpl) conn(ctx context.Context) sqlConn {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return r.db
+}
+
+func (r *sqlRepositoryImpl) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.conn(ctx).ExecContext(ctx, r.dialect.rebind(query), args...)
+}
+
+func (r *sqlRepositoryImpl) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.conn(ctx).QueryContext(ctx, r.dialect.rebind(query), args...)
+}
+
+func (r *sqlRepositoryImpl) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.conn(ctx).QueryRowContext(ctx, r.dialect.rebind(query), args...)
+}
+
+// RunInTransaction begins a transaction and passes it to the repository calls of fn through ctx.
+// With SQLite's single connection, fn must not make calls with a context from outside of it.
+func (r *sqlRepositoryImpl) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const jokeColumns = `id, type, joke, setup, delivery, category, tags, created_at, updated_at, normalized, upvotes, downvotes, rating, deleted_at, version`
+
+func (r *sqlRepositoryImpl) Create(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
+	data = withDefaults(data)
+	var err error
+	if data.ID, err = assignID(data.ID); err != nil {
+		return data, err
+	}
+	err = r.RunInTransaction(ctx, func(ctx context.Context) error {
+		_, err := r.exec(ctx,
+			`INSERT INTO jokes (`+jokeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			data.ID, data.Type, data.Joke, data.Setup, data.Delivery, data.Category, encodeTags(data.Tags),
+			data.CreatedAt.UTC(), data.UpdatedAt.UTC(), nullString(data.Normalized), data.Upvotes, data.Downvotes, data.Rating,
+			nullTime(data.DeletedAt), data.Version,
+		)
+		if err != nil {
+			return err
+		}
+		return r.insertSignature(ctx, data.ID, data.Signature)
+	})
+	if r.dialect.isUniqueViolation(err) {
+		return data, ErrDuplicateKey
+	}
+	return data, err
+}
+
+// CreateMany inserts every joke in a single transaction. Conflicting jokes are skipped with
+// ON CONFLICT DO NOTHING instead of failing, which would abort the whole transaction on PostgreSQL.
+func (r *sqlRepositoryImpl) CreateMany(ctx context.Context, data []models.Jusgo) ([]error, error) {
+	errs := make([]error, len(data))
+	err := r.RunInTransaction(ctx, func(ctx context.Context) error {
+		stmt, err := r.conn(ctx).PrepareContext(ctx, r.dialect.rebind(
+			`INSERT INTO jokes (`+jokeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i := range data {
+			if data[i].ID, err = assignID(data[i].ID); err != nil {
+				errs[i] = err
+				continue
+			}
+			joke := withDefaults(data[i])
+			result, err := stmt.ExecContext(ctx,
+				joke.ID, joke.Type, joke.Joke, joke.Setup, joke.Delivery, joke.Category, encodeTags(joke.Tags),
+				joke.CreatedAt.UTC(), joke.UpdatedAt.UTC(), nullString(joke.Normalized), joke.Upvotes, joke.Downvotes, joke.Rating,
+				nullTime(joke.DeletedAt), joke.Version,
+			)
+			if err != nil {
+				return err
+			}
+			if inserted, err := result.RowsAffected(); err != nil {
+				return err
+			} else if inserted == 0 {
+				errs[i] = ErrDuplicateKey
+				continue
+			}
+			if err := r.insertSignature(ctx, joke.ID, joke.Signature); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+func (r *sqlRepositoryImpl) Get(ctx context.Context, id string) (models.Jusgo, error) {
+	if _, err := parseID(id); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	row := r.queryRow(ctx, `SELECT `+jokeColumns+` FROM jokes WHERE id = ? AND deleted_at IS NULL`, id)
+	joke, err := scanJoke(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Jusgo{}, ErrNotFound
+	}
+	return joke, err
+}
+
+func (r *sqlRepositoryImpl) GetByNormalized(ctx context.Context, normalized string) (models.Jusgo, error) {
+	row := r.queryRow(ctx, `SELECT `+jokeColumns+` FROM jokes WHERE normalized = ? AND deleted_at IS NULL`, normalized)
+	joke, err := scanJoke(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Jusgo{}, ErrNotFound
+	}
+	return joke, err
+}
+
+// Update leaves created_at and the votes alone, like the $set of the mongo repository.
+func (r *sqlRepositoryImpl) Update(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
+	data = withDefaults(data)
+	var err error
+	if data.ID, err = checkID(data.ID); err != nil {
+		return data, err
+	}
+	var joke models.Jusgo
+	err = r.RunInTransaction(ctx, func(ctx context.Context) error {
+		row := r.queryRow(ctx,
+			`UPDATE jokes SET type = ?, joke = ?, setup = ?, delivery = ?, category = ?, tags = ?, updated_at = ?, normalized = ?, version = version + 1
+			WHERE id = ? AND deleted_at IS NULL AND version = ? RETURNING `+jokeColumns,
+			data.Type, data.Joke, data.Setup, data.Delivery, data.Category, encodeTags(data.Tags),
+			data.UpdatedAt.UTC(), nullString(data.Normalized), data.ID, data.Version,
+		)
+		var err error
+		if joke, err = scanJoke(row); err != nil {
+			return err
+		}
+		joke.Signature = data.Signature
+		return r.replaceSignature(ctx, data.ID, data.Signature)
+	})
+	if r.dialect.isUniqueViolation(err) {
+		return data, ErrDuplicateKey
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return data, r.versionConflict(ctx, data.ID)
+	}
+	if err != nil {
+		return data, err
+	}
+	return joke, nil
+}
+
+// insertSignature stores the buckets of the joke with id, see GetSimilar.
+func (r *sqlRepositoryImpl) insertSignature(ctx context.Context, id string, signature []string) error {
+	if len(signature) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(signature))
+	args := make([]any, 0, 2*len(signature))
+	for i, bucket := range signature {
+		values[i] = `(?, ?)`
+		args = append(args, bucket, id)
+	}
+	_, err := r.exec(ctx, `INSERT INTO joke_signatures (bucket, joke_id) VALUES `+strings.Join(values, `, `)+` ON CONFLICT DO NOTHING`, args...)
+	return err
+}
+
+// replaceSignature replaces the buckets of the joke with id with signature.
+func (r *sqlRepositoryImpl) replaceSignature(ctx context.Context, id string, signature []string) error {
+	if _, err := r.exec(ctx, `DELETE FROM joke_signatures WHERE joke_id = ?`, id); err != nil {
+		return err
+	}
+	return r.insertSignature(ctx, id, signature)
+}
+
+func (r *sqlRepositoryImpl) Delete(ctx context.Context, id string, version int64) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	result, err := r.exec(ctx,
+		`UPDATE jokes SET deleted_at = ?, normalized = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND version = ?`,
+		time.Now().UTC(), id, version,
+	)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil {
+		return err
+	} else if deleted == 0 {
+		if err := r.versionConflict(ctx, id); !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// versionConflict is called when a compare-and-set on the joke with id matched nothing. It returns
+// ErrVersionConflict if the joke is live, so it must have been at another version, and ErrNotFound otherwise.
+func (r *sqlRepositoryImpl) versionConflict(ctx context.Context, id string) error {
+	var count int64
+	if err := r.queryRow(ctx, `SELECT COUNT(*) FROM jokes WHERE id = ? AND deleted_at IS NULL`, id).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrVersionConflict
+	}
+	return ErrNotFound
+}
+
+func (r *sqlRepositoryImpl) GetDeleted(ctx context.Context, id string) (models.Jusgo, error) {
+	if _, err := parseID(id); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	row := r.queryRow(ctx, `SELECT `+jokeColumns+` FROM jokes WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	joke, err := scanJoke(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Jusgo{}, ErrNotFound
+	}
+	return joke, err
+}
+
+func (r *sqlRepositoryImpl) Restore(ctx context.Context, id, normalized string) (models.Jusgo, error) {
+	if _, err := parseID(id); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	row := r.queryRow(ctx,
+		`UPDATE jokes SET deleted_at = NULL, normalized = ?, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL RETURNING `+jokeColumns,
+		nullString(normalized), id,
+	)
+	joke, err := scanJoke(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Jusgo{}, ErrNotFound
+	}
+	if r.dialect.isUniqueViolation(err) {
+		return models.Jusgo{}, ErrDuplicateKey
+	}
+	return joke, err
+}
+
+func (r *sqlRepositoryImpl) Purge(ctx context.Context, id string) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	return r.RunInTransaction(ctx, func(ctx context.Context) error {
+		result, err := r.exec(ctx, `DELETE FROM jokes WHERE id = ? AND deleted_at IS NOT NULL`, id)
+		if err != nil {
+			return err
+		}
+		if purged, err := result.RowsAffected(); err != nil {
+			return err
+		} else if purged == 0 {
+			return ErrNotFound
+		}
+		_, err = r.exec(ctx, `DELETE FROM joke_signatures WHERE joke_id = ?`, id)
+		return err
+	})
+}
+
+func (r *sqlRepositoryImpl) PurgeDeletedBefore(ctx context.Context, before time.Time, limit int64) ([]models.Jusgo, error) {
+	var purged []models.Jusgo
+	err := r.RunInTransaction(ctx, func(ctx context.Context) error {
+		rows, err := r.query(ctx,
+			`SELECT `+jokeColumns+` FROM jokes WHERE deleted_at < ? ORDER BY id LIMIT ?`, before.UTC(), limit,
+		)
+		if err != nil {
+			return err
+		}
+		if purged, err = scanJokes(rows); err != nil || len(purged) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(purged))
+		for i, joke := range purged {
+			ids[i] = joke.ID
+		}
+		if _, err := r.exec(ctx, `DELETE FROM joke_signatures WHERE joke_id IN (`+placeholders(len(ids))+`)`, stringArgs(ids)...); err != nil {
+			return err
+		}
+		_, err = r.exec(ctx, `DELETE FROM jokes WHERE id IN (`+placeholders(len(ids))+`)`, stringArgs(ids)...)
+		return err
+	})
+	return purged, err
+}
+
+func (r *sqlRepositoryImpl) GetAll(ctx context.Context, filter models.JokeFilter, sort models.JokeSort, skip, limit int64) ([]models.Jusgo, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	var limitArg any = limit
+	if limit < 0 {
+		limitArg = -limit
+	}
+	if limit == 0 {
+		limitArg = r.dialect.noLimit
+	}
+
+	where := filterConditions(filter)
+	rows, err := r.query(ctx,
+		`SELECT `+jokeColumns+` FROM jokes`+where.String()+` ORDER BY `+orderBy(sort)+` LIMIT ? OFFSET ?`,
+		append(where.args, limitArg, skip)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanJokes(rows)
+}
+
+// GetAfter relies on IDs being stored as lower case hex, which sorts the same as the ObjectIDs themselves.
+func (r *sqlRepositoryImpl) GetAfter(ctx context.Context, filter models.JokeFilter, after string, limit int64) ([]models.Jusgo, error) {
+	where := filterConditions(filter)
+	if after != "" {
+		after, err := checkID(after)
+		if err != nil {
+			return nil, err
+		}
+		where.add(`id > ?`, after)
+	}
+
+	var limitArg any = limit
+	if limit == 0 {
+		limitArg = r.dialect.noLimit
+	}
+
+	rows, err := r.query(ctx,
+		`SELECT `+jokeColumns+` FROM jokes`+where.String()+` ORDER BY id LIMIT ?`,
+		append(where.args, limitArg)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanJokes(rows)
+}
+
+// searchCandidates is how many jokes past the end of the requested page Search ranks, see below.
+const searchCandidates = 500
+
+// Search narrows the jokes down with LIKE and ranks what's left in Go, see rankSearchResults. Only the
+// jokes that contain the most terms, shortest first, are read: those are the ones Score ranks highest,
+// it counts the terms found and then how much of the text they make up. Reading the skip+limit best of
+// them plus searchCandidates more leaves room for the ones LIKE takes for a term but Score doesn't,
+// like "debugger" for "bug", without loading every match for a common word.
+func (r *sqlRepositoryImpl) Search(ctx context.Context, query string, skip, limit int64) ([]models.SearchResult, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	parsed := search.Parse(query)
+	if parsed.Empty() {
+		return []models.SearchResult{}, nil
+	}
+
+	// matched counts the terms a joke has one of the forms of
+	matched := make([]string, 0, len(parsed.Terms))
+	args := make([]any, 0, len(parsed.Terms))
+	for _, term := range parsed.Terms {
+		forms := search.Forms(term)
+		clauses := make([]string, 0, len(forms))
+		for _, form := range forms {
+			clauses = append(clauses, `LOWER(joke || ' ' || setup || ' ' || delivery) LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(form)+"%")
+		}
+		matched = append(matched, `CASE WHEN `+strings.Join(clauses, ` OR `)+` THEN 1 ELSE 0 END`)
+	}
+
+	var limitArg any = skip + limit + searchCandidates
+	if limit == 0 {
+		limitArg = r.dialect.noLimit
+	}
+
+	rows, err := r.query(ctx,
+		`SELECT `+jokeColumns+` FROM (
+			SELECT `+jokeColumns+`, `+strings.Join(matched, ` + `)+` AS matched FROM jokes WHERE deleted_at IS NULL
+		) AS candidates WHERE matched > 0
+		ORDER BY matched DESC, LENGTH(joke) + LENGTH(setup) + LENGTH(delivery), id LIMIT ?`,
+		append(args, limitArg)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	jokes, err := scanJokes(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// ranked in ID order like the other backends, so jokes that score the same come out in the same order
+	slices.SortFunc(jokes, func(a, b models.Jusgo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return paginate(rankSearchResults(jokes, parsed), skip, limit), nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, with the ESCAPE '\' their queries declare.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike returns s as a LIKE pattern that matches s literally.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func (r *sqlRepositoryImpl) Count(ctx context.Context, filter models.JokeFilter) (int64, error) {
+	var count int64
+	where := filterConditions(filter)
+	err := r.queryRow(ctx, `SELECT COUNT(*) FROM jokes`+where.String(), where.args...).Scan(&count)
+	return count, err
+}
+
+// GetSimilar looks the buckets up in the joke_signatures table, which has a row per bucket of every joke.
+func (r *sqlRepositoryImpl) GetSimilar(ctx context.Context, signature []string, limit int64) ([]models.Jusgo, error) {
+	if len(signature) == 0 {
+		return []models.Jusgo{}, nil
+	}
+
+	rows, err := r.query(ctx,
+		`SELECT `+jokeColumns+` FROM jokes WHERE deleted_at IS NULL AND id IN (
+			SELECT joke_id FROM joke_signatures WHERE bucket IN (`+placeholders(len(signature))+`)
+		) ORDER BY id LIMIT ?`,
+		append(stringArgs(signature), limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanJokes(rows)
+}
+
+func (r *sqlRepositoryImpl) SetSignature(ctx context.Context, id string, signature []string) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	return r.RunInTransaction(ctx, func(ctx context.Context) error {
+		var count int64
+		if err := r.queryRow(ctx, `SELECT COUNT(*) FROM jokes WHERE id = ?`, id).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrNotFound
+		}
+		return r.replaceSignature(ctx, id, signature)
+	})
+}
+
+func (r *sqlRepositoryImpl) GetRandom(ctx context.Context, filter models.JokeFilter, count int64, exclude []string) ([]models.Jusgo, error) {
+	if _, err := parseIDs(exclude); err != nil {
+		return nil, err
+	}
+	if count < 1 {
+		return []models.Jusgo{}, nil
+	}
+
+	where := filterConditions(filter)
+	if len(exclude) > 0 {
+		where.add(`id NOT IN (`+placeholders(len(exclude))+`)`, stringArgs(exclude)...)
+	}
+
+	rows, err := r.query(ctx,
+		`SELECT `+jokeColumns+` FROM jokes`+where.String()+` ORDER BY RANDOM() LIMIT ?`,
+		append(where.args, count)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanJokes(rows)
+}
+
+const submissionColumns = `id, type, joke, setup, delivery, category, tags, status, reason, joke_id, created_at, updated_at`
+
+func (r *sqlRepositoryImpl) CreateSubmission(ctx context.Context, data models.Submission) (models.Submission, error) {
+	data = submissionWithDefaults(data)
+	var err error
+	if data.ID, err = assignID(data.ID); err != nil {
+		return data, err
+	}
+	_, err = r.exec(ctx,
+		`INSERT INTO submissions (`+submissionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		data.ID, data.Type, data.Joke, data.Setup, data.Delivery, data.Category, encodeTags(data.Tags),
+		data.Status, data.Reason, data.JokeID, data.CreatedAt.UTC(), data.UpdatedAt.UTC(),
+	)
+	if r.dialect.isUniqueViolation(err) {
+		return data, ErrDuplicateKey
+	}
+	return data, err
+}
+
+func (r *sqlRepositoryImpl) GetSubmission(ctx context.Context, id string) (models.Submission, error) {
+	if _, err := parseID(id); err != nil {
+		return models.Submission{}, err
+	}
+
+	row := r.queryRow(ctx, `SELECT `+submissionColumns+` FROM submissions WHERE id = ?`, id)
+	submission, err := scanSubmission(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Submission{}, ErrNotFound
+	}
+	return submission, err
+}
+
+func (r *sqlRepositoryImpl) UpdateSubmission(ctx context.Context, data models.Submission) (models.Submission, error) {
+	data = submissionWithDefaults(data)
+	var err error
+	if data.ID, err = checkID(data.ID); err != nil {
+		return data, err
+	}
+	result, err := r.exec(ctx,
+		`UPDATE submissions SET type = ?, joke = ?, setup = ?, delivery = ?, category = ?, tags = ?, status = ?, reason = ?, joke_id = ?, created_at = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		data.Type, data.Joke, data.Setup, data.Delivery, data.Category, encodeTags(data.Tags),
+		data.Status, data.Reason, data.JokeID, data.CreatedAt.UTC(), data.UpdatedAt.UTC(), data.ID, models.SubmissionPending,
+	)
+	if err != nil {
+		return data, err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return data, err
+	} else if updated == 0 {
+		var count int64
+		if err := r.queryRow(ctx, `SELECT COUNT(*) FROM submissions WHERE id = ?`, data.ID).Scan(&count); err != nil {
+			return data, err
+		}
+		if count > 0 {
+			return data, ErrNotPending
+		}
+		return data, ErrNotFound
+	}
+	return data, nil
+}
+
+func (r *sqlRepositoryImpl) GetSubmissions(ctx context.Context, status string, skip, limit int64) ([]models.Submission, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	var limitArg any = limit
+	if limit == 0 {
+		limitArg = r.dialect.noLimit
+	}
+
+	where := submissionConditions(status)
+	rows, err := r.query(ctx,
+		`SELECT `+submissionColumns+` FROM submissions`+where.String()+` ORDER BY id LIMIT ? OFFSET ?`,
+		append(where.args, limitArg, skip)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	submissions := []models.Submission{}
+	for rows.Next() {
+		submission, err := scanSubmission(rows)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions, rows.Err()
+}
+
+func (r *sqlRepositoryImpl) CountSubmissions(ctx context.Context, status string) (int64, error) {
+	var count int64
+	where := submissionConditions(status)
+	err := r.queryRow(ctx, `SELECT COUNT(*) FROM submissions`+where.String(), where.args...).Scan(&count)
+	return count, err
+}
+
+func submissionConditions(status string) *conditions {
+	where := &conditions{}
+	if status != "" {
+		where.add(`status = ?`, status)
+	}
+	return where
+}
+
+func (r *sqlRepositoryImpl) AddVote(ctx context.Context, vote models.Vote) error {
+	var err error
+	if vote.JokeID, err = checkID(vote.JokeID); err != nil {
+		return err
+	}
+	_, err = r.exec(ctx,
+		`INSERT INTO votes (joke_id, client, up, window_start, created_at) VALUES (?, ?, ?, ?, ?)`,
+		vote.JokeID, vote.Client, vote.Up, vote.Window.UTC(), vote.CreatedAt.UTC(),
+	)
+	if r.dialect.isUniqueViolation(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
+
+func (r *sqlRepositoryImpl) IncrementVotes(ctx context.Context, id string, up, down int64) (models.Jusgo, error) {
+	if _, err := parseID(id); err != nil {
+		return models.Jusgo{}, err
+	}
+
+	row := r.queryRow(ctx,
+		`UPDATE jokes SET upvotes = upvotes + ?, downvotes = downvotes + ? WHERE id = ? AND deleted_at IS NULL RETURNING `+jokeColumns,
+		up, down, id,
+	)
+	joke, err := scanJoke(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Jusgo{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	// only set the rating if no other vote came in since, that vote's update sets it otherwise
+	joke.Rating = rating.Wilson(joke.Upvotes, joke.Downvotes)
+	_, err = r.exec(ctx,
+		`UPDATE jokes SET rating = ? WHERE id = ? AND upvotes = ? AND downvotes = ?`,
+		joke.Rating, id, joke.Upvotes, joke.Downvotes,
+	)
+	return joke, err
+}
+
+func (r *sqlRepositoryImpl) GetVoteTotals(ctx context.Context, since time.Time) ([]models.VoteTotal, error) {
+	rows, err := r.query(ctx,
+		`SELECT joke_id, SUM(CASE WHEN up THEN 1 ELSE 0 END), SUM(CASE WHEN up THEN 0 ELSE 1 END)
+		FROM votes WHERE created_at >= ? GROUP BY joke_id`,
+		since.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := []models.VoteTotal{}
+	for rows.Next() {
+		var total models.VoteTotal
+		if err := rows.Scan(&total.JokeID, &total.Upvotes, &total.Downvotes); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+// conditions collects the clauses of a WHERE clause together with their arguments.
+type conditions struct {
+	clauses []string
+	args    []any
+}
+
+func (c *conditions) add(clause string, args ...any) {
+	c.clauses = append(c.clauses, clause)
+	c.args = append(c.args, args...)
+}
+
+// String returns the WHERE clause with a leading space, or nothing if there are no conditions.
+func (c *conditions) String() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return ` WHERE ` + strings.Join(c.clauses, ` AND `)
+}
+
+func filterConditions(filter models.JokeFilter) *conditions {
+	where := &conditions{}
+	if filter.Type != "" {
+		where.add(`type = ?`, filter.Type)
+	}
+	if len(filter.Categories) > 0 {
+		where.add(`category IN (`+placeholders(len(filter.Categories))+`)`, stringArgs(filter.Categories)...)
+	}
+
+	if len(filter.Tags) > 0 {
+		// tags are stored as a JSON array, matching the quoted tag can't hit a longer tag
+		// that contains it since tags only come from models.Tags
+		clauses := make([]string, 0, len(filter.Tags))
+		args := make([]any, 0, len(filter.Tags))
+		for _, tag := range filter.Tags {
+			clauses = append(clauses, `tags LIKE ?`)
+			args = append(args, `%"`+tag+`"%`)
+		}
+
+		operator := ` OR `
+		if filter.MatchAllTags {
+			operator = ` AND `
+		}
+		where.add(`(`+strings.Join(clauses, operator)+`)`, args...)
+	}
+
+	if !filter.CreatedAfter.IsZero() {
+		where.add(`created_at > ?`, filter.CreatedAfter.UTC())
+	}
+	if !filter.CreatedBefore.IsZero() {
+		where.add(`created_at < ?`, filter.CreatedBefore.UTC())
+	}
+	if !filter.UpdatedSince.IsZero() {
+		where.add(`updated_at >= ?`, filter.UpdatedSince.UTC())
+	}
+
+	if filter.Unsigned {
+		where.add(`NOT EXISTS (SELECT 1 FROM joke_signatures WHERE joke_signatures.joke_id = jokes.id)`)
+	}
+
+	if filter.Deleted {
+		where.add(`deleted_at IS NOT NULL`)
+	} else {
+		where.add(`deleted_at IS NULL`)
+	}
+	return where
+}
+
+// orderBy returns the ORDER BY list for sort, ties are broken by id so pages never overlap.
+func orderBy(sort models.JokeSort) string {
+	direction := ` ASC`
+	if sort.Descending {
+		direction = ` DESC`
+	}
+
+	switch sort.Field {
+	case models.SortCreatedAt, models.SortUpdatedAt, models.SortRating, models.SortDeletedAt: // only known column names end up in the query
+		return sort.Field + direction + `, id` + direction
+	default:
+		return `id` + direction
+	}
+}
+
+// placeholders returns n comma separated placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat(`?, `, n), `, `)
+}
+
+func stringArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// nullString stores an empty s as NULL, which unlike an empty string doesn't collide in a unique index.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime stores a nil t as NULL.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return `[]`
+	}
+	encoded, _ := json.Marshal(tags) // can't fail for a []string
+	return string(encoded)
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJoke(row scanner) (models.Jusgo, error) {
+	var (
+		joke       models.Jusgo
+		tags       string
+		normalized sql.NullString
+		deletedAt  sql.NullTime
+	)
+	err := row.Scan(
+		&joke.ID, &joke.Type, &joke.Joke, &joke.Setup, &joke.Delivery, &joke.Category, &tags, &joke.CreatedAt, &joke.UpdatedAt, &normalized,
+		&joke.Upvotes, &joke.Downvotes, &joke.Rating, &deletedAt, &joke.Version,
+	)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+
+	if err := json.Unmarshal([]byte(tags), &joke.Tags); err != nil {
+		return models.Jusgo{}, err
+	}
+	if len(joke.Tags) == 0 {
+		joke.Tags = nil // like mongo, no tags decode to nil
+	}
+
+	joke.Normalized = normalized.String
+	if deletedAt.Valid {
+		joke.DeletedAt = &deletedAt.Time
+	}
+
+	return joke, nil
+}
+
+func scanSubmission(row scanner) (models.Submission, error) {
+	var (
+		submission models.Submission
+		tags       string
+	)
+	err := row.Scan(
+		&submission.ID, &submission.Type, &submission.Joke, &submission.Setup, &submission.Delivery, &submission.Category, &tags,
+		&submission.Status, &submission.Reason, &submission.JokeID, &submission.CreatedAt, &submission.UpdatedAt,
+	)
+	if err != nil {
+		return models.Submission{}, err
+	}
+
+	if err := json.Unmarshal([]byte(tags), &submission.Tags); err != nil {
+		return models.Submission{}, err
+	}
+	if len(submission.Tags) == 0 {
+		submission.Tags = nil
+	}
+	return submission, nil
+}
+
+const revisionColumns = `id, joke_id, number, action, old_content, new_content, reverted, actor, request_id, created_at`
+
+func (r *sqlRepositoryImpl) AddRevision(ctx context.Context, rev models.Revision) (models.Revision, error) {
+	var err error
+	if rev.ID, err = assignID(rev.ID); err != nil {
+		return rev, err
+	}
+	if rev.JokeID, err = checkID(rev.JokeID); err != nil {
+		return rev, err
+	}
+	oldContent, err := encodeContent(rev.Old)
+	if err != nil {
+		return rev, err
+	}
+	newContent, err := encodeContent(rev.New)
+	if err != nil {
+		return rev, err
+	}
+
+	// the unique constraint on joke_id and number fails the insert if another revision got the number first
+	err = r.queryRow(ctx, `SELECT COALESCE(MAX(number), 0) + 1 FROM revisions WHERE joke_id = ?`, rev.JokeID).Scan(&rev.Number)
+	if err != nil {
+		return rev, err
+	}
+	_, err = r.exec(ctx,
+		`INSERT INTO revisions (`+revisionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rev.ID, rev.JokeID, rev.Number, rev.Action, oldContent, newContent, rev.Reverted,
+		rev.Actor, rev.RequestID, rev.CreatedAt.UTC(),
+	)
+	return rev, err
+}
+
+func (r *sqlRepositoryImpl) GetRevision(ctx context.Context, jokeID string, number int64) (models.Revision, error) {
+	if _, err := parseID(jokeID); err != nil {
+		return models.Revision{}, err
+	}
+
+	row := r.queryRow(ctx, `SELECT `+revisionColumns+` FROM revisions WHERE joke_id = ? AND number = ?`, jokeID, number)
+	rev, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Revision{}, ErrNotFound
+	}
+	return rev, err
+}
+
+func (r *sqlRepositoryImpl) GetRevisions(ctx context.Context, jokeID string, skip, limit int64) ([]models.Revision, error) {
+	if skip < 0 {
+		return nil, ErrNegativeSkip
+	}
+	if _, err := parseID(jokeID); err != nil {
+		return nil, err
+	}
+
+	var limitArg any = limit
+	if limit == 0 {
+		limitArg = r.dialect.noLimit
+	}
+
+	rows, err := r.query(ctx,
+		`SELECT `+revisionColumns+` FROM revisions WHERE joke_id = ? ORDER BY number LIMIT ? OFFSET ?`,
+		jokeID, limitArg, skip,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (r *sqlRepositoryImpl) CountRevisions(ctx context.Context, jokeID string) (int64, error) {
+	if _, err := parseID(jokeID); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err := r.queryRow(ctx, `SELECT COUNT(*) FROM revisions WHERE joke_id = ?`, jokeID).Scan(&count)
+	return count, err
+}
+
+// encodeContent stores c as JSON, a nil c as NULL.
+func encodeContent(c *models.JokeContent) (sql.NullString, error) {
+	if c == nil {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+// decodeContent is the reverse of encodeContent.
+func decodeContent(s sql.NullString) (*models.JokeContent, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	var c models.JokeContent
+	if err := json.Unmarshal([]byte(s.String), &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func scanRevision(row scanner) (models.Revision, error) {
+	var (
+		rev                    models.Revision
+		oldContent, newContent sql.NullString
+	)
+	err := row.Scan(
+		&rev.ID, &rev.JokeID, &rev.Number, &rev.Action, &oldContent, &newContent, &rev.Reverted,
+		&rev.Actor, &rev.RequestID, &rev.CreatedAt,
+	)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	if rev.Old, err = decodeContent(oldContent); err != nil {
+		return models.Revision{}, err
+	}
+	if rev.New, err = decodeContent(newContent); err != nil {
+		return models.Revision{}, err
+	}
+	return rev, nil
+}
+
+const apiKeyColumns = `id, name, prefix, hash, scopes, created_at, expires_at, revoked_at`
+
+func (r *sqlRepositoryImpl) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	var err error
+	if key.ID, err = assignID(key.ID); err != nil {
+		return key, err
+	}
+	_, err = r.exec(ctx,
+		`INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.Name, key.Prefix, key.Hash, encodeTags(key.Scopes), key.CreatedAt.UTC(),
+		nullTime(key.ExpiresAt), nullTime(key.RevokedAt),
+	)
+	if r.dialect.isUniqueViolation(err) {
+		return key, ErrDuplicateKey
+	}
+	return key, err
+}
+
+func (r *sqlRepositoryImpl) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	key, err := scanAPIKey(r.queryRow(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = ?`, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrNotFound
+	}
+	return key, err
+}
+
+func (r *sqlRepositoryImpl) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := r.query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *sqlRepositoryImpl) RevokeAPIKey(ctx context.Context, id string, at time.Time) (models.APIKey, error) {
+	if _, err := parseID(id); err != nil {
+		return models.APIKey{}, err
+	}
+
+	// COALESCE keeps the time of an earlier revocation
+	key, err := scanAPIKey(r.queryRow(ctx,
+		`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, ?) WHERE id = ? RETURNING `+apiKeyColumns,
+		at.UTC(), id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrNotFound
+	}
+	return key, err
+}
+
+func scanAPIKey(row scanner) (models.APIKey, error) {
+	var (
+		key                  models.APIKey
+		scopes               string
+		expiresAt, revokedAt sql.NullTime
+	)
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return models.APIKey{}, err
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return key, nil
+}
+
+// scanJokes reads every row and closes rows.
+func scanJokes(rows *sql.Rows) ([]models.Jusgo, error) {
+	defer rows.Close()
+
+	jokes := []models.Jusgo{} // initialize it so it will return '[]' instead of null if the list is empty
+	for rows.Next() {
+		joke, err := scanJoke(rows)
+		if err != nil {
+			return nil, err
+		}
+		jokes = append(jokes, joke)
+	}
+
+	return jokes, rows.Err()
+}
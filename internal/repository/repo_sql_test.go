@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebind(t *testing.T) {
+	query := `UPDATE jokes SET joke = ? WHERE id = ? LIMIT ?`
+
+	require.Equal(t, query, sqliteDialect.rebind(query))
+	require.Equal(t, `UPDATE jokes SET joke = $1 WHERE id = $2 LIMIT $3`, postgresDialect.rebind(query))
+}
+
+func TestEscapeLike(t *testing.T) {
+	require.Equal(t, `100\% \_id\\`, escapeLike(`100% _id\`))
+	require.Equal(t, "query", escapeLike("query"))
+}
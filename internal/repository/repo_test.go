@@ -1,141 +1,129 @@
-package repository
+package repository_test
 
 import (
 	"context"
+	"database/sql"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/database"
 	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"github.com/zde37/Jusgo/internal/repository/repositorytest"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func TestCreate(t *testing.T) {
-	createJoke(t, context.Background())
-}
-
-func TestGet(t *testing.T) {
-	ctx := context.Background()
-	data := createJoke(t, ctx)
+func TestMongoConformance(t *testing.T) {
+	if testDB == nil {
+		t.Skip("DB_SOURCE is not a mongodb uri")
+	}
 
-	joke, err := testRepo.Repo.Get(ctx, data.ID)
-	require.NoError(t, err)
-	require.NotEmpty(t, joke)
-	require.Equal(t, data.ID, joke.ID)
-	require.Equal(t, data.Joke, joke.Joke)
-	require.Equal(t, data.UpdatedAt.Unix(), joke.UpdatedAt.Unix())
-	require.Equal(t, data.CreatedAt.Unix(), joke.CreatedAt.Unix())
+	repositorytest.RunConformance(t, func(t *testing.T) repository.RepositoryProvider {
+		// every test gets its own collection so they can't see each other's jokes
+		col := testDB.Collection("Test_" + primitive.NewObjectID().Hex())
+		submissions := testDB.Collection(col.Name() + "_submissions")
+		votes := testDB.Collection(col.Name() + "_votes")
+		revisions := testDB.Collection(col.Name() + "_revisions")
+		apiKeys := testDB.Collection(col.Name() + "_api_keys")
+		t.Cleanup(func() {
+			col.Drop(context.Background())
+			submissions.Drop(context.Background())
+			votes.Drop(context.Background())
+			revisions.Drop(context.Background())
+			apiKeys.Drop(context.Background())
+		})
+		require.NoError(t, database.CreateMongoIndexes(context.Background(), col, submissions, votes, revisions, apiKeys))
+		return repository.NewRepository(col, submissions, votes, revisions, apiKeys).Repo
+	})
 }
 
-func TestUpdate(t *testing.T) {
-	ctx := context.Background()
-	data := createJoke(t, ctx)
+func TestMongoBackfillsCategory(t *testing.T) {
+	if testDB == nil {
+		t.Skip("DB_SOURCE is not a mongodb uri")
+	}
 
-	data.Joke = "I used to know a joke about Java...but I ran out of memory"
-	data.UpdatedAt = time.Now()
+	ctx := context.Background()
+	col := testDB.Collection("Test_" + primitive.NewObjectID().Hex())
+	others := []*mongo.Collection{
+		testDB.Collection(col.Name() + "_submissions"), testDB.Collection(col.Name() + "_votes"),
+		testDB.Collection(col.Name() + "_revisions"), testDB.Collection(col.Name() + "_api_keys"),
+	}
+	t.Cleanup(func() {
+		for _, c := range append(others, col) {
+			c.Drop(context.Background())
+		}
+	})
+
+	// a joke stored before categories existed
+	id := primitive.NewObjectID()
+	_, err := col.InsertOne(ctx, bson.M{"_id": id, "joke": "legacy", "created_at": time.Now(), "updated_at": time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, database.CreateMongoIndexes(ctx, col, others[0], others[1], others[2], others[3]))
 
-	updatedJoke, err := testRepo.Repo.Update(ctx, data)
+	repo := repository.NewRepository(col, others[0], others[1], others[2], others[3]).Repo
+	jokes, err := repo.GetAll(ctx, models.JokeFilter{Categories: []string{models.DefaultCategory}}, models.JokeSort{}, 0, 0)
 	require.NoError(t, err)
-	require.NotEmpty(t, updatedJoke)
-	require.Equal(t, data.ID, updatedJoke.ID)
-	require.Equal(t, data.Joke, updatedJoke.Joke)
-	require.Equal(t, data.UpdatedAt.Unix(), updatedJoke.UpdatedAt.Unix())
-	require.Equal(t, data.CreatedAt.Unix(), updatedJoke.CreatedAt.Unix())
+	require.Len(t, jokes, 1)
+	require.Equal(t, id.Hex(), jokes[0].ID)
 }
 
-func TestGetAll(t *testing.T) {
-	ctx := context.Background()
-	for range 20 {
-		createJoke(t, ctx)
-	}
-
-	testData := []struct {
-		Name  string
-		limit int64
-		page  int64
-		stub  func(t *testing.T, limit, page int64)
-	}{
-		{
-			Name:  "Fetch 10 jokes from page 1",
-			limit: 10,
-			page:  1,
-			stub: func(t *testing.T, limit, page int64) {
-				skip := (page - 1) * limit
-				jokes, err := testRepo.Repo.GetAll(ctx, skip, limit)
-				require.NoError(t, err)
-				require.NotEmpty(t, jokes)
-				require.Len(t, jokes, 10)
-
-				for _, joke := range jokes {
-					require.NotEmpty(t, joke)
-				}
-			},
-		},
-		{
-			Name:  "Fetch 10 jokes from page 2",
-			limit: 10,
-			page:  2,
-			stub: func(t *testing.T, limit, page int64) {
-				skip := (page - 1) * limit
-				jokes, err := testRepo.Repo.GetAll(ctx, skip, limit)
-				require.NoError(t, err)
-				require.NotEmpty(t, jokes)
-				require.Len(t, jokes, 10)
-
-				for _, joke := range jokes {
-					require.NotEmpty(t, joke)
-				}
-			},
-		},
-		{
-			Name:  "Fetch 5 jokes from page 1",
-			limit: 5,
-			page:  1,
-			stub: func(t *testing.T, limit, page int64) {
-				skip := (page - 1) * limit
-				jokes, err := testRepo.Repo.GetAll(ctx, skip, limit)
-				require.NoError(t, err)
-				require.NotEmpty(t, jokes)
-				require.Len(t, jokes, 5)
-
-				for _, joke := range jokes {
-					require.NotEmpty(t, joke)
-				}
-			},
-		},
-	}
+func TestMemoryConformance(t *testing.T) {
+	repositorytest.RunConformance(t, func(t *testing.T) repository.RepositoryProvider {
+		return repository.NewMemoryRepository().Repo
+	})
+}
 
-	for _, tc := range testData {
-		t.Run(tc.Name, func(t *testing.T) {
-			tc.stub(t, tc.limit, tc.page)
+func TestSQLiteConformance(t *testing.T) {
+	repositorytest.RunConformance(t, func(t *testing.T) repository.RepositoryProvider {
+		db, err := database.ConnectToSQLite(filepath.Join(t.TempDir(), "jusgo.db"), context.Background())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			db.Close()
 		})
-	}
+		return repository.NewSQLiteRepository(db).Repo
+	})
 }
 
-func TestDelete(t *testing.T) {
-	ctx := context.Background()
-	joke := createJoke(t, ctx)
+func TestPostgresConformance(t *testing.T) {
+	source := os.Getenv("POSTGRES_SOURCE")
+	if source == "" {
+		t.Skip("POSTGRES_SOURCE is not set")
+	}
 
-	err := testRepo.Repo.Delete(ctx, joke.ID)
+	ctx := context.Background()
+	admin, err := sql.Open("postgres", source)
 	require.NoError(t, err)
-
-	deletedJoke, err := testRepo.Repo.Get(ctx, joke.ID)
-	require.Error(t, err)
-	require.Empty(t, deletedJoke)
+	defer admin.Close()
+
+	repositorytest.RunConformance(t, func(t *testing.T) repository.RepositoryProvider {
+		// every test migrates its own schema so they can't see each other's jokes
+		schema := "test_" + primitive.NewObjectID().Hex()
+		_, err := admin.ExecContext(ctx, `CREATE SCHEMA `+schema)
+		require.NoError(t, err)
+
+		db, err := database.ConnectToPostgres(withSearchPath(t, source, schema), ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			db.Close()
+			admin.ExecContext(ctx, `DROP SCHEMA `+schema+` CASCADE`)
+		})
+		return repository.NewPostgresRepository(db).Repo
+	})
 }
 
-func createJoke(t *testing.T, ctx context.Context) models.Jusgo {
-	data := models.Jusgo{
-		ID:        primitive.NewObjectID(),
-		Joke:      "I'm declaring a war. var war",
-		UpdatedAt: time.Now(),
-		CreatedAt: time.Now(),
-	}
-
-	joke, err := testRepo.Repo.Create(ctx, data)
+// withSearchPath sets the schema new connections use on a postgres uri.
+func withSearchPath(t *testing.T, source, schema string) string {
+	u, err := url.Parse(source)
 	require.NoError(t, err)
-	require.NotEmpty(t, joke)
-	require.Equal(t, data, joke)
 
-	return joke
-} 
\ No newline at end of file
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
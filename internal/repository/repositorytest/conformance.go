@@ -0,0 +1,1717 @@
+// Package repositorytest holds a test suite that every repository.RepositoryProvider
+// implementation must pass, so all storage backends behave the same way.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/rating"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+// Factory returns an empty provider for a single test. Backends that hold
+// resources should release them with t.Cleanup.
+type Factory func(t *testing.T) repository.RepositoryProvider
+
+// RunConformance runs the whole suite, every case gets a fresh provider from newRepo.
+func RunConformance(t *testing.T, newRepo Factory) {
+	testData := []struct {
+		Name string
+		stub func(t *testing.T, repo repository.RepositoryProvider)
+	}{
+		{Name: "Create and get", stub: testCreateGet},
+		{Name: "Create duplicate ID", stub: testCreateDuplicate},
+		{Name: "Create gives an ID", stub: testCreateGivesID},
+		{Name: "Create many", stub: testCreateMany},
+		{Name: "Create many in a transaction", stub: testCreateManyInTransaction},
+		{Name: "Normalized text is unique", stub: testNormalizedUnique},
+		{Name: "Get by normalized text", stub: testGetByNormalized},
+		{Name: "Similar jokes", stub: testGetSimilar},
+		{Name: "Set signature", stub: testSetSignature},
+		{Name: "Two part joke", stub: testTwoPart},
+		{Name: "Missing type defaults to single", stub: testDefaultType},
+		{Name: "Missing category defaults to general", stub: testDefaultCategory},
+		{Name: "Get missing ID", stub: testGetMissing},
+		{Name: "Invalid ID", stub: testInvalidID},
+		{Name: "Update", stub: testUpdate},
+		{Name: "Update missing ID", stub: testUpdateMissing},
+		{Name: "Update changes the type", stub: testUpdateChangesType},
+		{Name: "Update stale version", stub: testUpdateStaleVersion},
+		{Name: "Votes don't change the version", stub: testVersionNotChangedByVotes},
+		{Name: "Delete", stub: testDelete},
+		{Name: "Delete is idempotent", stub: testDeleteTwice},
+		{Name: "Get all from empty store", stub: testGetAllEmpty},
+		{Name: "Get all pages", stub: testGetAllPages},
+		{Name: "Negative skip", stub: testNegativeSkip},
+		{Name: "Get all ordering", stub: testGetAllOrdering},
+		{Name: "Sort", stub: testSort},
+		{Name: "Filter by dates", stub: testDateRange},
+		{Name: "Get after", stub: testGetAfter},
+		{Name: "Get after with filter", stub: testGetAfterFilter},
+		{Name: "Count", stub: testCount},
+		{Name: "Filter by category and tags", stub: testFilter},
+		{Name: "Search", stub: testSearch},
+		{Name: "Search pages", stub: testSearchPages},
+		{Name: "Get random", stub: testGetRandom},
+		{Name: "Get random with exclusions", stub: testGetRandomExclude},
+		{Name: "Get random from empty store", stub: testGetRandomEmpty},
+		{Name: "Canceled context", stub: testCanceledContext},
+		{Name: "Submissions", stub: testSubmissions},
+		{Name: "Submissions by status", stub: testSubmissionsByStatus},
+		{Name: "Votes", stub: testVotes},
+		{Name: "Vote totals", stub: testVoteTotals},
+		{Name: "Sort by rating", stub: testSortRating},
+		{Name: "Deleted jokes are hidden", stub: testTrashHidden},
+		{Name: "Trash listing", stub: testTrashListing},
+		{Name: "Restore", stub: testRestore},
+		{Name: "Purge", stub: testPurge},
+		{Name: "Purge deleted before", stub: testPurgeDeletedBefore},
+		{Name: "Transaction commits", stub: testTransactionCommit},
+		{Name: "Transaction rolls back", stub: testTransactionRollback},
+		{Name: "Revisions", stub: testRevisions},
+		{Name: "Revisions are numbered per joke", stub: testRevisionNumbers},
+		{Name: "API keys", stub: testAPIKeys},
+		{Name: "Revoke API key", stub: testRevokeAPIKey},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.stub(t, newRepo(t))
+		})
+	}
+}
+
+func testCreateGet(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := CreateJoke(t, ctx, repo)
+
+	joke, err := repo.Get(ctx, data.ID)
+	require.NoError(t, err)
+	RequireJokeEqual(t, data, joke)
+}
+
+func testCreateDuplicate(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := CreateJoke(t, ctx, repo)
+
+	data.Joke = "Same ID, different joke"
+	_, err := repo.Create(ctx, data)
+	require.ErrorIs(t, err, repository.ErrDuplicateKey)
+}
+
+func testCreateGivesID(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := NewJoke()
+	data.ID = ""
+
+	joke, err := repo.Create(ctx, data)
+	require.NoError(t, err)
+	require.NotEmpty(t, joke.ID)
+	got, err := repo.Get(ctx, joke.ID)
+	require.NoError(t, err)
+	RequireJokeEqual(t, joke, got)
+
+	batch := []models.Jusgo{NewJoke(), NewJoke()}
+	batch[0].ID, batch[0].Joke = "", "No ID yet"
+	batch[1].ID, batch[1].Joke = "not-an-id", "A bad ID"
+	errs, err := repo.CreateMany(ctx, batch)
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	require.ErrorIs(t, errs[1], repository.ErrInvalidID)
+	require.NotEmpty(t, batch[0].ID) // set in place
+	got, err = repo.Get(ctx, batch[0].ID)
+	require.NoError(t, err)
+	RequireJokeEqual(t, batch[0], got)
+
+	data.ID = "not-an-id"
+	_, err = repo.Create(ctx, data)
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+}
+
+func testCreateMany(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	existing := CreateJoke(t, ctx, repo)
+
+	twoPart := NewJoke()
+	twoPart.Type, twoPart.Joke, twoPart.Setup, twoPart.Delivery = models.TypeTwoPart, "", "Why?", "Because."
+	fresh := []models.Jusgo{NewJoke(), twoPart, NewJoke()}
+	batch := []models.Jusgo{fresh[0], existing, fresh[1], fresh[1], fresh[2]}
+
+	errs, err := repo.CreateMany(ctx, batch)
+	require.NoError(t, err)
+	require.Len(t, errs, len(batch))
+	require.NoError(t, errs[0])
+	require.ErrorIs(t, errs[1], repository.ErrDuplicateKey)
+	require.NoError(t, errs[2])
+	require.ErrorIs(t, errs[3], repository.ErrDuplicateKey) // repeated within the batch
+	require.NoError(t, errs[4])
+
+	for _, want := range fresh {
+		got, err := repo.Get(ctx, want.ID)
+		require.NoError(t, err)
+		RequireJokeEqual(t, want, got)
+	}
+
+	count, err := repo.Count(ctx, models.JokeFilter{})
+	require.NoError(t, err)
+	require.EqualValues(t, 4, count)
+
+	errs, err = repo.CreateMany(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, errs)
+}
+
+func testCreateManyInTransaction(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	existing := NewJoke()
+	existing.Normalized = "im declaring a war var war"
+	_, err := repo.Create(ctx, existing)
+	require.NoError(t, err)
+
+	sameText := NewJoke()
+	sameText.Normalized = existing.Normalized
+	fresh := NewJoke()
+	fresh.Normalized = "something else"
+	batch := []models.Jusgo{existing, sameText, fresh}
+
+	// duplicates fail on their own without aborting the transaction, the rest is stored with it
+	var errs []error
+	err = repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if errs, err = repo.CreateMany(ctx, batch); err != nil {
+			return err
+		}
+		_, err = repo.AddRevision(ctx, NewRevision(fresh.ID))
+		return err
+	})
+	require.NoError(t, err)
+	require.ErrorIs(t, errs[0], repository.ErrDuplicateKey)
+	require.ErrorIs(t, errs[1], repository.ErrDuplicateKey)
+	require.NoError(t, errs[2])
+
+	_, err = repo.Get(ctx, fresh.ID)
+	require.NoError(t, err)
+	revisions, err := repo.GetRevisions(ctx, fresh.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+}
+
+func testNormalizedUnique(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	withNormalized := func(normalized string) models.Jusgo {
+		joke := NewJoke()
+		joke.Normalized = normalized
+		return joke
+	}
+
+	first := withNormalized("im declaring a war var war")
+	_, err := repo.Create(ctx, first)
+	require.NoError(t, err)
+
+	got, err := repo.Get(ctx, first.ID)
+	require.NoError(t, err)
+	RequireJokeEqual(t, first, got)
+
+	_, err = repo.Create(ctx, withNormalized(first.Normalized))
+	require.ErrorIs(t, err, repository.ErrDuplicateKey)
+
+	errs, err := repo.CreateMany(ctx, []models.Jusgo{withNormalized(first.Normalized), withNormalized("something else")})
+	require.NoError(t, err)
+	require.ErrorIs(t, errs[0], repository.ErrDuplicateKey)
+	require.NoError(t, errs[1])
+
+	// jokes without normalized text never collide
+	for range 2 {
+		_, err = repo.Create(ctx, withNormalized(""))
+		require.NoError(t, err)
+	}
+
+	// updating a joke to the text of another one fails, keeping its own text doesn't
+	second := withNormalized("a different joke")
+	_, err = repo.Create(ctx, second)
+	require.NoError(t, err)
+
+	second.Normalized = first.Normalized
+	_, err = repo.Update(ctx, second)
+	require.ErrorIs(t, err, repository.ErrDuplicateKey)
+
+	first.Joke = "I'm declaring a war! var war"
+	_, err = repo.Update(ctx, first)
+	require.NoError(t, err)
+}
+
+func testGetByNormalized(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	joke := NewJoke()
+	joke.Normalized = "im declaring a war var war"
+	_, err := repo.Create(ctx, joke)
+	require.NoError(t, err)
+	CreateJoke(t, ctx, repo) // without normalized text
+
+	got, err := repo.GetByNormalized(ctx, joke.Normalized)
+	require.NoError(t, err)
+	RequireJokeEqual(t, joke, got)
+
+	_, err = repo.GetByNormalized(ctx, "something else")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetByNormalized(ctx, "")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// jokes in the trash don't count
+	require.NoError(t, repo.Delete(ctx, joke.ID, joke.Version))
+	_, err = repo.GetByNormalized(ctx, joke.Normalized)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testGetSimilar(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	withSignature := func(signature ...string) models.Jusgo {
+		joke := NewJoke()
+		joke.Signature = signature
+		_, err := repo.Create(ctx, joke)
+		require.NoError(t, err)
+		return joke
+	}
+	similarIDs := func(signature ...string) []string {
+		jokes, err := repo.GetSimilar(ctx, signature, 10)
+		require.NoError(t, err)
+		ids := []string{}
+		for _, joke := range jokes {
+			ids = append(ids, joke.ID)
+		}
+		return ids
+	}
+
+	a := withSignature("00:a", "01:b")
+	b := withSignature("01:b", "02:c")
+	withSignature("03:d")
+	deleted := withSignature("00:a")
+	require.NoError(t, repo.Delete(ctx, deleted.ID, deleted.Version))
+	withSignature()
+
+	require.ElementsMatch(t, []string{a.ID}, similarIDs("00:a"))
+	require.ElementsMatch(t, []string{a.ID, b.ID}, similarIDs("01:b", "02:c"))
+	require.Empty(t, similarIDs("04:e"))
+	require.Empty(t, similarIDs())
+
+	jokes, err := repo.GetSimilar(ctx, []string{"01:b"}, 1)
+	require.NoError(t, err)
+	require.Len(t, jokes, 1)
+
+	// an update replaces the signature
+	a.Signature = []string{"05:f"}
+	_, err = repo.Update(ctx, a)
+	require.NoError(t, err)
+	require.Empty(t, similarIDs("00:a"))
+	require.ElementsMatch(t, []string{a.ID}, similarIDs("05:f"))
+
+	errs, err := repo.CreateMany(ctx, []models.Jusgo{{ID: repository.NewID(), Joke: "Batch", Signature: []string{"06:g"}}})
+	require.NoError(t, err)
+	require.NoError(t, errs[0])
+	require.Len(t, similarIDs("06:g"), 1)
+}
+
+func testSetSignature(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	unsigned := CreateJoke(t, ctx, repo)
+	signed := NewJoke()
+	signed.Signature = []string{"00:a"}
+	_, err := repo.Create(ctx, signed)
+	require.NoError(t, err)
+	trashed := CreateJoke(t, ctx, repo)
+	require.NoError(t, repo.Delete(ctx, trashed.ID, trashed.Version))
+
+	jokes, err := repo.GetAfter(ctx, models.JokeFilter{Unsigned: true}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, jokes, 1)
+	require.Equal(t, unsigned.ID, jokes[0].ID)
+	count, err := repo.Count(ctx, models.JokeFilter{Unsigned: true, Deleted: true})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+
+	require.NoError(t, repo.SetSignature(ctx, unsigned.ID, []string{"00:a", "01:b"}))
+	require.NoError(t, repo.SetSignature(ctx, trashed.ID, []string{"00:a"}))
+	count, err = repo.Count(ctx, models.JokeFilter{Unsigned: true})
+	require.NoError(t, err)
+	require.Zero(t, count)
+	count, err = repo.Count(ctx, models.JokeFilter{Unsigned: true, Deleted: true})
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	jokes, err = repo.GetSimilar(ctx, []string{"00:a"}, 10)
+	require.NoError(t, err)
+	require.Len(t, jokes, 2)
+
+	// neither the version nor the update time change
+	got, err := repo.Get(ctx, unsigned.ID)
+	require.NoError(t, err)
+	RequireJokeEqual(t, unsigned, got)
+
+	require.NoError(t, repo.SetSignature(ctx, signed.ID, nil))
+	jokes, err = repo.GetAfter(ctx, models.JokeFilter{Unsigned: true}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, jokes, 1)
+	require.Equal(t, signed.ID, jokes[0].ID)
+
+	require.ErrorIs(t, repo.SetSignature(ctx, repository.NewID(), []string{"00:a"}), repository.ErrNotFound)
+	require.ErrorIs(t, repo.SetSignature(ctx, "invalid", nil), repository.ErrInvalidID)
+}
+
+func testTwoPart(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := NewJoke()
+	data.Type, data.Joke = models.TypeTwoPart, ""
+	data.Setup, data.Delivery = "Why do programmers prefer dark mode?", "Because light attracts bugs."
+
+	_, err := repo.Create(ctx, data)
+	require.NoError(t, err)
+
+	joke, err := repo.Get(ctx, data.ID)
+	require.NoError(t, err)
+	RequireJokeEqual(t, data, joke)
+
+	single := CreateJoke(t, ctx, repo)
+	for _, tc := range []struct {
+		jokeType string
+		want     models.Jusgo
+	}{
+		{jokeType: models.TypeTwoPart, want: data},
+		{jokeType: models.TypeSingle, want: single},
+	} {
+		jokes, err := repo.GetAll(ctx, models.JokeFilter{Type: tc.jokeType}, models.JokeSort{}, 0, 0)
+		require.NoError(t, err)
+		require.Len(t, jokes, 1)
+		RequireJokeEqual(t, tc.want, jokes[0])
+	}
+}
+
+func testDefaultType(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := NewJoke()
+	data.Type = ""
+
+	_, err := repo.Create(ctx, data)
+	require.NoError(t, err)
+
+	joke, err := repo.Get(ctx, data.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.TypeSingle, joke.Type)
+
+	count, err := repo.Count(ctx, models.JokeFilter{Type: models.TypeSingle})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+func testDefaultCategory(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := NewJoke()
+	data.Category = ""
+
+	_, err := repo.Create(ctx, data)
+	require.NoError(t, err)
+
+	joke, err := repo.Get(ctx, data.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.DefaultCategory, joke.Category)
+
+	count, err := repo.Count(ctx, models.JokeFilter{Categories: []string{models.DefaultCategory}})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+func testGetMissing(t *testing.T, repo repository.RepositoryProvider) {
+	joke, err := repo.Get(context.Background(), repository.NewID())
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.Empty(t, joke)
+}
+
+func testInvalidID(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+
+	_, err := repo.Get(ctx, "not-an-id")
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+
+	err = repo.Delete(ctx, "not-an-id", 0)
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+}
+
+func testUpdate(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := CreateJoke(t, ctx, repo)
+
+	voted, err := repo.IncrementVotes(ctx, data.ID, 2, 1)
+	require.NoError(t, err)
+
+	// the update doesn't know the creation time or votes, the stored ones are kept
+	update := data
+	update.Joke = "I used to know a joke about Java...but I ran out of memory"
+	update.UpdatedAt = time.Now().Add(time.Minute)
+	update.CreatedAt = time.Time{}
+
+	updatedJoke, err := repo.Update(ctx, update)
+	require.NoError(t, err)
+	data = voted
+	data.Joke, data.UpdatedAt, data.Version = update.Joke, update.UpdatedAt, 1
+	RequireJokeEqual(t, data, updatedJoke)
+
+	joke, err := repo.Get(ctx, data.ID)
+	require.NoError(t, err)
+	RequireJokeEqual(t, data, joke)
+}
+
+func testUpdateStaleVersion(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	stored := CreateJoke(t, ctx, repo)
+
+	first := stored
+	first.Joke = "first edit"
+	first, err := repo.Update(ctx, first)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), first.Version)
+
+	// a second edit based on the version before the first one loses
+	second := stored
+	second.Joke = "second edit"
+	_, err = repo.Update(ctx, second)
+	require.ErrorIs(t, err, repository.ErrVersionConflict)
+	RequireJokeEqual(t, first, mustGet(t, ctx, repo, stored.ID))
+
+	// as does a delete
+	require.ErrorIs(t, repo.Delete(ctx, stored.ID, stored.Version), repository.ErrVersionConflict)
+	require.NoError(t, repo.Delete(ctx, stored.ID, first.Version))
+	deleted, err := repo.GetDeleted(ctx, stored.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted.Version)
+}
+
+func testVersionNotChangedByVotes(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	stored := CreateJoke(t, ctx, repo)
+
+	voted, err := repo.IncrementVotes(ctx, stored.ID, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, stored.Version, voted.Version)
+
+	// so an edit started before the vote still goes through
+	stored.Joke = "edited"
+	_, err = repo.Update(ctx, stored)
+	require.NoError(t, err)
+}
+
+func testUpdateMissing(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := NewJoke()
+
+	_, err := repo.Update(ctx, data)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// updates never create the joke
+	_, err = repo.Get(ctx, data.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testUpdateChangesType(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := NewJoke()
+	data.Type, data.Joke = models.TypeTwoPart, ""
+	data.Setup, data.Delivery = "Why do Java developers wear glasses?", "Because they don't C#."
+	data.Tags = []string{"pun"}
+	_, err := repo.Create(ctx, data)
+	require.NoError(t, err)
+
+	// the setup, delivery and tags of the two part joke don't linger
+	data.Type, data.Joke = models.TypeSingle, "There are 10 kinds of people"
+	data.Setup, data.Delivery, data.Tags = "", "", nil
+	updated, err := repo.Update(ctx, data)
+	require.NoError(t, err)
+	data.Version = 1
+	RequireJokeEqual(t, data, updated)
+	RequireJokeEqual(t, data, mustGet(t, ctx, repo, data.ID))
+}
+
+func testDelete(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	joke := CreateJoke(t, ctx, repo)
+	other := CreateJoke(t, ctx, repo)
+
+	err := repo.Delete(ctx, joke.ID, 0)
+	require.NoError(t, err)
+
+	deletedJoke, err := repo.Get(ctx, joke.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.Empty(t, deletedJoke)
+
+	_, err = repo.Get(ctx, other.ID)
+	require.NoError(t, err)
+}
+
+func testDeleteTwice(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	joke := CreateJoke(t, ctx, repo)
+
+	require.NoError(t, repo.Delete(ctx, joke.ID, 0))
+	require.NoError(t, repo.Delete(ctx, joke.ID, 0))
+	require.NoError(t, repo.Delete(ctx, repository.NewID(), 0))
+}
+
+func testGetAllEmpty(t *testing.T, repo repository.RepositoryProvider) {
+	jokes, err := repo.GetAll(context.Background(), models.JokeFilter{}, models.JokeSort{}, 0, 10)
+	require.NoError(t, err)
+	require.NotNil(t, jokes) // encodes as '[]' instead of null
+	require.Empty(t, jokes)
+}
+
+// testNegativeSkip checks that every paged listing rejects a negative skip alike, page 0 would ask for one.
+func testNegativeSkip(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	joke := CreateJoke(t, ctx, repo)
+
+	_, err := repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{}, -10, 10)
+	require.ErrorIs(t, err, repository.ErrNegativeSkip)
+	_, err = repo.Search(ctx, joke.Joke, -10, 10)
+	require.ErrorIs(t, err, repository.ErrNegativeSkip)
+	_, err = repo.GetSubmissions(ctx, "", -10, 10)
+	require.ErrorIs(t, err, repository.ErrNegativeSkip)
+	_, err = repo.GetRevisions(ctx, joke.ID, -10, 10)
+	require.ErrorIs(t, err, repository.ErrNegativeSkip)
+}
+
+func testGetAllPages(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	for range 25 {
+		CreateJoke(t, ctx, repo)
+	}
+
+	testData := []struct {
+		Name  string
+		limit int64
+		page  int64
+		want  int
+	}{
+		{Name: "Fetch 10 jokes from page 1", limit: 10, page: 1, want: 10},
+		{Name: "Fetch 10 jokes from page 2", limit: 10, page: 2, want: 10},
+		{Name: "Fetch the last partial page", limit: 10, page: 3, want: 5},
+		{Name: "Fetch past the last page", limit: 10, page: 4, want: 0},
+		{Name: "Fetch 5 jokes from page 1", limit: 5, page: 1, want: 5},
+		{Name: "Fetch a page bigger than the store", limit: 100, page: 1, want: 25},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			skip := (tc.page - 1) * tc.limit
+			jokes, err := repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{}, skip, tc.limit)
+			require.NoError(t, err)
+			require.NotNil(t, jokes)
+			require.Len(t, jokes, tc.want)
+
+			for _, joke := range jokes {
+				require.NotEmpty(t, joke)
+			}
+		})
+	}
+}
+
+func testGetAllOrdering(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	created := make([]models.Jusgo, 0, 12)
+	for range 12 {
+		created = append(created, CreateJoke(t, ctx, repo))
+	}
+
+	// pages must not overlap and together list every joke in creation order
+	var paged []models.Jusgo
+	for skip := int64(0); skip < 12; skip += 5 {
+		jokes, err := repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{}, skip, 5)
+		require.NoError(t, err)
+		paged = append(paged, jokes...)
+	}
+
+	require.Len(t, paged, len(created))
+	for i := range created {
+		RequireJokeEqual(t, created[i], paged[i])
+	}
+}
+
+// createDated stores a new joke with the given timestamps, to the millisecond since that is all mongo stores.
+func createDated(t *testing.T, ctx context.Context, repo repository.RepositoryProvider, created, updated time.Time) models.Jusgo {
+	t.Helper()
+	joke := NewJoke()
+	joke.CreatedAt, joke.UpdatedAt = created.Truncate(time.Millisecond), updated.Truncate(time.Millisecond)
+	_, err := repo.Create(ctx, joke)
+	require.NoError(t, err)
+	return joke
+}
+
+func testSort(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	base := time.Now().Add(-24 * time.Hour)
+
+	// timestamps out of ID order, c and d are created at the same time
+	a := createDated(t, ctx, repo, base.Add(2*time.Hour), base)
+	b := createDated(t, ctx, repo, base, base.Add(3*time.Hour))
+	c := createDated(t, ctx, repo, base.Add(time.Hour), base.Add(time.Hour))
+	d := createDated(t, ctx, repo, base.Add(time.Hour), base.Add(2*time.Hour))
+
+	testData := []struct {
+		Name  string
+		sort  models.JokeSort
+		skip  int64
+		limit int64
+		want  []models.Jusgo
+	}{
+		{Name: "By ID", sort: models.JokeSort{}, want: []models.Jusgo{a, b, c, d}},
+		{Name: "By ID descending", sort: models.JokeSort{Descending: true}, want: []models.Jusgo{d, c, b, a}},
+		{Name: "Oldest first", sort: models.JokeSort{Field: models.SortCreatedAt}, want: []models.Jusgo{b, c, d, a}},
+		{Name: "Newest first", sort: models.JokeSort{Field: models.SortCreatedAt, Descending: true}, want: []models.Jusgo{a, d, c, b}},
+		{Name: "Least recently updated first", sort: models.JokeSort{Field: models.SortUpdatedAt}, want: []models.Jusgo{a, c, d, b}},
+		{Name: "Most recently updated first", sort: models.JokeSort{Field: models.SortUpdatedAt, Descending: true}, want: []models.Jusgo{b, d, c, a}},
+		{Name: "Page of a sorted listing", sort: models.JokeSort{Field: models.SortCreatedAt}, skip: 1, limit: 2, want: []models.Jusgo{c, d}},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			jokes, err := repo.GetAll(ctx, models.JokeFilter{}, tc.sort, tc.skip, tc.limit)
+			require.NoError(t, err)
+			require.Len(t, jokes, len(tc.want))
+			for i := range tc.want {
+				RequireJokeEqual(t, tc.want[i], jokes[i])
+			}
+		})
+	}
+}
+
+func testDateRange(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Millisecond)
+
+	a := createDated(t, ctx, repo, base, base.Add(3*time.Hour))
+	b := createDated(t, ctx, repo, base.Add(time.Hour), base.Add(time.Hour))
+	c := createDated(t, ctx, repo, base.Add(2*time.Hour), base.Add(2*time.Hour))
+
+	testData := []struct {
+		Name   string
+		filter models.JokeFilter
+		want   []models.Jusgo
+	}{
+		{Name: "Created after is exclusive", filter: models.JokeFilter{CreatedAfter: base}, want: []models.Jusgo{b, c}},
+		{Name: "Created before is exclusive", filter: models.JokeFilter{CreatedBefore: base.Add(2 * time.Hour)}, want: []models.Jusgo{a, b}},
+		{Name: "Created between", filter: models.JokeFilter{CreatedAfter: base, CreatedBefore: base.Add(2 * time.Hour)}, want: []models.Jusgo{b}},
+		{Name: "Updated since is inclusive", filter: models.JokeFilter{UpdatedSince: base.Add(2 * time.Hour)}, want: []models.Jusgo{a, c}},
+		{Name: "Dates and category", filter: models.JokeFilter{CreatedAfter: base, Categories: []string{"git"}}, want: []models.Jusgo{}},
+		{Name: "Nothing in range", filter: models.JokeFilter{CreatedAfter: base.Add(time.Hour), CreatedBefore: base.Add(2 * time.Hour)}, want: []models.Jusgo{}},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			jokes, err := repo.GetAll(ctx, tc.filter, models.JokeSort{}, 0, 0)
+			require.NoError(t, err)
+			require.Len(t, jokes, len(tc.want))
+			for i := range tc.want {
+				RequireJokeEqual(t, tc.want[i], jokes[i])
+			}
+
+			count, err := repo.Count(ctx, tc.filter)
+			require.NoError(t, err)
+			require.EqualValues(t, len(tc.want), count)
+		})
+	}
+}
+
+func testGetAfter(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	created := make([]models.Jusgo, 0, 12)
+	for range 12 {
+		created = append(created, CreateJoke(t, ctx, repo))
+	}
+
+	// walk the store 5 at a time, deleting a joke that was already listed and adding a new
+	// one mid-scan must neither repeat nor skip anything
+	var paged []models.Jusgo
+	after := ""
+	for {
+		jokes, err := repo.GetAfter(ctx, models.JokeFilter{}, after, 5)
+		require.NoError(t, err)
+		require.NotNil(t, jokes)
+		if len(jokes) == 0 {
+			break
+		}
+		paged = append(paged, jokes...)
+		after = jokes[len(jokes)-1].ID
+
+		if len(paged) == 5 {
+			require.NoError(t, repo.Delete(ctx, created[0].ID, 0))
+			created = append(created, CreateJoke(t, ctx, repo))
+		}
+	}
+
+	require.Len(t, paged, len(created))
+	for i := range created {
+		RequireJokeEqual(t, created[i], paged[i])
+	}
+
+	_, err := repo.GetAfter(ctx, models.JokeFilter{}, "not-an-id", 5)
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+}
+
+func testGetAfterFilter(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	var gitJokes []models.Jusgo
+	for i := range 6 {
+		joke := NewJoke()
+		if i%2 == 0 {
+			joke.Category = "git"
+			gitJokes = append(gitJokes, joke)
+		}
+		_, err := repo.Create(ctx, joke)
+		require.NoError(t, err)
+	}
+
+	filter := models.JokeFilter{Categories: []string{"git"}}
+	jokes, err := repo.GetAfter(ctx, filter, "", 2)
+	require.NoError(t, err)
+	require.Len(t, jokes, 2)
+	RequireJokeEqual(t, gitJokes[0], jokes[0])
+	RequireJokeEqual(t, gitJokes[1], jokes[1])
+
+	jokes, err = repo.GetAfter(ctx, filter, jokes[1].ID, 2)
+	require.NoError(t, err)
+	require.Len(t, jokes, 1)
+	RequireJokeEqual(t, gitJokes[2], jokes[0])
+}
+
+func testCount(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+
+	count, err := repo.Count(ctx, models.JokeFilter{})
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	jokes := make([]models.Jusgo, 0, 7)
+	for range 7 {
+		jokes = append(jokes, CreateJoke(t, ctx, repo))
+	}
+	require.NoError(t, repo.Delete(ctx, jokes[0].ID, 0))
+
+	count, err = repo.Count(ctx, models.JokeFilter{})
+	require.NoError(t, err)
+	require.EqualValues(t, 6, count)
+}
+
+func testFilter(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	create := func(category string, tags ...string) models.Jusgo {
+		joke := NewJoke()
+		joke.Category, joke.Tags = category, tags
+		_, err := repo.Create(ctx, joke)
+		require.NoError(t, err)
+		return joke
+	}
+
+	gitPun := create("git", "pun", "one-liner")
+	gitBugs := create("git", "bugs")
+	goPun := create("go", "pun")
+	general := create(models.DefaultCategory)
+
+	testData := []struct {
+		Name   string
+		filter models.JokeFilter
+		want   []models.Jusgo
+	}{
+		{Name: "No filter", filter: models.JokeFilter{}, want: []models.Jusgo{gitPun, gitBugs, goPun, general}},
+		{Name: "One category", filter: models.JokeFilter{Categories: []string{"git"}}, want: []models.Jusgo{gitPun, gitBugs}},
+		{Name: "Several categories", filter: models.JokeFilter{Categories: []string{"go", "general"}}, want: []models.Jusgo{goPun, general}},
+		{Name: "Any tag", filter: models.JokeFilter{Tags: []string{"bugs", "one-liner"}}, want: []models.Jusgo{gitPun, gitBugs}},
+		{Name: "All tags", filter: models.JokeFilter{Tags: []string{"pun", "one-liner"}, MatchAllTags: true}, want: []models.Jusgo{gitPun}},
+		{Name: "Category and tag", filter: models.JokeFilter{Categories: []string{"go"}, Tags: []string{"pun"}}, want: []models.Jusgo{goPun}},
+		{Name: "Tag prefix does not match", filter: models.JokeFilter{Tags: []string{"one"}}, want: []models.Jusgo{}},
+		{Name: "Nothing matches", filter: models.JokeFilter{Categories: []string{"python"}}, want: []models.Jusgo{}},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			jokes, err := repo.GetAll(ctx, tc.filter, models.JokeSort{}, 0, 0)
+			require.NoError(t, err)
+			require.Len(t, jokes, len(tc.want))
+			for i := range tc.want {
+				RequireJokeEqual(t, tc.want[i], jokes[i])
+			}
+
+			count, err := repo.Count(ctx, tc.filter)
+			require.NoError(t, err)
+			require.EqualValues(t, len(tc.want), count)
+
+			random, err := repo.GetRandom(ctx, tc.filter, 10, nil)
+			require.NoError(t, err)
+			require.Len(t, random, len(tc.want))
+		})
+	}
+}
+
+func testSearch(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	create := func(text string) models.Jusgo {
+		joke := NewJoke()
+		joke.Joke = text
+		_, err := repo.Create(ctx, joke)
+		require.NoError(t, err)
+		return joke
+	}
+
+	both := create("To understand recursion you must first understand recursion")
+	one := create("Recursion: see recursion")
+	bugs := create("Why do programmers prefer dark mode? Because light attracts bugs")
+	create("A SQL query walks into a bar")
+
+	results, err := repo.Search(ctx, "understand recursion", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	RequireJokeEqual(t, both, results[0].Jusgo) // matches more of the words
+	RequireJokeEqual(t, one, results[1].Jusgo)
+	require.Greater(t, results[0].Score, results[1].Score)
+
+	results, err = repo.Search(ctx, "understand recursion", 1, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	RequireJokeEqual(t, one, results[0].Jusgo)
+
+	results, err = repo.Search(ctx, "bug", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	RequireJokeEqual(t, bugs, results[0].Jusgo)
+
+	results, err = repo.Search(ctx, "recursion -understand", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	RequireJokeEqual(t, one, results[0].Jusgo)
+
+	results, err = repo.Search(ctx, `"walks into"`, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	results, err = repo.Search(ctx, "queries", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	results, err = repo.Search(ctx, "javascript", 0, 10)
+	require.NoError(t, err)
+	require.NotNil(t, results)
+	require.Empty(t, results)
+
+	// LIKE wildcards are matched literally, not as wildcards
+	for _, query := range []string{"%", "_", "%_%"} {
+		results, err = repo.Search(ctx, query, 0, 10)
+		require.NoError(t, err)
+		require.Empty(t, results, query)
+	}
+}
+
+func testSearchPages(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	for i := range 24 {
+		joke := NewJoke()
+		joke.Joke = fmt.Sprintf("Bug number %d was a feature all along", i)
+		_, err := repo.Create(ctx, joke)
+		require.NoError(t, err)
+	}
+	// stored last and longer, it still comes first for having both words
+	best := NewJoke()
+	best.Joke = "Every bug report turns out to be a feature request once you read it twice"
+	_, err := repo.Create(ctx, best)
+	require.NoError(t, err)
+
+	results, err := repo.Search(ctx, "feature request", 0, 5)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	RequireJokeEqual(t, best, results[0].Jusgo)
+
+	seen := make(map[string]bool)
+	for skip := int64(0); ; skip += 5 {
+		results, err := repo.Search(ctx, "feature request", skip, 5)
+		require.NoError(t, err)
+		if len(results) == 0 {
+			break
+		}
+		for i, result := range results {
+			require.False(t, seen[result.ID], "joke %s on two pages", result.ID)
+			seen[result.ID] = true
+			if i > 0 {
+				require.GreaterOrEqual(t, results[i-1].Score, result.Score)
+			}
+		}
+	}
+	require.Len(t, seen, 25)
+}
+
+func testGetRandom(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	created := make(map[string]bool)
+	for range 10 {
+		created[CreateJoke(t, ctx, repo).ID] = true
+	}
+
+	testData := []struct {
+		Name  string
+		count int64
+		want  int
+	}{
+		{Name: "Fetch one joke", count: 1, want: 1},
+		{Name: "Fetch several jokes", count: 4, want: 4},
+		{Name: "Fetch more jokes than stored", count: 25, want: 10},
+		{Name: "Fetch zero jokes", count: 0, want: 0},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			jokes, err := repo.GetRandom(ctx, models.JokeFilter{}, tc.count, nil)
+			require.NoError(t, err)
+			require.NotNil(t, jokes)
+			require.Len(t, jokes, tc.want)
+
+			seen := make(map[string]bool)
+			for _, joke := range jokes {
+				require.True(t, created[joke.ID])
+				require.False(t, seen[joke.ID], "duplicate joke %s", joke.ID)
+				seen[joke.ID] = true
+			}
+		})
+	}
+}
+
+func testGetRandomExclude(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	var exclude []string
+	for range 5 {
+		exclude = append(exclude, CreateJoke(t, ctx, repo).ID)
+	}
+	kept := CreateJoke(t, ctx, repo)
+
+	jokes, err := repo.GetRandom(ctx, models.JokeFilter{}, 10, exclude)
+	require.NoError(t, err)
+	require.Len(t, jokes, 1)
+	RequireJokeEqual(t, kept, jokes[0])
+
+	_, err = repo.GetRandom(ctx, models.JokeFilter{}, 1, []string{"not-an-id"})
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+}
+
+func testGetRandomEmpty(t *testing.T, repo repository.RepositoryProvider) {
+	jokes, err := repo.GetRandom(context.Background(), models.JokeFilter{}, 3, nil)
+	require.NoError(t, err)
+	require.NotNil(t, jokes)
+	require.Empty(t, jokes)
+}
+
+func testCanceledContext(t *testing.T, repo repository.RepositoryProvider) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.Create(ctx, NewJoke())
+	require.Error(t, err)
+
+	_, err = repo.Get(ctx, repository.NewID())
+	require.Error(t, err)
+
+	_, err = repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{}, 0, 10)
+	require.Error(t, err)
+}
+
+func testSubmissions(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	data := newSubmission(models.SubmissionPending)
+
+	submission, err := repo.CreateSubmission(ctx, data)
+	require.NoError(t, err)
+	require.Equal(t, data, submission)
+
+	_, err = repo.CreateSubmission(ctx, data)
+	require.ErrorIs(t, err, repository.ErrDuplicateKey)
+
+	// submissions are not jokes
+	_, err = repo.Get(ctx, data.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	count, err := repo.Count(ctx, models.JokeFilter{})
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	data.Status = models.SubmissionRejected
+	data.Reason = "heard it before"
+	data.JokeID = repository.NewID()
+	_, err = repo.UpdateSubmission(ctx, data)
+	require.NoError(t, err)
+
+	submission, err = repo.GetSubmission(ctx, data.ID)
+	require.NoError(t, err)
+	require.Equal(t, data.ID, submission.ID)
+	require.Equal(t, data.Type, submission.Type)
+	require.Equal(t, data.Joke, submission.Joke)
+	require.Equal(t, data.Category, submission.Category)
+	require.Equal(t, data.Tags, submission.Tags)
+	require.Equal(t, data.Status, submission.Status)
+	require.Equal(t, data.Reason, submission.Reason)
+	require.Equal(t, data.JokeID, submission.JokeID)
+	require.Equal(t, data.CreatedAt.UnixMilli(), submission.CreatedAt.UnixMilli())
+	require.Equal(t, data.UpdatedAt.UnixMilli(), submission.UpdatedAt.UnixMilli())
+
+	// a submission without a type or category gets the defaults, like a joke
+	bare := models.Submission{ID: repository.NewID(), Joke: "No type", Status: models.SubmissionPending}
+	_, err = repo.CreateSubmission(ctx, bare)
+	require.NoError(t, err)
+	submission, err = repo.GetSubmission(ctx, bare.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.TypeSingle, submission.Type)
+	require.Equal(t, models.DefaultCategory, submission.Category)
+
+	// only a pending submission is updated, data was rejected above
+	data.Status = models.SubmissionApproved
+	_, err = repo.UpdateSubmission(ctx, data)
+	require.ErrorIs(t, err, repository.ErrNotPending)
+	submission, err = repo.GetSubmission(ctx, data.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.SubmissionRejected, submission.Status)
+
+	missing := newSubmission(models.SubmissionPending)
+	_, err = repo.UpdateSubmission(ctx, missing)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	_, err = repo.GetSubmission(ctx, repository.NewID())
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetSubmission(ctx, "not-an-id")
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+}
+
+func testSubmissionsByStatus(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	statuses := []string{models.SubmissionPending, models.SubmissionApproved, models.SubmissionPending, models.SubmissionRejected, models.SubmissionPending}
+	ids := make([]string, len(statuses))
+	for i, status := range statuses {
+		submission, err := repo.CreateSubmission(ctx, newSubmission(status))
+		require.NoError(t, err)
+		ids[i] = submission.ID
+	}
+
+	submissions, err := repo.GetSubmissions(ctx, models.SubmissionPending, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, submissions, 2)
+	require.Equal(t, ids[0], submissions[0].ID) // oldest first
+	require.Equal(t, ids[2], submissions[1].ID)
+
+	submissions, err = repo.GetSubmissions(ctx, models.SubmissionPending, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, submissions, 1)
+	require.Equal(t, ids[4], submissions[0].ID)
+
+	submissions, err = repo.GetSubmissions(ctx, "", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, submissions, len(statuses))
+
+	testData := []struct {
+		status string
+		count  int64
+	}{
+		{status: "", count: 5},
+		{status: models.SubmissionPending, count: 3},
+		{status: models.SubmissionApproved, count: 1},
+		{status: models.SubmissionRejected, count: 1},
+	}
+	for _, tc := range testData {
+		count, err := repo.CountSubmissions(ctx, tc.status)
+		require.NoError(t, err)
+		require.Equal(t, tc.count, count, tc.status)
+	}
+
+	submissions, err = repo.GetSubmissions(ctx, "unknown", 0, 10)
+	require.NoError(t, err)
+	require.NotNil(t, submissions)
+	require.Empty(t, submissions)
+}
+
+func testVotes(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	joke := CreateJoke(t, ctx, repo)
+
+	window := time.Now().Truncate(models.VoteWindow)
+	vote := models.Vote{JokeID: joke.ID, Client: "client", Up: true, Window: window, CreatedAt: time.Now()}
+	require.NoError(t, repo.AddVote(ctx, vote))
+
+	vote.Up = false
+	require.ErrorIs(t, repo.AddVote(ctx, vote), repository.ErrDuplicateKey)
+	vote.Window = window.Add(models.VoteWindow)
+	require.NoError(t, repo.AddVote(ctx, vote))
+	vote.Client = "another client"
+	require.NoError(t, repo.AddVote(ctx, vote))
+
+	_, err := repo.IncrementVotes(ctx, joke.ID, 1, 0)
+	require.NoError(t, err)
+	_, err = repo.IncrementVotes(ctx, joke.ID, 1, 0)
+	require.NoError(t, err)
+	voted, err := repo.IncrementVotes(ctx, joke.ID, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), voted.Upvotes)
+	require.Equal(t, int64(1), voted.Downvotes)
+	require.Equal(t, rating.Wilson(2, 1), voted.Rating)
+	RequireJokeEqual(t, voted, mustGet(t, ctx, repo, joke.ID))
+
+	// updating a joke keeps its votes
+	joke.Joke = "Updated joke"
+	_, err = repo.Update(ctx, joke)
+	require.NoError(t, err)
+	updated := mustGet(t, ctx, repo, joke.ID)
+	require.Equal(t, joke.Joke, updated.Joke)
+	require.Equal(t, voted.Upvotes, updated.Upvotes)
+	require.Equal(t, voted.Rating, updated.Rating)
+
+	_, err = repo.IncrementVotes(ctx, repository.NewID(), 1, 0)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.IncrementVotes(ctx, "not-an-id", 1, 0)
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+}
+
+func testVoteTotals(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	now := time.Now()
+	first, second := repository.NewID(), repository.NewID()
+
+	votes := []models.Vote{
+		{JokeID: first, Client: "a", Up: true, CreatedAt: now.Add(-time.Hour)},
+		{JokeID: first, Client: "b", Up: true, CreatedAt: now.Add(-2 * time.Hour)},
+		{JokeID: first, Client: "c", Up: false, CreatedAt: now.Add(-3 * time.Hour)},
+		{JokeID: second, Client: "a", Up: false, CreatedAt: now.Add(-time.Hour)},
+		{JokeID: second, Client: "b", Up: true, CreatedAt: now.Add(-48 * time.Hour)},
+	}
+	for _, vote := range votes {
+		vote.Window = vote.CreatedAt.Truncate(models.VoteWindow)
+		require.NoError(t, repo.AddVote(ctx, vote))
+	}
+
+	totals, err := repo.GetVoteTotals(ctx, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []models.VoteTotal{
+		{JokeID: first, Upvotes: 2, Downvotes: 1},
+		{JokeID: second, Upvotes: 0, Downvotes: 1},
+	}, totals)
+
+	totals, err = repo.GetVoteTotals(ctx, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, totals, 2)
+
+	totals, err = repo.GetVoteTotals(ctx, now)
+	require.NoError(t, err)
+	require.NotNil(t, totals)
+	require.Empty(t, totals)
+}
+
+func testSortRating(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	unrated := CreateJoke(t, ctx, repo)
+	liked := CreateJoke(t, ctx, repo)
+	loved := CreateJoke(t, ctx, repo)
+
+	_, err := repo.IncrementVotes(ctx, liked.ID, 3, 2)
+	require.NoError(t, err)
+	_, err = repo.IncrementVotes(ctx, loved.ID, 10, 0)
+	require.NoError(t, err)
+
+	jokes, err := repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{Field: models.SortRating, Descending: true}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, jokes, 3)
+	require.Equal(t, loved.ID, jokes[0].ID)
+	require.Equal(t, liked.ID, jokes[1].ID)
+	require.Equal(t, unrated.ID, jokes[2].ID)
+}
+
+func testTransactionCommit(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	joke := NewJoke()
+
+	err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		if _, err := repo.Create(ctx, joke); err != nil {
+			return err
+		}
+		// nested calls join the outer transaction and see its writes
+		return repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			_, err := repo.AddRevision(ctx, NewRevision(joke.ID))
+			if err != nil {
+				return err
+			}
+			_, err = repo.Get(ctx, joke.ID)
+			return err
+		})
+	})
+	require.NoError(t, err)
+
+	RequireJokeEqual(t, joke, mustGet(t, ctx, repo, joke.ID))
+	count, err := repo.CountRevisions(ctx, joke.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+}
+
+func testTransactionRollback(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	stored := CreateJoke(t, ctx, repo)
+	joke := NewJoke()
+	errFailed := errors.New("failed")
+
+	err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		if _, err := repo.Create(ctx, joke); err != nil {
+			return err
+		}
+		if _, err := repo.AddRevision(ctx, NewRevision(joke.ID)); err != nil {
+			return err
+		}
+		if err := repo.Delete(ctx, stored.ID, 0); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	require.ErrorIs(t, err, errFailed)
+
+	_, err = repo.Get(ctx, joke.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	count, err := repo.CountRevisions(ctx, joke.ID)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	RequireJokeEqual(t, stored, mustGet(t, ctx, repo, stored.ID))
+}
+
+func testRevisions(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	jokeID := repository.NewID()
+
+	created := NewRevision(jokeID)
+	created.Action = models.RevisionCreate
+	created.New = NewJoke().Content()
+	created, err := repo.AddRevision(ctx, created)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), created.Number)
+
+	updated := NewRevision(jokeID)
+	updated.Old = created.New
+	updated.New = &models.JokeContent{Type: models.TypeTwoPart, Setup: "setup", Delivery: "delivery", Category: "go"}
+	updated.Reverted = 1
+	updated, err = repo.AddRevision(ctx, updated)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), updated.Number)
+
+	deleted := NewRevision(jokeID)
+	deleted.Action = models.RevisionDelete
+	deleted.Old = updated.New
+	deleted, err = repo.AddRevision(ctx, deleted)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), deleted.Number)
+
+	got, err := repo.GetRevision(ctx, jokeID, 2)
+	require.NoError(t, err)
+	requireRevisionEqual(t, updated, got)
+
+	revisions, err := repo.GetRevisions(ctx, jokeID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	requireRevisionEqual(t, created, revisions[0])
+	requireRevisionEqual(t, updated, revisions[1])
+	requireRevisionEqual(t, deleted, revisions[2])
+
+	revisions, err = repo.GetRevisions(ctx, jokeID, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	require.Equal(t, int64(2), revisions[0].Number)
+
+	count, err := repo.CountRevisions(ctx, jokeID)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), count)
+
+	_, err = repo.GetRevision(ctx, jokeID, 4)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetRevision(ctx, "not-an-id", 1)
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+	_, err = repo.GetRevisions(ctx, "not-an-id", 0, 10)
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+}
+
+func testRevisionNumbers(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	first, second := repository.NewID(), repository.NewID()
+
+	for _, jokeID := range []string{first, second, first} {
+		_, err := repo.AddRevision(ctx, NewRevision(jokeID))
+		require.NoError(t, err)
+	}
+
+	rev, err := repo.GetRevision(ctx, second, 1)
+	require.NoError(t, err)
+	require.Equal(t, second, rev.JokeID)
+	_, err = repo.GetRevision(ctx, second, 2)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetRevision(ctx, first, 2)
+	require.NoError(t, err)
+
+	revisions, err := repo.GetRevisions(ctx, repository.NewID(), 0, 10)
+	require.NoError(t, err)
+	require.Empty(t, revisions)
+}
+
+// NewRevision returns an update revision of the joke with jokeID that is ready to be stored.
+func NewRevision(jokeID string) models.Revision {
+	joke := NewJoke()
+	return models.Revision{
+		ID:        repository.NewID(),
+		JokeID:    jokeID,
+		Action:    models.RevisionUpdate,
+		Old:       joke.Content(),
+		New:       joke.Content(),
+		Actor:     "admin",
+		RequestID: "test",
+		CreatedAt: time.Now(),
+	}
+}
+
+// requireRevisionEqual compares two revisions like RequireJokeEqual compares jokes.
+func requireRevisionEqual(t *testing.T, want, got models.Revision) {
+	t.Helper()
+	require.Equal(t, want.CreatedAt.UnixMilli(), got.CreatedAt.UnixMilli())
+	want.CreatedAt, got.CreatedAt = time.Time{}, time.Time{}
+	require.Equal(t, want, got)
+}
+
+func testAPIKeys(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+
+	keys, err := repo.GetAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	expires := time.Now().Add(time.Hour)
+	first := newAPIKey("first")
+	first.ExpiresAt = &expires
+	_, err = repo.CreateAPIKey(ctx, first)
+	require.NoError(t, err)
+	second := newAPIKey("second")
+	_, err = repo.CreateAPIKey(ctx, second)
+	require.NoError(t, err)
+
+	// hashes are unique
+	again := newAPIKey("again")
+	again.Hash = first.Hash
+	_, err = repo.CreateAPIKey(ctx, again)
+	require.ErrorIs(t, err, repository.ErrDuplicateKey)
+
+	key, err := repo.GetAPIKeyByHash(ctx, first.Hash)
+	require.NoError(t, err)
+	requireAPIKeyEqual(t, first, key)
+	_, err = repo.GetAPIKeyByHash(ctx, "unknown")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	keys, err = repo.GetAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	requireAPIKeyEqual(t, first, keys[0])
+	requireAPIKeyEqual(t, second, keys[1])
+}
+
+func testRevokeAPIKey(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	key := newAPIKey("revoked")
+	_, err := repo.CreateAPIKey(ctx, key)
+	require.NoError(t, err)
+
+	revokedAt := time.Now()
+	revoked, err := repo.RevokeAPIKey(ctx, key.ID, revokedAt)
+	require.NoError(t, err)
+	key.RevokedAt = &revokedAt
+	requireAPIKeyEqual(t, key, revoked)
+
+	// revoking it again doesn't move the time
+	revoked, err = repo.RevokeAPIKey(ctx, key.ID, revokedAt.Add(time.Hour))
+	require.NoError(t, err)
+	requireAPIKeyEqual(t, key, revoked)
+
+	stored, err := repo.GetAPIKeyByHash(ctx, key.Hash)
+	require.NoError(t, err)
+	requireAPIKeyEqual(t, key, stored)
+
+	_, err = repo.RevokeAPIKey(ctx, repository.NewID(), revokedAt)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.RevokeAPIKey(ctx, "not-an-id", revokedAt)
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+}
+
+func newAPIKey(name string) models.APIKey {
+	id := repository.NewID()
+	return models.APIKey{
+		ID:        id,
+		Name:      name,
+		Prefix:    "jusgo_" + id[18:],
+		Hash:      "hash-" + id,
+		Scopes:    []string{models.ScopeJokesWrite, models.ScopeJokesDelete},
+		CreatedAt: time.Now(),
+	}
+}
+
+// requireAPIKeyEqual compares keys down to the millisecond, the precision mongo stores times with.
+func requireAPIKeyEqual(t *testing.T, want, got models.APIKey) {
+	t.Helper()
+	millis := func(at *time.Time) *int64 {
+		if at == nil {
+			return nil
+		}
+		ms := at.UnixMilli()
+		return &ms
+	}
+	require.Equal(t, want.CreatedAt.UnixMilli(), got.CreatedAt.UnixMilli())
+	require.Equal(t, millis(want.ExpiresAt), millis(got.ExpiresAt))
+	require.Equal(t, millis(want.RevokedAt), millis(got.RevokedAt))
+	want.CreatedAt, want.ExpiresAt, want.RevokedAt = time.Time{}, nil, nil
+	got.CreatedAt, got.ExpiresAt, got.RevokedAt = time.Time{}, nil, nil
+	require.Equal(t, want, got)
+}
+
+// createTrashed stores a joke with the given normalized text and moves it to the trash.
+func createTrashed(t *testing.T, ctx context.Context, repo repository.RepositoryProvider, normalized string) models.Jusgo {
+	t.Helper()
+	joke := NewJoke()
+	joke.Normalized = normalized
+	_, err := repo.Create(ctx, joke)
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(ctx, joke.ID, 0))
+	return joke
+}
+
+func testTrashHidden(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	live := CreateJoke(t, ctx, repo)
+	trashed := createTrashed(t, ctx, repo, "im declaring a war var war")
+
+	_, err := repo.Get(ctx, trashed.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	jokes, err := repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, jokes, 1)
+	require.Equal(t, live.ID, jokes[0].ID)
+
+	jokes, err = repo.GetAfter(ctx, models.JokeFilter{}, "", 10)
+	require.NoError(t, err)
+	require.Len(t, jokes, 1)
+
+	count, err := repo.Count(ctx, models.JokeFilter{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	results, err := repo.Search(ctx, "war", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, live.ID, results[0].ID)
+
+	jokes, err = repo.GetRandom(ctx, models.JokeFilter{}, 10, nil)
+	require.NoError(t, err)
+	require.Len(t, jokes, 1)
+
+	_, err = repo.IncrementVotes(ctx, trashed.ID, 1, 0)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// updates don't bring it back, deleting it again doesn't move its deletion time
+	deleted, err := repo.GetDeleted(ctx, trashed.ID)
+	require.NoError(t, err)
+	_, err = repo.Update(ctx, trashed)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	require.NoError(t, repo.Delete(ctx, trashed.ID, 0))
+	again, err := repo.GetDeleted(ctx, trashed.ID)
+	require.NoError(t, err)
+	require.Equal(t, deleted.DeletedAt.UnixMilli(), again.DeletedAt.UnixMilli())
+	_, err = repo.Get(ctx, trashed.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+
+	// the trash doesn't hold on to normalized texts
+	require.Empty(t, deleted.Normalized)
+	joke := NewJoke()
+	joke.Normalized = trashed.Normalized
+	_, err = repo.Create(ctx, joke)
+	require.NoError(t, err)
+}
+
+func testTrashListing(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	CreateJoke(t, ctx, repo)
+	first := createTrashed(t, ctx, repo, "")
+	time.Sleep(5 * time.Millisecond) // deletion times are only stored to the millisecond
+	second := createTrashed(t, ctx, repo, "")
+
+	trash := models.JokeFilter{Deleted: true}
+	jokes, err := repo.GetAll(ctx, trash, models.JokeSort{Field: models.SortDeletedAt, Descending: true}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, jokes, 2)
+	require.Equal(t, second.ID, jokes[0].ID)
+	require.Equal(t, first.ID, jokes[1].ID)
+	require.NotNil(t, jokes[0].DeletedAt)
+	require.WithinDuration(t, time.Now(), *jokes[0].DeletedAt, time.Minute)
+
+	count, err := repo.Count(ctx, trash)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), count)
+
+	_, err = repo.GetDeleted(ctx, repository.NewID())
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testRestore(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	trashed := createTrashed(t, ctx, repo, "")
+
+	restored, err := repo.Restore(ctx, trashed.ID, "im declaring a war var war")
+	require.NoError(t, err)
+	require.Nil(t, restored.DeletedAt)
+	trashed.Normalized = "im declaring a war var war"
+	trashed.Version = 2 // deleted and restored
+	RequireJokeEqual(t, trashed, restored)
+	RequireJokeEqual(t, trashed, mustGet(t, ctx, repo, trashed.ID))
+
+	// only jokes in the trash can be restored
+	_, err = repo.Restore(ctx, trashed.ID, "")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.Restore(ctx, repository.NewID(), "")
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.Restore(ctx, "not-an-id", "")
+	require.ErrorIs(t, err, repository.ErrInvalidID)
+
+	// a joke with the same text was stored while it was in the trash
+	again := createTrashed(t, ctx, repo, "")
+	_, err = repo.Restore(ctx, again.ID, trashed.Normalized)
+	require.ErrorIs(t, err, repository.ErrDuplicateKey)
+	_, err = repo.GetDeleted(ctx, again.ID)
+	require.NoError(t, err)
+}
+
+func testPurge(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	live := CreateJoke(t, ctx, repo)
+	trashed := createTrashed(t, ctx, repo, "")
+
+	require.ErrorIs(t, repo.Purge(ctx, live.ID), repository.ErrNotFound)
+	require.NoError(t, repo.Purge(ctx, trashed.ID))
+	require.ErrorIs(t, repo.Purge(ctx, trashed.ID), repository.ErrNotFound)
+	require.ErrorIs(t, repo.Purge(ctx, "not-an-id"), repository.ErrInvalidID)
+
+	_, err := repo.GetDeleted(ctx, trashed.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.Get(ctx, live.ID)
+	require.NoError(t, err)
+
+	// the ID is free again
+	_, err = repo.Create(ctx, trashed)
+	require.NoError(t, err)
+}
+
+func testPurgeDeletedBefore(t *testing.T, repo repository.RepositoryProvider) {
+	ctx := context.Background()
+	live := CreateJoke(t, ctx, repo)
+	older := createTrashed(t, ctx, repo, "")
+	old := createTrashed(t, ctx, repo, "")
+	time.Sleep(5 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	recent := createTrashed(t, ctx, repo, "")
+
+	purged, err := repo.PurgeDeletedBefore(ctx, cutoff, 1)
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	require.Equal(t, older.ID, purged[0].ID) // lowest ID first
+	require.Equal(t, older.Joke, purged[0].Joke)
+	require.NotNil(t, purged[0].DeletedAt)
+
+	purged, err = repo.PurgeDeletedBefore(ctx, cutoff, 10)
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	require.Equal(t, old.ID, purged[0].ID)
+
+	purged, err = repo.PurgeDeletedBefore(ctx, cutoff, 10)
+	require.NoError(t, err)
+	require.Empty(t, purged)
+
+	_, err = repo.GetDeleted(ctx, older.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetDeleted(ctx, old.ID)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = repo.GetDeleted(ctx, recent.ID)
+	require.NoError(t, err)
+	_, err = repo.Get(ctx, live.ID)
+	require.NoError(t, err)
+}
+
+func mustGet(t *testing.T, ctx context.Context, repo repository.RepositoryProvider, id string) models.Jusgo {
+	t.Helper()
+	joke, err := repo.Get(ctx, id)
+	require.NoError(t, err)
+	return joke
+}
+
+// NewJoke returns a joke with a fresh ID that has not been stored.
+func NewJoke() models.Jusgo {
+	return models.Jusgo{
+		ID:        repository.NewID(),
+		Type:      models.TypeSingle,
+		Joke:      "I'm declaring a war. var war",
+		Category:  "go",
+		Tags:      []string{"pun", "one-liner"},
+		UpdatedAt: time.Now(),
+		CreatedAt: time.Now(),
+	}
+}
+
+// newSubmission returns a submission with status and the text of NewJoke.
+func newSubmission(status string) models.Submission {
+	joke := NewJoke()
+	return models.Submission{
+		ID:        joke.ID,
+		Type:      joke.Type,
+		Joke:      joke.Joke,
+		Category:  joke.Category,
+		Tags:      joke.Tags,
+		Status:    status,
+		CreatedAt: joke.CreatedAt,
+		UpdatedAt: joke.UpdatedAt,
+	}
+}
+
+// CreateJoke stores a new joke in repo and checks it was returned unchanged.
+func CreateJoke(t *testing.T, ctx context.Context, repo repository.RepositoryProvider) models.Jusgo {
+	t.Helper()
+	data := NewJoke()
+
+	joke, err := repo.Create(ctx, data)
+	require.NoError(t, err)
+	require.NotEmpty(t, joke)
+	require.Equal(t, data, joke)
+
+	return joke
+}
+
+// RequireJokeEqual compares two jokes, timestamps are compared to the
+// millisecond since that is all mongo stores.
+func RequireJokeEqual(t *testing.T, want, got models.Jusgo) {
+	t.Helper()
+	require.Equal(t, want.ID, got.ID)
+	require.Equal(t, want.Type, got.Type)
+	require.Equal(t, want.Joke, got.Joke)
+	require.Equal(t, want.Setup, got.Setup)
+	require.Equal(t, want.Delivery, got.Delivery)
+	require.Equal(t, want.Category, got.Category)
+	if len(want.Tags) > 0 || len(got.Tags) > 0 { // nil and empty tags are the same
+		require.Equal(t, want.Tags, got.Tags)
+	}
+	require.Equal(t, want.CreatedAt.UnixMilli(), got.CreatedAt.UnixMilli())
+	require.Equal(t, want.UpdatedAt.UnixMilli(), got.UpdatedAt.UnixMilli())
+	require.Equal(t, want.Normalized, got.Normalized)
+	require.Equal(t, want.Upvotes, got.Upvotes)
+	require.Equal(t, want.Downvotes, got.Downvotes)
+	require.InDelta(t, want.Rating, got.Rating, 1e-9)
+	require.Equal(t, want.Version, got.Version)
+	require.Equal(t, want.DeletedAt == nil, got.DeletedAt == nil)
+	if want.DeletedAt != nil && got.DeletedAt != nil {
+		require.Equal(t, want.DeletedAt.UnixMilli(), got.DeletedAt.UnixMilli())
+	}
+}
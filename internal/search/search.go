@@ -0,0 +1,192 @@
+// Package search parses full text search queries and scores and highlights jokes against them.
+// It follows the syntax of mongo's $text operator so every repository understands the same queries:
+// words match if any of them appear, "quoted phrases" must all appear and -words must not appear.
+package search
+
+import (
+	"html"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed search query.
+type Query struct {
+	Terms    []string // stemmed words, a joke matches if it has any of them
+	Phrases  []string // lower cased phrases, a joke must have all of them
+	Excluded []string // stemmed words a joke must not have
+}
+
+// Parse splits q into terms, phrases and excluded terms.
+func Parse(q string) Query {
+	var query Query
+	for {
+		start := strings.IndexByte(q, '"')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(q[start+1:], '"')
+		if end < 0 {
+			break
+		}
+
+		phrase := q[start+1 : start+1+end]
+		if normalized := strings.Join(strings.FieldsFunc(strings.ToLower(phrase), isSeparator), " "); normalized != "" {
+			query.Phrases = append(query.Phrases, normalized)
+		}
+		// words of a phrase also count as terms, like they do for mongo
+		q = q[:start] + " " + phrase + " " + q[start+2+end:]
+	}
+
+	for _, field := range strings.Fields(q) {
+		excluded := strings.HasPrefix(field, "-")
+		for _, word := range strings.FieldsFunc(strings.ToLower(field), isSeparator) {
+			if excluded {
+				if !slices.Contains(query.Excluded, stem(word)) {
+					query.Excluded = append(query.Excluded, stem(word))
+				}
+			} else if !slices.Contains(query.Terms, stem(word)) {
+				query.Terms = append(query.Terms, stem(word))
+			}
+		}
+	}
+	return query
+}
+
+// Empty reports whether the query has nothing that can match a joke.
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0
+}
+
+// Score rates how well text matches the query, 0 means it doesn't match.
+// Every distinct term found counts 1, plus how much of the text the matches make up.
+func (q Query) Score(text string) float64 {
+	if q.Empty() {
+		return 0
+	}
+
+	lower := strings.Join(strings.FieldsFunc(strings.ToLower(text), isSeparator), " ")
+	for _, phrase := range q.Phrases {
+		if !strings.Contains(" "+lower+" ", " "+phrase+" ") {
+			return 0
+		}
+	}
+
+	words := strings.Fields(lower)
+	found := make(map[string]bool)
+	hits := 0
+	for _, word := range words {
+		word = stem(word)
+		if slices.Contains(q.Excluded, word) {
+			return 0
+		}
+		for _, term := range q.Terms {
+			if word == term {
+				found[term] = true
+				hits++
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return 0
+	}
+	return float64(len(found)) + float64(hits)/float64(len(words))
+}
+
+// Highlight returns text, HTML escaped, with the words and phrases of the query wrapped in <mark> tags.
+func (q Query) Highlight(text string) string {
+	type span struct{ start, end int }
+	var spans []span
+
+	// words of text, as byte offsets
+	var words []span
+	start := -1
+	for i, r := range text {
+		if isSeparator(r) {
+			if start >= 0 {
+				words = append(words, span{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, span{start, len(text)})
+	}
+
+	for i, word := range words {
+		if slices.Contains(q.Terms, stem(strings.ToLower(text[word.start:word.end]))) {
+			spans = append(spans, word)
+		}
+
+		for _, phrase := range q.Phrases {
+			n := len(strings.Fields(phrase))
+			if i+n > len(words) {
+				continue
+			}
+			candidate := make([]string, n)
+			for j := range n {
+				w := words[i+j]
+				candidate[j] = strings.ToLower(text[w.start:w.end])
+			}
+			if strings.Join(candidate, " ") == phrase {
+				spans = append(spans, span{word.start, words[i+n-1].end})
+			}
+		}
+	}
+
+	// longest span first when several start at the same word, so phrases win over their words
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start == spans[j].start {
+			return spans[i].end > spans[j].end
+		}
+		return spans[i].start < spans[j].start
+	})
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.end <= last { // inside a span that was already marked
+			continue
+		}
+		if s.start < last {
+			s.start = last
+		}
+		b.WriteString(html.EscapeString(text[last:s.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[s.start:s.end]))
+		b.WriteString("</mark>")
+		last = s.end
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Forms returns what a text must contain to have a word that stems to term, which comes from a parsed
+// Query: one of the forms, anywhere in a word. Backends that narrow jokes down by substring before scoring
+// them use it, so they don't drop jokes Score would match.
+func Forms(term string) []string {
+	if strings.HasSuffix(term, "y") && len(term) > 1 {
+		return []string{term, term[:len(term)-1] + "ies"} // "query" comes from "queries" too
+	}
+	return []string{term} // a plural has its singular in it
+}
+
+// stem reduces plurals to their singular so "bugs" finds "bug" and the other way around.
+func stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return word[:len(word)-1]
+	}
+	return word
+}
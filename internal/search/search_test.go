@@ -0,0 +1,72 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	query := Parse(`Recursion "stack overflow" -Java bugs`)
+	require.Equal(t, []string{"recursion", "stack", "overflow", "bug"}, query.Terms)
+	require.Equal(t, []string{"stack overflow"}, query.Phrases)
+	require.Equal(t, []string{"java"}, query.Excluded)
+
+	require.True(t, Parse(`  -java "" `).Empty())
+}
+
+func TestScore(t *testing.T) {
+	testData := []struct {
+		Name  string
+		query string
+		text  string
+		match bool
+	}{
+		{Name: "Any term matches", query: "recursion java", text: "To understand recursion you must first understand recursion", match: true},
+		{Name: "No term matches", query: "java", text: "Why do programmers prefer dark mode?", match: false},
+		{Name: "Plurals match", query: "bug", text: "Because light attracts bugs.", match: true},
+		{Name: "Case is ignored", query: "SQL", text: "A sql query walks into a bar", match: true},
+		{Name: "Phrase matches", query: `"walks into"`, text: "A SQL query walks into a bar", match: true},
+		{Name: "Phrase needs the words in order", query: `"into walks"`, text: "A SQL query walks into a bar", match: false},
+		{Name: "Excluded term", query: "query -bar", text: "A SQL query walks into a bar", match: false},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			score := Parse(tc.query).Score(tc.text)
+			if tc.match {
+				require.Positive(t, score)
+			} else {
+				require.Zero(t, score)
+			}
+		})
+	}
+
+	// more distinct terms rank higher, then more of the text matching
+	query := Parse("recursion understand")
+	require.Greater(t, query.Score("understand recursion"), query.Score("recursion recursion recursion"))
+	require.Greater(t, query.Score("recursion"), query.Score("a joke about recursion"))
+}
+
+func TestHighlight(t *testing.T) {
+	require.Equal(t,
+		"To understand <mark>recursion</mark> you must first understand <mark>Recursion</mark>",
+		Parse("recursion").Highlight("To understand recursion you must first understand Recursion"),
+	)
+	require.Equal(t,
+		"A SQL query <mark>walks into</mark> a <mark>bar</mark> &amp; asks",
+		Parse(`"walks into" bars`).Highlight("A SQL query walks into a bar & asks"),
+	)
+}
+
+func TestForms(t *testing.T) {
+	// every word that stems to a term contains one of its forms
+	for _, word := range []string{"query", "queries", "bug", "bugs", "class", "y", "flies"} {
+		term := stem(word)
+		require.True(t, slices.ContainsFunc(Forms(term), func(form string) bool {
+			return strings.Contains(word, form)
+		}), "%s stems to %s", word, term)
+	}
+}
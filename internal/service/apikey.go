@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+// apiKeyPrefix starts every secret, so a key that leaked into a log or a repository is easy to spot.
+const apiKeyPrefix = "jusgo_"
+
+func (s *serviceImpl) CreateAPIKey(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (models.APIKey, string, error) {
+	b := make([]byte, 32)
+	rand.Read(b) // never fails
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	key, err := s.storeAPIKey(ctx, name, secret[:len(apiKeyPrefix)+6], secret, scopes, expiresAt)
+	if err != nil {
+		return key, "", err
+	}
+	return key, secret, nil
+}
+
+// bootstrapKeyName and bootstrapKeyPrefix tell the key BootstrapAPIKey stores apart from the others.
+// Its secret was chosen by hand, showing a part of it would give away more than for a random one.
+const (
+	bootstrapKeyName   = "bootstrap"
+	bootstrapKeyPrefix = "TOKEN"
+)
+
+// minBootstrapKeyLength is the shortest secret BootstrapAPIKey takes. Its hash is as fast to check as that of
+// any key, so it has to be about as hard to guess as the 32 random bytes of the keys CreateAPIKey makes.
+const minBootstrapKeyLength = 32
+
+func (s *serviceImpl) BootstrapAPIKey(ctx context.Context, secret string) (models.APIKey, bool, error) {
+	keys, err := s.repo.GetAPIKeys(ctx)
+	if err != nil || len(keys) > 0 {
+		return models.APIKey{}, false, err
+	}
+	if len(secret) < minBootstrapKeyLength {
+		return models.APIKey{}, false, ErrWeakBootstrapKey
+	}
+
+	key, err := s.storeAPIKey(ctx, bootstrapKeyName, bootstrapKeyPrefix, secret, models.Scopes, nil)
+	if errors.Is(err, repository.ErrDuplicateKey) { // another instance stored it first
+		return models.APIKey{}, false, nil
+	}
+	if err != nil {
+		return models.APIKey{}, false, err
+	}
+	return key, true, nil
+}
+
+// storeAPIKey stores a key with secret, of which only the hash is kept.
+func (s *serviceImpl) storeAPIKey(ctx context.Context, name, prefix, secret string, scopes []string, expiresAt *time.Time) (models.APIKey, error) {
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	return s.repo.CreateAPIKey(ctx, models.APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hashAPIKey(secret),
+		Scopes:    slices.Compact(scopes),
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (s *serviceImpl) AuthenticateAPIKey(ctx context.Context, secret string) (models.APIKey, error) {
+	key, err := s.repo.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if !key.Active(time.Now()) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+func (s *serviceImpl) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.GetAPIKeys(ctx)
+}
+
+func (s *serviceImpl) RevokeAPIKey(ctx context.Context, id string) (models.APIKey, error) {
+	return s.repo.RevokeAPIKey(ctx, id, time.Now())
+}
+
+// hashAPIKey returns the hash a key is stored under. A fast hash is enough where passwords would need a slow
+// one because secrets are too long to guess: CreateAPIKey makes them from 32 random bytes and BootstrapAPIKey
+// turns down those shorter than minBootstrapKeyLength.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	mockproviders "github.com/zde37/Jusgo/internal/mock"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var stored models.APIKey
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+	repo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(_ context.Context, key models.APIKey) (models.APIKey, error) {
+		stored = key
+		return key, nil
+	})
+
+	service := NewService(repo)
+	scopes := []string{models.ScopeJokesWrite, models.ScopeJokesDelete, models.ScopeJokesWrite}
+	key, secret, err := service.Srvc.CreateAPIKey(context.Background(), "ci", scopes, nil)
+	require.NoError(t, err)
+	require.Equal(t, stored, key)
+
+	require.True(t, strings.HasPrefix(secret, apiKeyPrefix))
+	require.True(t, strings.HasPrefix(secret, key.Prefix))
+	require.Equal(t, hashAPIKey(secret), key.Hash)
+	require.NotContains(t, key.Hash, secret)
+	require.Equal(t, []string{models.ScopeJokesDelete, models.ScopeJokesWrite}, key.Scopes)
+	require.Nil(t, key.ExpiresAt)
+	require.Empty(t, key.ID) // given by the repository
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	secret := apiKeyPrefix + "secret"
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	key := models.APIKey{ID: repository.NewID(), Name: "ci", Hash: hashAPIKey(secret), Scopes: []string{models.ScopeJokesWrite}}
+
+	testData := []struct {
+		Name    string
+		stub    func(repo *mockproviders.MockRepositoryProvider)
+		wantErr error
+	}{
+		{
+			Name: "OK",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				active := key
+				active.ExpiresAt = &future
+				repo.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Eq(key.Hash)).Times(1).Return(active, nil)
+			},
+		},
+		{
+			Name: "Unknown",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Times(1).Return(models.APIKey{}, repository.ErrNotFound)
+			},
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			Name: "Expired",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				expired := key
+				expired.ExpiresAt = &past
+				repo.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Times(1).Return(expired, nil)
+			},
+			wantErr: ErrInvalidAPIKey,
+		},
+		{
+			Name: "Revoked",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				revoked := key
+				revoked.RevokedAt = &past
+				repo.EXPECT().GetAPIKeyByHash(gomock.Any(), gomock.Any()).Times(1).Return(revoked, nil)
+			},
+			wantErr: ErrInvalidAPIKey,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			tc.stub(repo)
+
+			service := NewService(repo)
+			authenticated, err := service.Srvc.AuthenticateAPIKey(context.Background(), secret)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, key.ID, authenticated.ID)
+		})
+	}
+}
+
+func TestBootstrapAPIKey(t *testing.T) {
+	const bootstrapSecret = "3zQ9yXw1Vn6bR2kLp8Tt4Hd7Gm0Fc5Ja"
+	existing := models.APIKey{ID: repository.NewID(), Name: "ci"}
+
+	testData := []struct {
+		Name   string
+		stub   func(repo *mockproviders.MockRepositoryProvider)
+		wantOK bool
+	}{
+		{
+			Name: "No keys",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetAPIKeys(gomock.Any()).Times(1).Return(nil, nil)
+				repo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(_ context.Context, key models.APIKey) (models.APIKey, error) {
+					return key, nil
+				})
+			},
+			wantOK: true,
+		},
+		{
+			Name: "Keys exist",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetAPIKeys(gomock.Any()).Times(1).Return([]models.APIKey{existing}, nil)
+				repo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			Name: "Stored by another instance",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetAPIKeys(gomock.Any()).Times(1).Return(nil, nil)
+				repo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(1).Return(models.APIKey{}, repository.ErrDuplicateKey)
+			},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			tc.stub(repo)
+
+			service := NewService(repo)
+			key, ok, err := service.Srvc.BootstrapAPIKey(context.Background(), bootstrapSecret)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantOK, ok)
+			if !tc.wantOK {
+				return
+			}
+			require.Equal(t, bootstrapKeyName, key.Name)
+			require.Equal(t, bootstrapKeyPrefix, key.Prefix)
+			require.Equal(t, hashAPIKey(bootstrapSecret), key.Hash)
+			require.ElementsMatch(t, models.Scopes, key.Scopes)
+			require.Nil(t, key.ExpiresAt)
+		})
+	}
+}
+
+func TestBootstrapAPIKeyTooShort(t *testing.T) {
+	existing := models.APIKey{ID: repository.NewID(), Name: "ci"}
+
+	testData := []struct {
+		Name    string
+		keys    []models.APIKey
+		wantErr error
+	}{
+		{
+			Name:    "No keys",
+			wantErr: ErrWeakBootstrapKey,
+		},
+		{
+			// it is ignored anyway, a deployment with an old short TOKEN still starts
+			Name: "Keys exist",
+			keys: []models.APIKey{existing},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			repo.EXPECT().GetAPIKeys(gomock.Any()).Times(1).Return(tc.keys, nil)
+			repo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).Times(0)
+
+			service := NewService(repo)
+			_, ok, err := service.Srvc.BootstrapAPIKey(context.Background(), "root")
+			require.ErrorIs(t, err, tc.wantErr)
+			require.False(t, ok)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/audit"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+// addRevision records rev for the actor and request ctx carries. Call it with the context of the
+// transaction that makes the change, so the change isn't stored without its revision.
+func (s *serviceImpl) addRevision(ctx context.Context, rev models.Revision) error {
+	rev.Actor = audit.Actor(ctx)
+	rev.RequestID = audit.RequestID(ctx)
+	rev.CreatedAt = time.Now()
+	_, err := s.repo.AddRevision(ctx, rev)
+	return err
+}
+
+func (s *serviceImpl) GetJokeHistory(ctx context.Context, id string, page, limit int) ([]models.Revision, int64, error) {
+	total, err := s.repo.CountRevisions(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	revisions, err := s.repo.GetRevisions(ctx, id, int64((page-1)*limit), int64(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	return revisions, total, nil
+}
+
+func (s *serviceImpl) RevertJoke(ctx context.Context, id string, number int64, force bool) (models.Jusgo, error) {
+	var joke models.Jusgo
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		rev, err := s.repo.GetRevision(ctx, id, number)
+		if err != nil {
+			return err
+		}
+		if rev.New == nil {
+			return ErrNothingToRevert
+		}
+
+		current, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		joke = current
+		joke.SetContent(*rev.New)
+		normalize(&joke)
+		if err := s.checkNewText(ctx, current, joke, force); err != nil {
+			return err
+		}
+		joke.UpdatedAt = time.Now()
+		if joke, err = s.repo.Update(ctx, joke); err != nil {
+			return err
+		}
+		return s.addRevision(ctx, models.Revision{
+			JokeID: joke.ID, Action: models.RevisionRevert, Old: current.Content(), New: joke.Content(), Reverted: number,
+		})
+	})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return joke, s.duplicateKeyError(ctx, joke, err)
+	}
+	return joke, err
+}
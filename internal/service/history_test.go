@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/audit"
+	mockproviders "github.com/zde37/Jusgo/internal/mock"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRevertJoke(t *testing.T) {
+	ctx := context.Background()
+	current := createJoke()
+	first := &models.JokeContent{Type: models.TypeTwoPart, Setup: "Knock knock", Delivery: "Race condition", Category: "go"}
+
+	testData := []struct {
+		Name    string
+		stub    func(repo *mockproviders.MockRepositoryProvider)
+		wantErr error
+	}{
+		{
+			Name: "OK",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetRevision(gomock.Any(), gomock.Eq(current.ID), gomock.Eq(int64(1))).Times(1).
+					Return(models.Revision{JokeID: current.ID, Number: 1, Action: models.RevisionCreate, New: first}, nil)
+				repo.EXPECT().Get(gomock.Any(), gomock.Eq(current.ID)).Times(1).Return(current, nil)
+				repo.EXPECT().GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]models.Jusgo{current}, nil)
+				repo.EXPECT().Update(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, joke models.Jusgo) (models.Jusgo, error) {
+						require.Equal(t, current.ID, joke.ID)
+						require.Equal(t, current.CreatedAt, joke.CreatedAt)
+						require.Equal(t, "knock knock race condition", joke.Normalized)
+						return joke, nil
+					},
+				)
+				repo.EXPECT().AddRevision(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, rev models.Revision) (models.Revision, error) {
+						require.Equal(t, models.RevisionRevert, rev.Action)
+						require.Equal(t, int64(1), rev.Reverted)
+						require.Equal(t, current.Content(), rev.Old)
+						require.Equal(t, first, rev.New)
+						require.Equal(t, "admin", rev.Actor)
+						require.Equal(t, "request", rev.RequestID)
+						return rev, nil
+					},
+				)
+			},
+		},
+		{
+			Name: "Similar to another joke",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				other := createJoke()
+				other.Type, other.Joke, other.Setup, other.Delivery = models.TypeTwoPart, "", "Knock, knock!", "Race condition"
+				repo.EXPECT().GetRevision(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(models.Revision{JokeID: current.ID, Number: 1, Action: models.RevisionCreate, New: first}, nil)
+				repo.EXPECT().Get(gomock.Any(), gomock.Any()).Times(1).Return(current, nil)
+				repo.EXPECT().GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]models.Jusgo{other}, nil)
+			},
+			wantErr: &DuplicateError{},
+		},
+		{
+			Name: "Revision deleted the joke",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetRevision(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					Return(models.Revision{JokeID: current.ID, Number: 1, Action: models.RevisionDelete, Old: first}, nil)
+			},
+			wantErr: ErrNothingToRevert,
+		},
+		{
+			Name: "Missing revision",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetRevision(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(models.Revision{}, repository.ErrNotFound)
+			},
+			wantErr: repository.ErrNotFound,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			expectTransactions(repo)
+			tc.stub(repo)
+
+			ctx := audit.WithRequestID(audit.WithActor(ctx, "admin"), "request")
+			service := NewService(repo)
+			joke, err := service.Srvc.RevertJoke(ctx, current.ID, 1, false)
+			switch want := tc.wantErr.(type) {
+			case nil:
+			case *DuplicateError:
+				require.ErrorAs(t, err, &want)
+				return
+			default:
+				require.ErrorIs(t, err, want)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, first.Setup, joke.Setup)
+			require.Equal(t, first.Delivery, joke.Delivery)
+			require.Equal(t, models.TypeTwoPart, joke.Type)
+			require.Empty(t, joke.Joke)
+		})
+	}
+}
+
+func TestGetJokeHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	joke := createJoke()
+	revisions := []models.Revision{{JokeID: joke.ID, Number: 11}, {JokeID: joke.ID, Number: 12}}
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+	repo.EXPECT().CountRevisions(gomock.Any(), gomock.Eq(joke.ID)).Times(1).Return(int64(12), nil)
+	repo.EXPECT().GetRevisions(gomock.Any(), gomock.Eq(joke.ID), gomock.Eq(int64(10)), gomock.Eq(int64(10))).Times(1).Return(revisions, nil)
+
+	service := NewService(repo)
+	got, total, err := service.Srvc.GetJokeHistory(context.Background(), joke.ID, 2, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(12), total)
+	require.Equal(t, revisions, got)
+}
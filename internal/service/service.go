@@ -2,17 +2,118 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/zde37/Jusgo/internal/models"
 	"github.com/zde37/Jusgo/internal/repository"
 )
 
 type ServiceProvider interface {
-	CreateJoke(ctx context.Context, data models.Jusgo) (models.Jusgo, error)
+	// CreateJoke stores data unless it duplicates a stored joke, see DuplicateError. force
+	// skips the check for similar jokes, jokes with the same normalized text are never stored.
+	CreateJoke(ctx context.Context, data models.Jusgo, force bool) (models.Jusgo, error)
+	CreateJokes(ctx context.Context, data []models.Jusgo) ([]models.Jusgo, []error, error)
 	GetJoke(ctx context.Context, id string) (models.Jusgo, error)
-	UpdateJoke(ctx context.Context, data models.Jusgo) (models.Jusgo, error)
-	DeleteJoke(ctx context.Context, id string) error
-	GetAllJokes(ctx context.Context, page, limit int) ([]models.Jusgo, error)
+	// UpdateJoke replaces the joke with data if it is still at version, or at any version for AnyVersion.
+	// It fails with repository.ErrVersionConflict if the joke was changed since, and returns the stored joke.
+	// A changed text is checked for duplicates like CreateJoke does, force works the same way.
+	UpdateJoke(ctx context.Context, data models.Jusgo, version int64, force bool) (models.Jusgo, error)
+	// PatchJoke is UpdateJoke for a change to the stored joke with id: patch is called on a copy of it
+	// and the result is stored. An error from patch, like a failed validation, is returned as is.
+	PatchJoke(ctx context.Context, id string, patch func(*models.Jusgo) error, version int64, force bool) (models.Jusgo, error)
+	// DeleteJoke moves the joke with id to the trash, where it stays until it is restored or purged.
+	// Like UpdateJoke, it only does so if the joke is still at version.
+	DeleteJoke(ctx context.Context, id string, version int64) error
+	GetAllJokes(ctx context.Context, query models.JokeQuery) ([]models.Jusgo, int64, error)
+	GetJokesAfter(ctx context.Context, filter models.JokeFilter, after string, limit int) ([]models.Jusgo, bool, error)
+	ExportJokes(ctx context.Context, fn func(models.Jusgo) error) error
+	SearchJokes(ctx context.Context, query string, page, limit int) ([]models.SearchResult, error)
+	GetRandomJokes(ctx context.Context, filter models.JokeFilter, count int, exclude []string) ([]models.Jusgo, error)
+	GetDailyJoke(ctx context.Context, date time.Time) (models.Jusgo, error)
+	// SignJokes gives the jokes stored before signatures existed their dedupe.Signature, so they are found
+	// as duplicates, and returns how many it signed.
+	SignJokes(ctx context.Context) (int64, error)
+
+	// RestoreJoke takes the joke with id out of the trash. It fails with a DuplicateError when a joke
+	// with the same normalized text was stored while it was in the trash.
+	RestoreJoke(ctx context.Context, id string) (models.Jusgo, error)
+	// PurgeJoke permanently removes the joke with id from the trash. Its history is kept, ending in a purge revision.
+	PurgeJoke(ctx context.Context, id string) error
+	// PurgeTrash is PurgeJoke for the jokes that were moved to the trash before before, it returns how many there were.
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+
+	// GetJokeHistory returns a page of the revisions of the joke with id, oldest first, and their total.
+	// CreateJoke, CreateJokes, UpdateJoke, PatchJoke, DeleteJoke, RestoreJoke and RevertJoke each record a revision
+	// for the actor and request the context carries, see the audit package.
+	GetJokeHistory(ctx context.Context, id string, page, limit int) ([]models.Revision, int64, error)
+	// RevertJoke puts the joke with id back to how revision number left it. It fails with ErrNothingToRevert
+	// for a revision that deleted the joke and with a DuplicateError like UpdateJoke.
+	RevertJoke(ctx context.Context, id string, number int64, force bool) (models.Jusgo, error)
+
+	// SubmitJoke queues data as a pending submission, unless it duplicates a stored joke.
+	SubmitJoke(ctx context.Context, data models.Submission) (models.Submission, error)
+	GetSubmission(ctx context.Context, id string) (models.Submission, error)
+	// GetSubmissions returns a page of the submissions with status, any status if it is empty, and their total.
+	GetSubmissions(ctx context.Context, status string, page, limit int) ([]models.Submission, int64, error)
+	// UpdateSubmission replaces the joke of the pending submission with id with the joke in data.
+	UpdateSubmission(ctx context.Context, id string, data models.Submission) (models.Submission, error)
+	// ApproveSubmission stores a pending submission as a new joke, checked like CreateJoke does, and returns the joke.
+	ApproveSubmission(ctx context.Context, id string, force bool) (models.Jusgo, error)
+	RejectSubmission(ctx context.Context, id, reason string) (models.Submission, error)
+
+	// Vote counts an up or down vote by client on the joke with id and returns the joke with its new votes.
+	// A client gets one vote per joke every models.VoteWindow, ErrAlreadyVoted is returned for more.
+	Vote(ctx context.Context, id, client string, up bool) (models.Jusgo, error)
+	// GetTopJokes returns up to limit of the best rated jokes, counting only the votes cast at or
+	// after since. A zero since counts every vote. Jokes that are rated 0 are left out.
+	GetTopJokes(ctx context.Context, since time.Time, limit int) ([]models.RankedJoke, error)
+
+	// CreateAPIKey stores a new key with scopes and returns it with its secret. Only a hash of the secret
+	// is stored, it can't be looked up again. A nil expiresAt makes a key that never expires.
+	CreateAPIKey(ctx context.Context, name string, scopes []string, expiresAt *time.Time) (models.APIKey, string, error)
+	// BootstrapAPIKey stores secret as a key with every scope, named "bootstrap", if no key was ever stored.
+	// It is how the first keys are made, and can be revoked like any other key once they are. ok is false
+	// if there were keys already. A secret too short to be safe fails with ErrWeakBootstrapKey when it would be stored.
+	BootstrapAPIKey(ctx context.Context, secret string) (key models.APIKey, ok bool, err error)
+	// AuthenticateAPIKey returns the key secret belongs to, or ErrInvalidAPIKey if there is no such key
+	// or it expired or was revoked.
+	AuthenticateAPIKey(ctx context.Context, secret string) (models.APIKey, error)
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	// RevokeAPIKey stops the key with id from authenticating and returns it. Revoking a key twice is fine.
+	RevokeAPIKey(ctx context.Context, id string) (models.APIKey, error)
+}
+
+// AnyVersion makes UpdateJoke and DeleteJoke change a joke whatever its version, see models.Jusgo.Version.
+const AnyVersion int64 = -1
+
+// ErrAlreadyVoted is returned when a client votes on a joke more than once in a models.VoteWindow.
+var ErrAlreadyVoted = errors.New("already voted on this joke, try again later")
+
+// ErrNotPending is returned when a submission that was already approved or rejected is changed.
+var ErrNotPending = repository.ErrNotPending
+
+// ErrNothingToRevert is returned when reverting to a revision that deleted its joke.
+var ErrNothingToRevert = errors.New("revision deleted the joke, there is nothing to revert to")
+
+// ErrInvalidAPIKey is returned for a secret that doesn't belong to an active API key.
+var ErrInvalidAPIKey = errors.New("invalid, expired or revoked api key")
+
+// ErrWeakBootstrapKey is returned by BootstrapAPIKey for a secret shorter than 32 characters.
+var ErrWeakBootstrapKey = errors.New("bootstrap key must be at least 32 characters, e.g. the output of openssl rand -base64 32")
+
+// DuplicateError is returned when a joke has the same normalized text as a stored one or is too similar to it.
+type DuplicateError struct {
+	ID         string  // the stored joke
+	Similarity float64 // 1 for the same normalized text, see dedupe.Similarity
+}
+
+func (e *DuplicateError) Error() string {
+	if e.Similarity >= 1 {
+		return fmt.Sprintf("joke already exists as %s", e.ID)
+	}
+	return fmt.Sprintf("joke is %.0f%% similar to %s", e.Similarity*100, e.ID)
 }
 
 type Service struct {
@@ -1,12 +1,16 @@
 package service
 
 import (
-	"context" 
-	// "time"
+	"context"
+	"errors"
+	"math/bits"
+	"slices"
+	"time"
 
+	"github.com/zde37/Jusgo/internal/dedupe"
 	"github.com/zde37/Jusgo/internal/models"
 	"github.com/zde37/Jusgo/internal/repository"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/zde37/Jusgo/internal/search"
 )
 
 type serviceImpl struct {
@@ -19,35 +23,429 @@ func newServiceImpl(repo repository.RepositoryProvider) *serviceImpl {
 	}
 }
 
-func (s *serviceImpl) CreateJoke(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
-	return s.repo.Create(ctx, data)
+func (s *serviceImpl) CreateJoke(ctx context.Context, data models.Jusgo, force bool) (models.Jusgo, error) {
+	normalize(&data)
+	joke := data
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		joke, err = s.createJoke(ctx, data, force)
+		return err
+	})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return joke, s.duplicateKeyError(ctx, data, err)
+	}
+	return joke, err
+}
+
+// createJoke stores data, which must be normalized, and records the revision. Unless force is set it fails
+// with a DuplicateError if a similar joke is stored. It runs in the caller's transaction, which turns an
+// ErrDuplicateKey into a DuplicateError with duplicateKeyError once the transaction is over.
+func (s *serviceImpl) createJoke(ctx context.Context, data models.Jusgo, force bool) (models.Jusgo, error) {
+	if !force {
+		dup, err := s.findDuplicate(ctx, data)
+		if err != nil {
+			return data, err
+		}
+		if dup != nil {
+			return data, dup
+		}
+	}
+
+	joke, err := s.repo.Create(ctx, data)
+	if err != nil {
+		return joke, err
+	}
+	return joke, s.addRevision(ctx, models.Revision{JokeID: joke.ID, Action: models.RevisionCreate, New: joke.Content()})
+}
+
+// normalize sets the normalized text of joke and its signature, which every write of its text stores along.
+func normalize(joke *models.Jusgo) {
+	joke.Normalized = dedupe.Normalize(joke.Text())
+	joke.Signature = dedupe.Signature(joke.Normalized)
+}
+
+// maxDuplicateCandidates caps how many jokes sharing a bucket with a joke are compared to it. Jokes that
+// are that much alike are rare, the cap keeps a joke made of the most common trigrams from reading them all.
+const maxDuplicateCandidates = 200
+
+// findDuplicate returns the stored joke most similar to joke if any is similar enough, see dedupe.Threshold.
+// Only the jokes that share a bucket of their dedupe.Signature with joke are compared to it.
+func (s *serviceImpl) findDuplicate(ctx context.Context, joke models.Jusgo) (*DuplicateError, error) {
+	candidates, err := s.repo.GetSimilar(ctx, dedupe.Signature(joke.Normalized), maxDuplicateCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	trigrams := dedupe.Trigrams(joke.Normalized)
+	var dup *DuplicateError
+	for _, stored := range candidates {
+		if stored.ID == joke.ID {
+			continue
+		}
+
+		normalized := stored.Normalized
+		if normalized == "" { // stored before jokes were normalized
+			normalized = dedupe.Normalize(stored.Text())
+		}
+		if normalized == joke.Normalized {
+			return &DuplicateError{ID: stored.ID, Similarity: 1}, nil
+		}
+
+		similarity := dedupe.Jaccard(trigrams, dedupe.Trigrams(normalized))
+		if similarity >= dedupe.Threshold && (dup == nil || similarity > dup.Similarity) {
+			dup = &DuplicateError{ID: stored.ID, Similarity: similarity}
+		}
+	}
+	return dup, nil
+}
+
+func (s *serviceImpl) SignJokes(ctx context.Context) (int64, error) {
+	var signed int64
+	for _, deleted := range []bool{false, true} {
+		after := ""
+		for {
+			jokes, err := s.repo.GetAfter(ctx, models.JokeFilter{Unsigned: true, Deleted: deleted}, after, exportBatchSize)
+			if err != nil {
+				return signed, err
+			}
+
+			for _, joke := range jokes {
+				normalized := joke.Normalized
+				if normalized == "" { // stored before jokes were normalized, or in the trash
+					normalized = dedupe.Normalize(joke.Text())
+				}
+				if err := s.repo.SetSignature(ctx, joke.ID, dedupe.Signature(normalized)); err != nil {
+					return signed, err
+				}
+				signed++
+			}
+			if len(jokes) < exportBatchSize {
+				break
+			}
+			// a joke too short for a signature stays unsigned, going on after it keeps it from being read again
+			after = jokes[len(jokes)-1].ID
+		}
+	}
+	return signed, nil
+}
+
+// duplicateKeyError turns the ErrDuplicateKey the repository returned for joke into a DuplicateError
+// naming the joke with the same normalized text. err is returned as is if there is no such joke.
+func (s *serviceImpl) duplicateKeyError(ctx context.Context, joke models.Jusgo, err error) error {
+	stored, findErr := s.repo.GetByNormalized(ctx, joke.Normalized)
+	if findErr != nil || stored.ID == joke.ID {
+		return err
+	}
+	return &DuplicateError{ID: stored.ID, Similarity: 1}
+}
+
+// createBatchSize caps how many jokes go to the repository at once, so big imports
+// don't end up in a single huge transaction.
+const createBatchSize = 500
+
+// CreateJokes stores data in batches. jokes[i] is data[i] as it was stored, with its ID, and errs[i] its error,
+// or nil if it was stored.
+// When a batch fails as a whole, the error is returned and the jokes of later batches are not stored.
+// Jokes with the same normalized text as a stored joke fail with repository.ErrDuplicateKey, there is no
+// check for similar jokes so an import stores exactly what it is given.
+// A batch and the revisions of its jokes are stored in one transaction, a batch that fails leaves nothing behind.
+func (s *serviceImpl) CreateJokes(ctx context.Context, data []models.Jusgo) ([]models.Jusgo, []error, error) {
+	data = slices.Clone(data)
+	for i := range data {
+		normalize(&data[i])
+	}
+
+	errs := make([]error, 0, len(data))
+	for start := 0; start < len(data); start += createBatchSize {
+		batch := data[start:min(start+createBatchSize, len(data))]
+		var batchErrs []error
+		err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			if batchErrs, err = s.repo.CreateMany(ctx, batch); err != nil {
+				return err
+			}
+			for i, joke := range batch {
+				if batchErrs[i] != nil {
+					continue
+				}
+				err := s.addRevision(ctx, models.Revision{JokeID: joke.ID, Action: models.RevisionCreate, New: joke.Content()})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		errs = append(errs, batchErrs...)
+	}
+	return data, errs, nil
 }
 
 func (s *serviceImpl) GetJoke(ctx context.Context, id string) (models.Jusgo, error) {
-	objectID, err := primitive.ObjectIDFromHex(id)
+	return s.repo.Get(ctx, id)
+}
+
+// GetAllJokes returns the page of jokes query asks for together with how many match its filter in total.
+func (s *serviceImpl) GetAllJokes(ctx context.Context, query models.JokeQuery) ([]models.Jusgo, int64, error) {
+	total, err := s.repo.Count(ctx, query.Filter)
 	if err != nil {
-		return models.Jusgo{}, err
+		return nil, 0, err
 	}
 
-	return s.repo.Get(ctx, objectID)
+	skip := (query.Page - 1) * query.Limit
+	jokes, err := s.repo.GetAll(ctx, query.Filter, query.Sort, int64(skip), int64(query.Limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	return jokes, total, nil
+}
+
+// GetJokesAfter returns up to limit jokes matching filter that come after the joke with ID after,
+// and whether there are more past them.
+func (s *serviceImpl) GetJokesAfter(ctx context.Context, filter models.JokeFilter, after string, limit int) ([]models.Jusgo, bool, error) {
+	// one more than asked for tells if there is another page without counting
+	jokes, err := s.repo.GetAfter(ctx, filter, after, int64(limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(jokes) > limit {
+		return jokes[:limit], true, nil
+	}
+	return jokes, false, nil
+}
+
+const exportBatchSize = 500
+
+// ExportJokes calls fn for every stored joke in creation order, stopping at the first error.
+func (s *serviceImpl) ExportJokes(ctx context.Context, fn func(models.Jusgo) error) error {
+	return s.forEachJoke(ctx, fn)
 }
 
-// TODO: set default to limit -> 10, page -> 1 
-func (s *serviceImpl) GetAllJokes(ctx context.Context, page, limit int) ([]models.Jusgo, error) {
+// forEachJoke calls fn for every stored joke in creation order, stopping at the first error.
+// Jokes are read in batches by ID rather than through one long running query, so a slow fn
+// neither holds a database connection nor the whole corpus in memory.
+func (s *serviceImpl) forEachJoke(ctx context.Context, fn func(models.Jusgo) error) error {
+	after := ""
+	for {
+		jokes, err := s.repo.GetAfter(ctx, models.JokeFilter{}, after, exportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, joke := range jokes {
+			if err := fn(joke); err != nil {
+				return err
+			}
+		}
+		if len(jokes) < exportBatchSize {
+			return nil
+		}
+		after = jokes[len(jokes)-1].ID
+	}
+}
+
+// SearchJokes returns a page of the jokes matching query, with the matched words highlighted.
+func (s *serviceImpl) SearchJokes(ctx context.Context, query string, page, limit int) ([]models.SearchResult, error) {
+	parsed := search.Parse(query)
+	if parsed.Empty() {
+		return []models.SearchResult{}, nil
+	}
+
 	skip := (page - 1) * limit
-	return s.repo.GetAll(ctx, int64(skip), int64(limit))
+	results, err := s.repo.Search(ctx, query, int64(skip), int64(limit))
+	if err != nil {
+		return nil, err
+	}
 
+	for i := range results {
+		results[i].Highlight = parsed.Highlight(results[i].Text())
+	}
+	return results, nil
 }
 
-func (s *serviceImpl) UpdateJoke(ctx context.Context, data models.Jusgo) (models.Jusgo, error) {
-	return s.repo.Update(ctx, data)
+func (s *serviceImpl) GetRandomJokes(ctx context.Context, filter models.JokeFilter, count int, exclude []string) ([]models.Jusgo, error) {
+	return s.repo.GetRandom(ctx, filter, int64(count), exclude)
 }
 
-func (s *serviceImpl) DeleteJoke(ctx context.Context, id string) error {
-	objectID, err := primitive.ObjectIDFromHex(id)
+// GetDailyJoke picks the joke of the day for the calendar date of date. The pick only depends on the date
+// and the jokes that were stored before the date began, so every replica returns the same joke for a day
+// and jokes added during the day or later don't change it. Jokes deleted from that pool do, they shift
+// the positions of the jokes after them.
+func (s *serviceImpl) GetDailyJoke(ctx context.Context, date time.Time) (models.Jusgo, error) {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	pool := models.JokeFilter{CreatedBefore: start.Add(-dailyEarliestOffset)}
+	total, err := s.repo.Count(ctx, pool)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+	if total == 0 {
+		return models.Jusgo{}, repository.ErrNotFound
+	}
+
+	jokes, err := s.repo.GetAll(ctx, pool, models.JokeSort{}, dailyIndex(start.Unix()/86400, total), 1)
+	if err != nil {
+		return models.Jusgo{}, err
+	}
+	if len(jokes) == 0 { // a joke was deleted since we counted
+		return models.Jusgo{}, repository.ErrNotFound
+	}
+
+	return jokes[0], nil
+}
+
+// dailyEarliestOffset is the UTC offset of the time zone a date begins in first, UTC+14. A date's pool is
+// fixed before the date began anywhere, so a joke added during someone's day never changes their joke.
+const dailyEarliestOffset = 14 * time.Hour
+
+// dailySeed keeps the daily order from simply following the order jokes were added in.
+const dailySeed = 0x4a7573676f // "Jusgo"
+
+// dailyIndex maps a day to the position of its joke among total jokes. Days are grouped in cycles
+// of total days and each cycle walks a different shuffle of all jokes, so no joke repeats
+// within a cycle as long as total doesn't change. A day with a bigger pool may land in another
+// cycle, so jokes added in the middle of a cycle can make it show a joke again early.
+func dailyIndex(day, total int64) int64 {
+	cycle, offset := day/total, day%total
+	if offset < 0 { // days before 1970
+		cycle, offset = cycle-1, offset+total
+	}
+
+	if total <= 2 { // nothing to shuffle, alternating is the only order without repeats
+		return offset
+	}
+
+	if dailyPosition(cycle, total, 0) == dailyPosition(cycle-1, total, total-1) {
+		// don't start a cycle with the joke the previous one ended on
+		switch offset {
+		case 0:
+			offset = 1
+		case 1:
+			offset = 0
+		}
+	}
+	return dailyPosition(cycle, total, offset)
+}
+
+// dailyRounds is the number of Feistel rounds dailyPosition runs, enough to look random for a daily pick.
+const dailyRounds = 4
+
+// dailyPosition returns the position at offset in the shuffle of total jokes for a cycle. The shuffle is never
+// built, a Feistel network keyed by the cycle permutes the numbers below the next power of 4 and offsets
+// that land outside [0, total) are fed through it again until they don't, which keeps it a permutation
+// of [0, total). It costs the same and takes no memory whatever total is, and only uses integer math,
+// so every Go version and replica computes the same shuffle.
+func dailyPosition(cycle, total, offset int64) int64 {
+	half := (bits.Len64(uint64(total-1)) + 1) / 2
+	mask := uint64(1)<<half - 1
+
+	key := mix(uint64(cycle) ^ dailySeed)
+	x := uint64(offset)
+	for {
+		left, right := x>>half, x&mask
+		for round := range uint64(dailyRounds) {
+			left, right = right, left^(mix(key^round<<56^right)&mask)
+		}
+		if x = left<<half | right; x < uint64(total) {
+			return int64(x)
+		}
+	}
+}
+
+// mix is the splitmix64 finalizer, every bit of x affects every bit of the result.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// UpdateJoke fails with a DuplicateError if the new text is the same as, or unless force is set similar to, that of another joke.
+func (s *serviceImpl) UpdateJoke(ctx context.Context, data models.Jusgo, version int64, force bool) (models.Jusgo, error) {
+	return s.updateJoke(ctx, data.ID, version, force, func(models.Jusgo) (models.Jusgo, error) {
+		return data, nil
+	})
+}
+
+func (s *serviceImpl) PatchJoke(ctx context.Context, id string, patch func(*models.Jusgo) error, version int64, force bool) (models.Jusgo, error) {
+	return s.updateJoke(ctx, id, version, force, func(old models.Jusgo) (models.Jusgo, error) {
+		joke := old
+		joke.Tags = slices.Clone(old.Tags)
+		if err := patch(&joke); err != nil {
+			return joke, err
+		}
+		joke.UpdatedAt = time.Now()
+		return joke, nil
+	})
+}
+
+// updateJoke replaces the joke with id, if it is at version, by what change makes of it and records the revision.
+// A new text is checked for similar jokes like CreateJoke does unless force is set.
+func (s *serviceImpl) updateJoke(ctx context.Context, id string, version int64, force bool, change func(old models.Jusgo) (models.Jusgo, error)) (models.Jusgo, error) {
+	var data, joke models.Jusgo
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		old, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && version != old.Version {
+			return repository.ErrVersionConflict
+		}
+
+		if data, err = change(old); err != nil {
+			return err
+		}
+		data.ID = old.ID
+		normalize(&data)
+		if err := s.checkNewText(ctx, old, data, force); err != nil {
+			return err
+		}
+		// the repository checks the version again, in case the joke changes before the update
+		data.Version = old.Version
+		if joke, err = s.repo.Update(ctx, data); err != nil {
+			return err
+		}
+		return s.addRevision(ctx, models.Revision{
+			JokeID: joke.ID, Action: models.RevisionUpdate, Old: old.Content(), New: joke.Content(),
+		})
+	})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return joke, s.duplicateKeyError(ctx, data, err)
+	}
+	return joke, err
+}
+
+// checkNewText returns a DuplicateError if joke, which is old changed, got a text too similar to another joke.
+// Changes that leave the text alone aren't checked, nor any with force.
+func (s *serviceImpl) checkNewText(ctx context.Context, old, joke models.Jusgo, force bool) error {
+	if force || (old.Normalized != "" && old.Normalized == joke.Normalized) {
+		return nil
+	}
+	dup, err := s.findDuplicate(ctx, joke)
 	if err != nil {
 		return err
 	}
+	if dup != nil {
+		return dup
+	}
+	return nil
+}
 
-	return s.repo.Delete(ctx, objectID)
+func (s *serviceImpl) DeleteJoke(ctx context.Context, id string, version int64) error {
+	return s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		joke, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version != AnyVersion && version != joke.Version {
+			return repository.ErrVersionConflict
+		}
+		if err := s.repo.Delete(ctx, id, joke.Version); err != nil {
+			return err
+		}
+		return s.addRevision(ctx, models.Revision{JokeID: joke.ID, Action: models.RevisionDelete, Old: joke.Content()})
+	})
 }
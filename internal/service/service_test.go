@@ -2,19 +2,25 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/dedupe"
 	mockproviders "github.com/zde37/Jusgo/internal/mock"
 	"github.com/zde37/Jusgo/internal/models"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/zde37/Jusgo/internal/repository"
 	"go.uber.org/mock/gomock"
 )
 
 func TestCreateJoke(t *testing.T) {
 	ctx := context.Background()
 	joke := createJoke()
+	stored := joke
+	stored.Normalized = "roses are red violets are blue unknown error on line 42"
+	stored.Signature = dedupe.Signature(stored.Normalized)
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -23,15 +29,163 @@ func TestCreateJoke(t *testing.T) {
 
 	// build stubs
 	repo.EXPECT().
-		Create(gomock.Any(), gomock.Any()).
+		GetSimilar(gomock.Any(), gomock.Eq(stored.Signature), gomock.Any()).
 		Times(1).
-		Return(joke, nil)
+		Return([]models.Jusgo{}, nil)
+	expectTransactions(repo)
+	repo.EXPECT().
+		Create(gomock.Any(), gomock.Eq(stored)).
+		Times(1).
+		Return(stored, nil)
+	expectRevision(t, repo, models.RevisionCreate, nil, stored.Content())
 
 	service := NewService(repo)
-	createdJoke, err := service.Srvc.CreateJoke(ctx, joke)
+	createdJoke, err := service.Srvc.CreateJoke(ctx, joke, false)
 	require.NoError(t, err)
 	require.NotEmpty(t, createdJoke)
-	require.Equal(t, joke, createdJoke)
+	require.Equal(t, stored, createdJoke)
+}
+
+func TestCreateDuplicateJoke(t *testing.T) {
+	ctx := context.Background()
+	existing := createJoke()
+	existing.Joke = "Why do programmers prefer dark mode? Because light attracts bugs."
+	other := createJoke()
+	other.Joke = "I'm declaring a war. var war"
+	other.Normalized = "im declaring a war var war"
+
+	testData := []struct {
+		Name           string
+		joke           string
+		force          bool
+		stub           func(repo *mockproviders.MockRepositoryProvider)
+		wantSimilarity float64 // 0 when the joke gets stored
+	}{
+		{
+			Name: "Same text after normalizing",
+			joke: "why do programmers prefer dark-mode?? because light attracts bugs",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]models.Jusgo{other, existing}, nil)
+			},
+			wantSimilarity: 1,
+		},
+		{
+			Name: "Similar text",
+			joke: "Why do programmers prefer dark mode? Because the light attracts bugs!",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]models.Jusgo{other, existing}, nil)
+			},
+			wantSimilarity: dedupe.Threshold,
+		},
+		{
+			Name:  "Similar text with force",
+			joke:  "Why do programmers prefer dark mode? Because the light attracts bugs!",
+			force: true,
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, joke models.Jusgo) (models.Jusgo, error) { return joke, nil },
+				)
+			},
+		},
+		{
+			Name:  "Same text with force",
+			joke:  "I'm declaring a WAR! var war",
+			force: true,
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(models.Jusgo{}, repository.ErrDuplicateKey)
+				repo.EXPECT().GetByNormalized(gomock.Any(), gomock.Eq("im declaring a war var war")).Times(1).Return(other, nil)
+			},
+			wantSimilarity: 1,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			expectTransactions(repo)
+			repo.EXPECT().AddRevision(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(
+				func(_ context.Context, rev models.Revision) (models.Revision, error) { return rev, nil },
+			)
+			tc.stub(repo)
+
+			joke := createJoke()
+			joke.Joke = tc.joke
+
+			service := NewService(repo)
+			_, err := service.Srvc.CreateJoke(ctx, joke, tc.force)
+			if tc.wantSimilarity == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var dup *DuplicateError
+			require.ErrorAs(t, err, &dup)
+			require.GreaterOrEqual(t, dup.Similarity, tc.wantSimilarity)
+			if tc.wantSimilarity == 1 {
+				require.Contains(t, []string{existing.ID, other.ID}, dup.ID)
+			} else {
+				require.Equal(t, existing.ID, dup.ID)
+			}
+		})
+	}
+}
+
+func TestCreateJokes(t *testing.T) {
+	ctx := context.Background()
+	jokes := make([]models.Jusgo, createBatchSize+2)
+	for i := range jokes {
+		jokes[i] = createJoke()
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+
+	for i := range jokes {
+		jokes[i].Normalized = dedupe.Normalize(jokes[i].Joke)
+		jokes[i].Signature = dedupe.Signature(jokes[i].Normalized)
+	}
+
+	firstErrs := make([]error, createBatchSize)
+	firstErrs[3] = repository.ErrDuplicateKey
+	expectTransactions(repo)
+	gomock.InOrder(
+		repo.EXPECT().
+			CreateMany(gomock.Any(), gomock.Eq(jokes[:createBatchSize])).
+			Times(1).
+			Return(firstErrs, nil),
+		repo.EXPECT().
+			CreateMany(gomock.Any(), gomock.Eq(jokes[createBatchSize:])).
+			Times(1).
+			Return([]error{nil, nil}, nil),
+	)
+	// one for every joke that was stored
+	repo.EXPECT().
+		AddRevision(gomock.Any(), gomock.Any()).
+		Times(len(jokes) - 1).
+		DoAndReturn(func(ctx context.Context, rev models.Revision) (models.Revision, error) {
+			require.True(t, inTransaction(ctx)) // along with the batch
+			require.NotEqual(t, jokes[3].ID, rev.JokeID)
+			require.Equal(t, models.RevisionCreate, rev.Action)
+			return rev, nil
+		})
+
+	service := NewService(repo)
+	stored, errs, err := service.Srvc.CreateJokes(ctx, jokes)
+	require.NoError(t, err)
+	require.Equal(t, jokes, stored)
+	require.Len(t, errs, len(jokes))
+	for i, err := range errs {
+		if i == 3 {
+			require.ErrorIs(t, err, repository.ErrDuplicateKey)
+		} else {
+			require.NoError(t, err)
+		}
+	}
 }
 
 func TestGetJoke(t *testing.T) {
@@ -49,7 +203,7 @@ func TestGetJoke(t *testing.T) {
 		Return(joke, nil)
 
 	service := NewService(repo)
-	joke2, err := service.Srvc.GetJoke(ctx, joke.ID.Hex())
+	joke2, err := service.Srvc.GetJoke(ctx, joke.ID)
 	require.NoError(t, err)
 	require.NotEmpty(t, joke2)
 	require.Equal(t, joke, joke2)
@@ -58,8 +212,10 @@ func TestGetJoke(t *testing.T) {
 func TestGetAllJokes(t *testing.T) {
 	ctx := context.Background()
 	jokes := []models.Jusgo{createJoke(), createJoke(), createJoke(), createJoke(), createJoke(), createJoke(), createJoke(), createJoke(), createJoke(), createJoke()}
-	page, limit := 1, 10
+	page, limit := 2, 10
 	skip := (page - 1) * limit
+	filter := models.JokeFilter{Categories: []string{"git"}, Tags: []string{"pun"}, CreatedAfter: time.Now().AddDate(0, -1, 0)}
+	sort := models.JokeSort{Field: models.SortCreatedAt, Descending: true}
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -67,15 +223,286 @@ func TestGetAllJokes(t *testing.T) {
 	repo := mockproviders.NewMockRepositoryProvider(ctrl)
 
 	repo.EXPECT().
-		GetAll(gomock.Any(), gomock.Eq(int64(skip)), gomock.Eq(int64(limit))).
+		Count(gomock.Any(), gomock.Eq(filter)).
+		Times(1).
+		Return(int64(25), nil)
+	repo.EXPECT().
+		GetAll(gomock.Any(), gomock.Eq(filter), gomock.Eq(sort), gomock.Eq(int64(skip)), gomock.Eq(int64(limit))).
 		Times(1).
 		Return(jokes, nil)
 
 	service := NewService(repo)
-	allJokes, err := service.Srvc.GetAllJokes(ctx, page, limit)
+	allJokes, total, err := service.Srvc.GetAllJokes(ctx, models.JokeQuery{Filter: filter, Sort: sort, Page: page, Limit: limit})
+	require.NoError(t, err)
+	require.Len(t, allJokes, 10)
+	require.Equal(t, int64(25), total)
+}
+
+func TestGetJokesAfter(t *testing.T) {
+	ctx := context.Background()
+	jokes := []models.Jusgo{createJoke(), createJoke(), createJoke()}
+	after := createJoke().ID
+	filter := models.JokeFilter{Type: models.TypeTwoPart}
+
+	testData := []struct {
+		Name     string
+		limit    int
+		stored   []models.Jusgo
+		want     int
+		wantMore bool
+	}{
+		{Name: "More jokes left", limit: 2, stored: jokes, want: 2, wantMore: true},
+		{Name: "Exactly the last page", limit: 3, stored: jokes, want: 3, wantMore: false},
+		{Name: "Short last page", limit: 5, stored: jokes, want: 3, wantMore: false},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+
+			stored := tc.stored
+			if len(stored) > tc.limit+1 {
+				stored = stored[:tc.limit+1]
+			}
+			repo.EXPECT().
+				GetAfter(gomock.Any(), gomock.Eq(filter), gomock.Eq(after), gomock.Eq(int64(tc.limit+1))).
+				Times(1).
+				Return(stored, nil)
+
+			service := NewService(repo)
+			page, more, err := service.Srvc.GetJokesAfter(ctx, filter, after, tc.limit)
+			require.NoError(t, err)
+			require.Len(t, page, tc.want)
+			require.Equal(t, tc.stored[:tc.want], page)
+			require.Equal(t, tc.wantMore, more)
+		})
+	}
+}
+
+func TestExportJokes(t *testing.T) {
+	ctx := context.Background()
+	jokes := make([]models.Jusgo, exportBatchSize+1)
+	for i := range jokes {
+		jokes[i] = createJoke()
+	}
+	lastOfFirstBatch := jokes[exportBatchSize-1].ID
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+
+	gomock.InOrder(
+		repo.EXPECT().
+			GetAfter(gomock.Any(), gomock.Eq(models.JokeFilter{}), gomock.Eq(""), gomock.Eq(int64(exportBatchSize))).
+			Times(1).
+			Return(jokes[:exportBatchSize], nil),
+		repo.EXPECT().
+			GetAfter(gomock.Any(), gomock.Eq(models.JokeFilter{}), gomock.Eq(lastOfFirstBatch), gomock.Eq(int64(exportBatchSize))).
+			Times(1).
+			Return(jokes[exportBatchSize:], nil),
+	)
+
+	service := NewService(repo)
+	var exported []models.Jusgo
+	err := service.Srvc.ExportJokes(ctx, func(joke models.Jusgo) error {
+		exported = append(exported, joke)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, jokes, exported)
+
+	// an error from fn stops the export
+	repo.EXPECT().
+		GetAfter(gomock.Any(), gomock.Any(), gomock.Eq(""), gomock.Any()).
+		Times(1).
+		Return(jokes[:exportBatchSize], nil)
+
+	stop := errors.New("client went away")
+	calls := 0
+	err = service.Srvc.ExportJokes(ctx, func(models.Jusgo) error {
+		calls++
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, 1, calls)
+}
+
+func TestSearchJokes(t *testing.T) {
+	ctx := context.Background()
+	joke := createJoke()
+	joke.Joke = "To understand recursion you must first understand recursion"
+	page, limit := 2, 5
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+
+	repo.EXPECT().
+		Search(gomock.Any(), gomock.Eq("recursion"), gomock.Eq(int64(5)), gomock.Eq(int64(limit))).
+		Times(1).
+		Return([]models.SearchResult{{Jusgo: joke, Score: 1.2}}, nil)
+
+	service := NewService(repo)
+	results, err := service.Srvc.SearchJokes(ctx, "recursion", page, limit)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, joke, results[0].Jusgo)
+	require.Equal(t, "To understand <mark>recursion</mark> you must first understand <mark>recursion</mark>", results[0].Highlight)
+
+	// a query without words never reaches the repository
+	results, err = service.Srvc.SearchJokes(ctx, "-recursion", page, limit)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestGetRandomJokes(t *testing.T) {
+	ctx := context.Background()
+	jokes := []models.Jusgo{createJoke(), createJoke(), createJoke()}
+	exclude := []string{createJoke().ID}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+
+	repo.EXPECT().
+		GetRandom(gomock.Any(), gomock.Eq(models.JokeFilter{}), gomock.Eq(int64(3)), gomock.Eq(exclude)).
+		Times(1).
+		Return(jokes, nil)
+
+	service := NewService(repo)
+	randomJokes, err := service.Srvc.GetRandomJokes(ctx, models.JokeFilter{}, 3, exclude)
+	require.NoError(t, err)
+	require.Equal(t, jokes, randomJokes)
+}
+
+func TestGetDailyJoke(t *testing.T) {
+	ctx := context.Background()
+	joke := createJoke()
+	date := time.Date(2024, time.June, 1, 23, 30, 0, 0, time.FixedZone("UTC+5", 5*60*60))
+	day := time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC).Unix() / 86400
+	pool := models.JokeFilter{CreatedBefore: time.Date(2024, time.May, 31, 10, 0, 0, 0, time.UTC)}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+
+	repo.EXPECT().
+		Count(gomock.Any(), gomock.Eq(pool)).
+		Times(1).
+		Return(int64(20), nil)
+
+	repo.EXPECT().
+		GetAll(gomock.Any(), gomock.Eq(pool), gomock.Eq(models.JokeSort{}), gomock.Eq(dailyIndex(day, 20)), gomock.Eq(int64(1))).
+		Times(1).
+		Return([]models.Jusgo{joke}, nil)
+
+	service := NewService(repo)
+	dailyJoke, err := service.Srvc.GetDailyJoke(ctx, date)
+	require.NoError(t, err)
+	require.Equal(t, joke, dailyJoke)
+}
+
+func TestGetDailyJokeEmpty(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+
+	repo.EXPECT().
+		Count(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(int64(0), nil)
+
+	service := NewService(repo)
+	_, err := service.Srvc.GetDailyJoke(context.Background(), time.Now())
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestGetDailyJokeStableDuringTheDay(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryRepository().Repo
+	service := NewService(repo)
+
+	today := time.Now()
+	for i := range 10 {
+		joke := createJoke()
+		joke.Joke = fmt.Sprintf("joke %d", i)
+		joke.CreatedAt = today.AddDate(0, 0, -3)
+		_, err := repo.Create(ctx, joke)
+		require.NoError(t, err)
+	}
+
+	yesterday := today.AddDate(0, 0, -1)
+	before, err := service.Srvc.GetDailyJoke(ctx, today)
+	require.NoError(t, err)
+	beforeYesterday, err := service.Srvc.GetDailyJoke(ctx, yesterday)
+	require.NoError(t, err)
+
+	// a joke added in the middle of the day changes neither today's nor an earlier day's joke
+	for i := range 5 {
+		joke := createJoke()
+		joke.Joke = fmt.Sprintf("new joke %d", i)
+		joke.CreatedAt = time.Now()
+		_, err := repo.Create(ctx, joke)
+		require.NoError(t, err)
+	}
+
+	after, err := service.Srvc.GetDailyJoke(ctx, today)
 	require.NoError(t, err)
-	require.NotEmpty(t, allJokes)
-	require.Len(t, jokes, 10)
+	require.Equal(t, before.ID, after.ID)
+	afterYesterday, err := service.Srvc.GetDailyJoke(ctx, yesterday)
+	require.NoError(t, err)
+	require.Equal(t, beforeYesterday.ID, afterYesterday.ID)
+}
+
+func TestDailyIndex(t *testing.T) {
+	const total = 30
+	for _, start := range []int64{0, 19876 * total, -2 * total} { // 1970, mid 2024, 1969
+		seen := make(map[int64]bool)
+		for day := start; day < start+total; day++ {
+			index := dailyIndex(day, total)
+			require.GreaterOrEqual(t, index, int64(0))
+			require.Less(t, index, int64(total))
+			require.False(t, seen[index], "joke %d repeated within a cycle", index)
+			seen[index] = true
+
+			require.Equal(t, index, dailyIndex(day, total)) // same day, same joke
+		}
+	}
+
+	// consecutive days never repeat, not even across cycles
+	for _, total := range []int64{2, 3, 4, 7} {
+		for day := int64(-50); day < 500; day++ {
+			require.NotEqual(t, dailyIndex(day, total), dailyIndex(day+1, total), "day %d of %d jokes", day, total)
+		}
+	}
+
+	// every cycle is a permutation, whether or not total is a power of 4
+	for _, total := range []int64{3, 5, 16, 17, 1000} {
+		for _, cycle := range []int64{-1, 0, 1, 19876} {
+			seen := make(map[int64]bool)
+			for offset := range total {
+				position := dailyPosition(cycle, total, offset)
+				require.GreaterOrEqual(t, position, int64(0))
+				require.Less(t, position, total)
+				require.False(t, seen[position], "position %d repeated in cycle %d of %d jokes", position, cycle, total)
+				seen[position] = true
+			}
+		}
+	}
+
+	// the shuffle is never built, a huge pool costs as little as a small one
+	const huge = int64(1) << 40
+	index := dailyIndex(19876, huge)
+	require.GreaterOrEqual(t, index, int64(0))
+	require.Less(t, index, huge)
 }
 
 func TestUpdateJoke(t *testing.T) {
@@ -88,40 +515,273 @@ func TestUpdateJoke(t *testing.T) {
 
 	repo := mockproviders.NewMockRepositoryProvider(ctrl)
 
+	stored := joke
+	stored.Normalized = "yay i love coding"
+	stored.Signature = dedupe.Signature(stored.Normalized)
+	stored.Version = 3
+	old := createJoke()
+	old.ID = joke.ID
+	old.Version = 3
+
+	expectTransactions(repo)
 	repo.EXPECT().
-		Update(gomock.Any(), gomock.Eq(joke)).
+		Get(gomock.Any(), gomock.Eq(joke.ID)).
+		Times(5).
+		Return(old, nil)
+	repo.EXPECT().
+		GetSimilar(gomock.Any(), gomock.Eq(stored.Signature), gomock.Any()).
 		Times(1).
-		Return(joke, nil)
+		Return([]models.Jusgo{old}, nil)
+	repo.EXPECT().
+		Update(gomock.Any(), gomock.Eq(stored)).
+		Times(1).
+		Return(stored, nil)
+	expectRevision(t, repo, models.RevisionUpdate, old.Content(), stored.Content())
 
 	service := NewService(repo)
-	updatedJoke, err := service.Srvc.UpdateJoke(ctx, joke)
+	updatedJoke, err := service.Srvc.UpdateJoke(ctx, joke, 3, false)
 	require.NoError(t, err)
 	require.NotEmpty(t, updatedJoke)
-	require.Equal(t, joke, updatedJoke)
+	require.Equal(t, stored, updatedJoke)
+
+	// a caller that saw an older version
+	_, err = service.Srvc.UpdateJoke(ctx, joke, 2, false)
+	require.ErrorIs(t, err, repository.ErrVersionConflict)
+
+	// taking a text close to that of another joke
+	near := createJoke()
+	near.Joke = "Yay...I love coding"
+	similar := joke
+	similar.Joke = "Yay... I love coding so"
+	repo.EXPECT().
+		GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]models.Jusgo{near}, nil)
+
+	_, err = service.Srvc.UpdateJoke(ctx, similar, AnyVersion, false)
+	var dup *DuplicateError
+	require.ErrorAs(t, err, &dup)
+	require.Equal(t, near.ID, dup.ID)
+
+	// unless forced
+	repo.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, data models.Jusgo) (models.Jusgo, error) { return data, nil })
+	expectRevision(t, repo, models.RevisionUpdate, old.Content(), similar.Content())
+
+	_, err = service.Srvc.UpdateJoke(ctx, similar, AnyVersion, true)
+	require.NoError(t, err)
+
+	// taking the text of another joke
+	other := createJoke()
+	other.Joke = "Yay! I love coding"
+	repo.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(stored, repository.ErrDuplicateKey)
+	repo.EXPECT().
+		GetByNormalized(gomock.Any(), gomock.Eq(stored.Normalized)).
+		Times(1).
+		Return(other, nil)
+
+	_, err = service.Srvc.UpdateJoke(ctx, joke, AnyVersion, true)
+	require.ErrorAs(t, err, &dup)
+	require.Equal(t, other.ID, dup.ID)
+
+	// a change that leaves the text alone isn't checked
+	old.Normalized = stored.Normalized
+	tagged := joke
+	tagged.Tags = []string{"coding"}
+	repo.EXPECT().
+		Get(gomock.Any(), gomock.Eq(joke.ID)).
+		Times(1).
+		Return(old, nil)
+	repo.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, data models.Jusgo) (models.Jusgo, error) { return data, nil })
+	repo.EXPECT().AddRevision(gomock.Any(), gomock.Any()).Times(1).Return(models.Revision{}, nil)
+
+	_, err = service.Srvc.UpdateJoke(ctx, tagged, AnyVersion, false)
+	require.NoError(t, err)
+
+	// a joke that doesn't exist or is in the trash
+	repo.EXPECT().
+		Get(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(models.Jusgo{}, repository.ErrNotFound)
+
+	_, err = service.Srvc.UpdateJoke(ctx, createJoke(), AnyVersion, false)
+	require.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestPatchJoke(t *testing.T) {
+	ctx := context.Background()
+	old := createJoke()
+	old.Category, old.Tags, old.Version = "go", []string{"pun"}, 2
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+
+	expectTransactions(repo)
+	repo.EXPECT().
+		Get(gomock.Any(), gomock.Eq(old.ID)).
+		Times(3).
+		Return(old, nil)
+	repo.EXPECT().
+		GetSimilar(gomock.Any(), gomock.Eq(dedupe.Signature("patched joke")), gomock.Any()).
+		Times(1).
+		Return([]models.Jusgo{old}, nil)
+	repo.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, data models.Jusgo) (models.Jusgo, error) {
+			// only the patched field changed
+			require.Equal(t, "Patched joke", data.Joke)
+			require.Equal(t, "patched joke", data.Normalized)
+			require.Equal(t, old.Category, data.Category)
+			require.Equal(t, old.Tags, data.Tags)
+			require.Equal(t, old.CreatedAt, data.CreatedAt)
+			require.Equal(t, old.Version, data.Version)
+			require.True(t, data.UpdatedAt.After(old.UpdatedAt))
+			data.Version++
+			return data, nil
+		})
+	patched := old
+	patched.Joke = "Patched joke"
+	expectRevision(t, repo, models.RevisionUpdate, old.Content(), patched.Content())
+
+	service := NewService(repo)
+	joke, err := service.Srvc.PatchJoke(ctx, old.ID, func(joke *models.Jusgo) error {
+		joke.Joke = "Patched joke"
+		return nil
+	}, 2, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), joke.Version)
+
+	// a patch that fails isn't stored
+	errInvalid := errors.New("invalid joke")
+	_, err = service.Srvc.PatchJoke(ctx, old.ID, func(*models.Jusgo) error { return errInvalid }, AnyVersion, false)
+	require.ErrorIs(t, err, errInvalid)
+
+	_, err = service.Srvc.PatchJoke(ctx, old.ID, func(*models.Jusgo) error { return nil }, 1, false)
+	require.ErrorIs(t, err, repository.ErrVersionConflict)
+}
+
+func TestSignJokes(t *testing.T) {
+	ctx := context.Background()
+	legacy := createJoke()
+	normalized := createJoke()
+	normalized.Normalized = "roses are red"
+	short := createJoke()
+	short.Joke = "?!"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+	repo.EXPECT().
+		GetAfter(gomock.Any(), gomock.Eq(models.JokeFilter{Unsigned: true}), gomock.Eq(""), gomock.Eq(int64(exportBatchSize))).
+		Times(1).
+		Return([]models.Jusgo{legacy, normalized}, nil)
+	repo.EXPECT().
+		GetAfter(gomock.Any(), gomock.Eq(models.JokeFilter{Unsigned: true, Deleted: true}), gomock.Eq(""), gomock.Any()).
+		Times(1).
+		Return([]models.Jusgo{short}, nil)
+	repo.EXPECT().
+		SetSignature(gomock.Any(), gomock.Eq(legacy.ID), gomock.Eq(dedupe.Signature(dedupe.Normalize(legacy.Joke)))).
+		Times(1).
+		Return(nil)
+	repo.EXPECT().
+		SetSignature(gomock.Any(), gomock.Eq(normalized.ID), gomock.Eq(dedupe.Signature("roses are red"))).
+		Times(1).
+		Return(nil)
+	repo.EXPECT().
+		SetSignature(gomock.Any(), gomock.Eq(short.ID), gomock.Nil()).
+		Times(1).
+		Return(nil)
+
+	service := NewService(repo)
+	signed, err := service.Srvc.SignJokes(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), signed)
 }
 
 func TestDeleteJoke(t *testing.T) {
 	ctx := context.Background()
 	joke := createJoke()
+	joke.Version = 2
 
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	repo := mockproviders.NewMockRepositoryProvider(ctrl)
 
+	expectTransactions(repo)
 	repo.EXPECT().
-		Delete(gomock.Any(), gomock.Eq(joke.ID)).
-		Times(1).
+		Get(gomock.Any(), gomock.Eq(joke.ID)).
+		Times(3).
+		Return(joke, nil)
+	repo.EXPECT().
+		Delete(gomock.Any(), gomock.Eq(joke.ID), gomock.Eq(int64(2))).
+		Times(2).
 		Return(nil)
+	expectRevision(t, repo, models.RevisionDelete, joke.Content(), nil)
+	expectRevision(t, repo, models.RevisionDelete, joke.Content(), nil)
 
 	service := NewService(repo)
-	err := service.Srvc.DeleteJoke(ctx, joke.ID.Hex())
+	err := service.Srvc.DeleteJoke(ctx, joke.ID, 2)
+	require.NoError(t, err)
+
+	// the version of the joke is used when the caller doesn't care
+	err = service.Srvc.DeleteJoke(ctx, joke.ID, AnyVersion)
 	require.NoError(t, err)
+
+	err = service.Srvc.DeleteJoke(ctx, joke.ID, 1)
+	require.ErrorIs(t, err, repository.ErrVersionConflict)
+}
+
+// expectTransactions makes the transactions of repo call their function right away and commit.
+func expectTransactions(repo *mockproviders.MockRepositoryProvider) {
+	repo.EXPECT().
+		RunInTransaction(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(ctx context.Context, fn func(context.Context) error) error {
+			return fn(context.WithValue(ctx, transactionKey{}, true))
+		})
+}
+
+// transactionKey marks the contexts expectTransactions passes on.
+type transactionKey struct{}
+
+// inTransaction reports whether ctx is part of a transaction started with RunInTransaction.
+func inTransaction(ctx context.Context) bool {
+	return ctx.Value(transactionKey{}) != nil
+}
+
+// expectRevision expects a single revision that records action, changing old to new.
+func expectRevision(t *testing.T, repo *mockproviders.MockRepositoryProvider, action string, old, new *models.JokeContent) {
+	repo.EXPECT().
+		AddRevision(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, rev models.Revision) (models.Revision, error) {
+			require.Empty(t, rev.ID) // given by the repository
+			require.Equal(t, action, rev.Action)
+			require.Equal(t, old, rev.Old)
+			require.Equal(t, new, rev.New)
+			require.NotEmpty(t, rev.Actor)
+			require.WithinDuration(t, time.Now(), rev.CreatedAt, time.Minute)
+			rev.Number = 1
+			return rev, nil
+		})
 }
 
 func createJoke() models.Jusgo {
 	return models.Jusgo{
-		ID:        primitive.NewObjectID(),
+		ID:        repository.NewID(),
 		Joke:      "Roses are red violets are blue...unknown error on line 42",
 		UpdatedAt: time.Now(),
 		CreatedAt: time.Now(),
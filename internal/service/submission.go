@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/dedupe"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+func (s *serviceImpl) SubmitJoke(ctx context.Context, data models.Submission) (models.Submission, error) {
+	data.Status = models.SubmissionPending
+	data.Reason, data.JokeID = "", ""
+
+	// no point in having an admin look at a joke that would be turned down as a duplicate. Anyone can
+	// submit, so only the cheap exact check runs here, similar jokes are caught when it is approved.
+	stored, err := s.repo.GetByNormalized(ctx, dedupe.Normalize(submissionJoke(data).Text()))
+	switch {
+	case err == nil:
+		return data, &DuplicateError{ID: stored.ID, Similarity: 1}
+	case !errors.Is(err, repository.ErrNotFound):
+		return data, err
+	}
+	return s.repo.CreateSubmission(ctx, data)
+}
+
+func (s *serviceImpl) GetSubmission(ctx context.Context, id string) (models.Submission, error) {
+	return s.repo.GetSubmission(ctx, id)
+}
+
+func (s *serviceImpl) GetSubmissions(ctx context.Context, status string, page, limit int) ([]models.Submission, int64, error) {
+	total, err := s.repo.CountSubmissions(ctx, status)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	skip := (page - 1) * limit
+	submissions, err := s.repo.GetSubmissions(ctx, status, int64(skip), int64(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	return submissions, total, nil
+}
+
+// UpdateSubmission reads and writes the submission in one transaction, and the write only goes through while
+// it is pending, so an edit that races ApproveSubmission or RejectSubmission fails with ErrNotPending.
+func (s *serviceImpl) UpdateSubmission(ctx context.Context, id string, data models.Submission) (models.Submission, error) {
+	var submission models.Submission
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if submission, err = s.pendingSubmission(ctx, id); err != nil {
+			return err
+		}
+
+		submission.Type, submission.Joke, submission.Setup, submission.Delivery = data.Type, data.Joke, data.Setup, data.Delivery
+		submission.Category, submission.Tags = data.Category, data.Tags
+		submission.UpdatedAt = time.Now()
+		submission, err = s.repo.UpdateSubmission(ctx, submission)
+		return err
+	})
+	return submission, err
+}
+
+// ApproveSubmission stores the joke and marks the submission approved in one transaction, so a
+// submission is never left pending for a joke that was stored, nor approved for one that wasn't.
+func (s *serviceImpl) ApproveSubmission(ctx context.Context, id string, force bool) (models.Jusgo, error) {
+	var data, joke models.Jusgo
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		submission, err := s.pendingSubmission(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		data = submissionJoke(submission)
+		data.CreatedAt, data.UpdatedAt = now, now
+		normalize(&data)
+		if joke, err = s.createJoke(ctx, data, force); err != nil {
+			return err
+		}
+
+		submission.Status = models.SubmissionApproved
+		submission.JokeID = joke.ID
+		submission.UpdatedAt = now
+		_, err = s.repo.UpdateSubmission(ctx, submission)
+		return err
+	})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return joke, s.duplicateKeyError(ctx, data, err)
+	}
+	return joke, err
+}
+
+// submissionJoke returns the joke submission would be stored as, without an ID or timestamps.
+func submissionJoke(submission models.Submission) models.Jusgo {
+	return models.Jusgo{
+		Type:     submission.Type,
+		Joke:     submission.Joke,
+		Setup:    submission.Setup,
+		Delivery: submission.Delivery,
+		Category: submission.Category,
+		Tags:     slices.Clone(submission.Tags),
+	}
+}
+
+// RejectSubmission is checked and written like UpdateSubmission, a submission that is approved meanwhile stays approved.
+func (s *serviceImpl) RejectSubmission(ctx context.Context, id, reason string) (models.Submission, error) {
+	var submission models.Submission
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if submission, err = s.pendingSubmission(ctx, id); err != nil {
+			return err
+		}
+
+		submission.Status = models.SubmissionRejected
+		submission.Reason = reason
+		submission.UpdatedAt = time.Now()
+		submission, err = s.repo.UpdateSubmission(ctx, submission)
+		return err
+	})
+	return submission, err
+}
+
+// pendingSubmission returns the submission with id, or ErrNotPending if it was already moderated.
+func (s *serviceImpl) pendingSubmission(ctx context.Context, id string) (models.Submission, error) {
+	submission, err := s.repo.GetSubmission(ctx, id)
+	if err != nil {
+		return submission, err
+	}
+	if submission.Status != models.SubmissionPending {
+		return submission, ErrNotPending
+	}
+	return submission, nil
+}
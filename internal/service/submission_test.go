@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	mockproviders "github.com/zde37/Jusgo/internal/mock"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSubmitJoke(t *testing.T) {
+	ctx := context.Background()
+	stored := createJoke()
+	stored.Normalized = "roses are red violets are blue unknown error on line 42"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+	// only the exact check runs, similar jokes are caught when the submission is approved
+	repo.EXPECT().
+		GetByNormalized(gomock.Any(), gomock.Eq("there are 10 types of people those who understand binary and those who dont")).
+		Times(1).
+		Return(models.Jusgo{}, repository.ErrNotFound)
+	repo.EXPECT().
+		GetByNormalized(gomock.Any(), gomock.Eq(stored.Normalized)).
+		Times(1).
+		Return(stored, nil)
+	repo.EXPECT().
+		CreateSubmission(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, data models.Submission) (models.Submission, error) { return data, nil })
+
+	service := NewService(repo)
+
+	data := createSubmission(models.SubmissionApproved)
+	data.Joke = "There are 10 types of people, those who understand binary and those who don't"
+	data.JokeID = stored.ID
+	submission, err := service.Srvc.SubmitJoke(ctx, data)
+	require.NoError(t, err)
+	require.Equal(t, models.SubmissionPending, submission.Status)
+	require.Empty(t, submission.JokeID)
+
+	_, err = service.Srvc.SubmitJoke(ctx, createSubmission(""))
+	var dup *DuplicateError
+	require.ErrorAs(t, err, &dup)
+	require.Equal(t, stored.ID, dup.ID)
+}
+
+func TestUpdateSubmission(t *testing.T) {
+	ctx := context.Background()
+	pending := createSubmission(models.SubmissionPending)
+	pending.CreatedAt = time.Now().Add(-time.Hour)
+
+	testData := []struct {
+		Name    string
+		stub    func(repo *mockproviders.MockRepositoryProvider)
+		wantErr error
+	}{
+		{
+			Name: "Pending submission",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Eq(pending.ID)).Times(1).DoAndReturn(
+					func(ctx context.Context, _ string) (models.Submission, error) {
+						require.True(t, inTransaction(ctx))
+						return pending, nil
+					},
+				)
+				repo.EXPECT().UpdateSubmission(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(ctx context.Context, data models.Submission) (models.Submission, error) {
+						require.True(t, inTransaction(ctx))
+						return data, nil
+					},
+				)
+			},
+		},
+		{
+			Name: "Moderated meanwhile",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Any()).Times(1).Return(pending, nil)
+				repo.EXPECT().UpdateSubmission(gomock.Any(), gomock.Any()).Times(1).Return(models.Submission{}, repository.ErrNotPending)
+			},
+			wantErr: ErrNotPending,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			expectTransactions(repo)
+			tc.stub(repo)
+
+			data := models.Submission{
+				Type: models.TypeTwoPart, Setup: "Knock knock", Delivery: "Race condition", Category: "go",
+				Status: models.SubmissionApproved, CreatedAt: time.Now(),
+			}
+			service := NewService(repo)
+			submission, err := service.Srvc.UpdateSubmission(ctx, pending.ID, data)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, pending.ID, submission.ID)
+			require.Equal(t, models.TypeTwoPart, submission.Type)
+			require.Empty(t, submission.Joke)
+			require.Equal(t, "Race condition", submission.Delivery)
+			require.Equal(t, models.SubmissionPending, submission.Status) // only the joke changes
+			require.Equal(t, pending.CreatedAt, submission.CreatedAt)
+			require.True(t, submission.UpdatedAt.After(pending.UpdatedAt))
+		})
+	}
+}
+
+func TestApproveSubmission(t *testing.T) {
+	ctx := context.Background()
+	pending := createSubmission(models.SubmissionPending)
+	rejected := pending
+	rejected.Status = models.SubmissionRejected
+	jokeID := repository.NewID()
+	errDisconnected := errors.New("client disconnected")
+
+	testData := []struct {
+		Name    string
+		stub    func(repo *mockproviders.MockRepositoryProvider)
+		wantErr error
+	}{
+		{
+			Name: "Pending submission",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Eq(pending.ID)).Times(1).DoAndReturn(
+					func(ctx context.Context, _ string) (models.Submission, error) {
+						require.True(t, inTransaction(ctx))
+						return pending, nil
+					},
+				)
+				repo.EXPECT().GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]models.Jusgo{}, nil)
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(ctx context.Context, joke models.Jusgo) (models.Jusgo, error) {
+						require.True(t, inTransaction(ctx))
+						require.Empty(t, joke.ID) // given by the repository
+						require.Equal(t, pending.Joke, joke.Joke)
+						require.Equal(t, pending.Category, joke.Category)
+						require.Equal(t, pending.Tags, joke.Tags)
+						require.Equal(t, "roses are red violets are blue unknown error on line 42", joke.Normalized)
+						require.Zero(t, joke.Version)
+						joke.ID = jokeID
+						return joke, nil
+					},
+				)
+				repo.EXPECT().AddRevision(gomock.Any(), gomock.Any()).Times(1).Return(models.Revision{}, nil)
+				repo.EXPECT().UpdateSubmission(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(ctx context.Context, submission models.Submission) (models.Submission, error) {
+						require.True(t, inTransaction(ctx))
+						require.Equal(t, pending.ID, submission.ID)
+						require.Equal(t, models.SubmissionApproved, submission.Status)
+						require.Equal(t, jokeID, submission.JokeID)
+						return submission, nil
+					},
+				)
+			},
+		},
+		{
+			Name: "Rejected submission",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Any()).Times(1).Return(rejected, nil)
+			},
+			wantErr: ErrNotPending,
+		},
+		{
+			Name: "Duplicate joke",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				stored := createJoke()
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Any()).Times(1).Return(pending, nil)
+				repo.EXPECT().GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]models.Jusgo{stored}, nil)
+			},
+			wantErr: &DuplicateError{},
+		},
+		{
+			Name: "Same text as a joke stored meanwhile",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				stored := createJoke()
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Any()).Times(1).Return(pending, nil)
+				repo.EXPECT().GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]models.Jusgo{}, nil)
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).Return(models.Jusgo{}, repository.ErrDuplicateKey)
+				repo.EXPECT().GetByNormalized(gomock.Any(), gomock.Any()).Times(1).Return(stored, nil)
+			},
+			wantErr: &DuplicateError{},
+		},
+		{
+			Name: "Marking it approved fails",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Any()).Times(1).Return(pending, nil)
+				repo.EXPECT().GetSimilar(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]models.Jusgo{}, nil)
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, joke models.Jusgo) (models.Jusgo, error) { return joke, nil },
+				)
+				repo.EXPECT().AddRevision(gomock.Any(), gomock.Any()).Times(1).Return(models.Revision{}, nil)
+				repo.EXPECT().UpdateSubmission(gomock.Any(), gomock.Any()).Times(1).Return(models.Submission{}, errDisconnected)
+			},
+			// the transaction is rolled back, the joke isn't stored either
+			wantErr: errDisconnected,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			expectTransactions(repo)
+			tc.stub(repo)
+
+			service := NewService(repo)
+			joke, err := service.Srvc.ApproveSubmission(ctx, pending.ID, false)
+			switch want := tc.wantErr.(type) {
+			case nil:
+				require.NoError(t, err)
+				require.Equal(t, pending.Joke, joke.Joke)
+			case *DuplicateError:
+				require.ErrorAs(t, err, &want)
+			default:
+				require.ErrorIs(t, err, want)
+			}
+		})
+	}
+}
+
+func TestRejectSubmission(t *testing.T) {
+	ctx := context.Background()
+	pending := createSubmission(models.SubmissionPending)
+
+	testData := []struct {
+		Name    string
+		stub    func(repo *mockproviders.MockRepositoryProvider)
+		wantErr error
+	}{
+		{
+			Name: "Pending submission",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Eq(pending.ID)).Times(1).DoAndReturn(
+					func(ctx context.Context, _ string) (models.Submission, error) {
+						require.True(t, inTransaction(ctx))
+						return pending, nil
+					},
+				)
+				repo.EXPECT().UpdateSubmission(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(ctx context.Context, data models.Submission) (models.Submission, error) {
+						require.True(t, inTransaction(ctx))
+						return data, nil
+					},
+				)
+			},
+		},
+		{
+			// the approval committed after the submission was read, it must not be overwritten
+			Name: "Approved meanwhile",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetSubmission(gomock.Any(), gomock.Any()).Times(1).Return(pending, nil)
+				repo.EXPECT().UpdateSubmission(gomock.Any(), gomock.Any()).Times(1).Return(models.Submission{}, repository.ErrNotPending)
+			},
+			wantErr: ErrNotPending,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			expectTransactions(repo)
+			tc.stub(repo)
+
+			service := NewService(repo)
+			submission, err := service.Srvc.RejectSubmission(ctx, pending.ID, "not funny")
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, models.SubmissionRejected, submission.Status)
+			require.Equal(t, "not funny", submission.Reason)
+		})
+	}
+}
+
+// createSubmission returns a submission with status and the text of createJoke.
+func createSubmission(status string) models.Submission {
+	return models.Submission{
+		ID:        repository.NewID(),
+		Type:      models.TypeSingle,
+		Joke:      "Roses are red violets are blue...unknown error on line 42",
+		Category:  "go",
+		Tags:      []string{"pun"},
+		Status:    status,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
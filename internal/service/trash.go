@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/dedupe"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+func (s *serviceImpl) RestoreJoke(ctx context.Context, id string) (models.Jusgo, error) {
+	joke, err := s.repo.GetDeleted(ctx, id)
+	if err != nil {
+		return joke, err
+	}
+
+	// the trash doesn't keep normalized texts, so the same joke may have been stored in the meantime
+	joke.Normalized = dedupe.Normalize(joke.Text())
+	restored := joke
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if restored, err = s.repo.Restore(ctx, id, joke.Normalized); err != nil {
+			return err
+		}
+		return s.addRevision(ctx, models.Revision{JokeID: restored.ID, Action: models.RevisionRestore, New: restored.Content()})
+	})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return joke, s.duplicateKeyError(ctx, joke, err)
+	}
+	return restored, err
+}
+
+func (s *serviceImpl) PurgeJoke(ctx context.Context, id string) error {
+	return s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		joke, err := s.repo.GetDeleted(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.Purge(ctx, id); err != nil {
+			return err
+		}
+		return s.addRevision(ctx, models.Revision{JokeID: joke.ID, Action: models.RevisionPurge, Old: joke.Content()})
+	})
+}
+
+// purgeBatchSize caps how many jokes PurgeTrash removes in one transaction.
+const purgeBatchSize = 500
+
+func (s *serviceImpl) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for {
+		var batch []models.Jusgo
+		err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			if batch, err = s.repo.PurgeDeletedBefore(ctx, before, purgeBatchSize); err != nil {
+				return err
+			}
+			for _, joke := range batch {
+				err := s.addRevision(ctx, models.Revision{JokeID: joke.ID, Action: models.RevisionPurge, Old: joke.Content()})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged += int64(len(batch))
+		if len(batch) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
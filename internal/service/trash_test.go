@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zde37/Jusgo/internal/dedupe"
+	mockproviders "github.com/zde37/Jusgo/internal/mock"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRestoreJoke(t *testing.T) {
+	ctx := context.Background()
+	deletedAt := time.Now()
+	trashed := createJoke()
+	trashed.DeletedAt = &deletedAt
+	normalized := dedupe.Normalize(trashed.Text())
+
+	existing := createJoke()
+	existing.ID = repository.NewID()
+	existing.Normalized = normalized
+
+	testData := []struct {
+		Name    string
+		stub    func(repo *mockproviders.MockRepositoryProvider)
+		wantErr error
+	}{
+		{
+			Name: "OK",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				restored := trashed
+				restored.DeletedAt = nil
+				repo.EXPECT().GetDeleted(gomock.Any(), gomock.Eq(trashed.ID)).Times(1).Return(trashed, nil)
+				repo.EXPECT().Restore(gomock.Any(), gomock.Eq(trashed.ID), gomock.Eq(normalized)).Times(1).Return(restored, nil)
+				expectRevision(t, repo, models.RevisionRestore, nil, restored.Content())
+			},
+		},
+		{
+			Name: "Not in the trash",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetDeleted(gomock.Any(), gomock.Any()).Times(1).Return(models.Jusgo{}, repository.ErrNotFound)
+			},
+			wantErr: repository.ErrNotFound,
+		},
+		{
+			Name: "Duplicate",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().GetDeleted(gomock.Any(), gomock.Any()).Times(1).Return(trashed, nil)
+				repo.EXPECT().Restore(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(models.Jusgo{}, repository.ErrDuplicateKey)
+				repo.EXPECT().GetByNormalized(gomock.Any(), gomock.Eq(normalized)).Times(1).Return(existing, nil)
+			},
+			wantErr: &DuplicateError{ID: existing.ID, Similarity: 1},
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			expectTransactions(repo)
+			tc.stub(repo)
+
+			service := NewService(repo)
+			joke, err := service.Srvc.RestoreJoke(ctx, trashed.ID)
+			if tc.wantErr != nil {
+				require.Equal(t, tc.wantErr, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Nil(t, joke.DeletedAt)
+			require.Equal(t, trashed.ID, joke.ID)
+		})
+	}
+}
+
+func TestPurgeJoke(t *testing.T) {
+	ctx := context.Background()
+	trashed := createJoke()
+	deletedAt := time.Now()
+	trashed.DeletedAt = &deletedAt
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+	expectTransactions(repo)
+	repo.EXPECT().GetDeleted(gomock.Any(), gomock.Eq(trashed.ID)).Times(1).Return(trashed, nil)
+	repo.EXPECT().Purge(gomock.Any(), gomock.Eq(trashed.ID)).Times(1).DoAndReturn(
+		func(ctx context.Context, _ string) error {
+			require.True(t, inTransaction(ctx))
+			return nil
+		},
+	)
+	expectRevision(t, repo, models.RevisionPurge, trashed.Content(), nil)
+
+	service := NewService(repo)
+	require.NoError(t, service.Srvc.PurgeJoke(ctx, trashed.ID))
+
+	// a joke that isn't in the trash
+	repo.EXPECT().GetDeleted(gomock.Any(), gomock.Any()).Times(1).Return(models.Jusgo{}, repository.ErrNotFound)
+	require.ErrorIs(t, service.Srvc.PurgeJoke(ctx, trashed.ID), repository.ErrNotFound)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	before := time.Now().Add(-time.Hour)
+	full := make([]models.Jusgo, purgeBatchSize)
+	for i := range full {
+		full[i] = createJoke()
+	}
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+	expectTransactions(repo)
+	gomock.InOrder(
+		repo.EXPECT().PurgeDeletedBefore(gomock.Any(), gomock.Eq(before), gomock.Eq(int64(purgeBatchSize))).Times(1).Return(full, nil),
+		repo.EXPECT().PurgeDeletedBefore(gomock.Any(), gomock.Eq(before), gomock.Any()).Times(1).Return(full[:2], nil),
+	)
+	// one for every purged joke, in the transaction that purged it
+	repo.EXPECT().AddRevision(gomock.Any(), gomock.Any()).Times(purgeBatchSize + 2).DoAndReturn(
+		func(ctx context.Context, rev models.Revision) (models.Revision, error) {
+			require.True(t, inTransaction(ctx))
+			require.Equal(t, models.RevisionPurge, rev.Action)
+			require.NotNil(t, rev.Old)
+			require.Nil(t, rev.New)
+			return rev, nil
+		},
+	)
+
+	service := NewService(repo)
+	purged, err := service.Srvc.PurgeTrash(context.Background(), before)
+	require.NoError(t, err)
+	require.Equal(t, int64(purgeBatchSize+2), purged)
+}
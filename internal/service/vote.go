@@ -0,0 +1,113 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/rating"
+	"github.com/zde37/Jusgo/internal/repository"
+)
+
+func (s *serviceImpl) Vote(ctx context.Context, id, client string, up bool) (models.Jusgo, error) {
+	joke, err := s.repo.Get(ctx, id) // don't store votes on jokes that don't exist
+	if err != nil {
+		return joke, err
+	}
+
+	// only a hash of the client is stored, IP addresses don't need to be kept around
+	sum := sha256.Sum256([]byte(client))
+	now := time.Now()
+	vote := models.Vote{
+		JokeID:    joke.ID,
+		Client:    hex.EncodeToString(sum[:]),
+		Up:        up,
+		Window:    now.Truncate(models.VoteWindow),
+		CreatedAt: now,
+	}
+
+	// the vote and the counts change together, a vote that wasn't counted would still block the next one
+	var voted models.Jusgo
+	err = s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.AddVote(ctx, vote); err != nil {
+			return err
+		}
+		var err error
+		if up {
+			voted, err = s.repo.IncrementVotes(ctx, id, 1, 0)
+		} else {
+			voted, err = s.repo.IncrementVotes(ctx, id, 0, 1)
+		}
+		return err
+	})
+	if errors.Is(err, repository.ErrDuplicateKey) {
+		return joke, ErrAlreadyVoted
+	}
+	if err != nil {
+		return joke, err
+	}
+	return voted, nil
+}
+
+func (s *serviceImpl) GetTopJokes(ctx context.Context, since time.Time, limit int) ([]models.RankedJoke, error) {
+	if since.IsZero() {
+		return s.getTopJokesOfAllTime(ctx, limit)
+	}
+
+	totals, err := s.repo.GetVoteTotals(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := make([]models.RankedJoke, 0, len(totals))
+	for _, total := range totals {
+		if score := rating.Wilson(total.Upvotes, total.Downvotes); score > 0 {
+			ranked = append(ranked, models.RankedJoke{Jusgo: models.Jusgo{ID: total.JokeID}, Score: score})
+		}
+	}
+	slices.SortFunc(ranked, func(a, b models.RankedJoke) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(b.ID, a.ID)
+	})
+
+	top := make([]models.RankedJoke, 0, min(limit, len(ranked)))
+	for _, r := range ranked {
+		if len(top) == limit {
+			break
+		}
+		joke, err := s.repo.Get(ctx, r.ID)
+		if errors.Is(err, repository.ErrNotFound) { // deleted since it was voted on
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		top = append(top, models.RankedJoke{Jusgo: joke, Score: r.Score})
+	}
+	return top, nil
+}
+
+// getTopJokesOfAllTime ranks jokes by the rating stored with them, which counts every vote.
+func (s *serviceImpl) getTopJokesOfAllTime(ctx context.Context, limit int) ([]models.RankedJoke, error) {
+	jokes, err := s.repo.GetAll(ctx, models.JokeFilter{}, models.JokeSort{Field: models.SortRating, Descending: true}, 0, int64(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	top := make([]models.RankedJoke, 0, len(jokes))
+	for _, joke := range jokes {
+		if joke.Rating <= 0 { // sorted, the rest aren't rated either
+			break
+		}
+		top = append(top, models.RankedJoke{Jusgo: joke, Score: joke.Rating})
+	}
+	return top, nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	mockproviders "github.com/zde37/Jusgo/internal/mock"
+	"github.com/zde37/Jusgo/internal/models"
+	"github.com/zde37/Jusgo/internal/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestVote(t *testing.T) {
+	ctx := context.Background()
+	joke := createJoke()
+
+	testData := []struct {
+		Name    string
+		up      bool
+		stub    func(repo *mockproviders.MockRepositoryProvider)
+		wantErr error
+	}{
+		{
+			Name: "Upvote",
+			up:   true,
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().Get(gomock.Any(), gomock.Eq(joke.ID)).Times(1).Return(joke, nil)
+				repo.EXPECT().AddVote(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, vote models.Vote) error {
+						require.Equal(t, joke.ID, vote.JokeID)
+						require.True(t, vote.Up)
+						require.NotContains(t, vote.Client, "127.0.0.1")
+						require.Equal(t, vote.CreatedAt.Truncate(models.VoteWindow), vote.Window)
+						return nil
+					},
+				)
+				repo.EXPECT().IncrementVotes(gomock.Any(), gomock.Eq(joke.ID), gomock.Eq(int64(1)), gomock.Eq(int64(0))).Times(1).Return(joke, nil)
+			},
+		},
+		{
+			Name: "Downvote",
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().Get(gomock.Any(), gomock.Any()).Times(1).Return(joke, nil)
+				repo.EXPECT().AddVote(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				repo.EXPECT().IncrementVotes(gomock.Any(), gomock.Eq(joke.ID), gomock.Eq(int64(0)), gomock.Eq(int64(1))).Times(1).Return(joke, nil)
+			},
+		},
+		{
+			Name: "Already voted",
+			up:   true,
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().Get(gomock.Any(), gomock.Any()).Times(1).Return(joke, nil)
+				repo.EXPECT().AddVote(gomock.Any(), gomock.Any()).Times(1).Return(repository.ErrDuplicateKey)
+			},
+			wantErr: ErrAlreadyVoted,
+		},
+		{
+			Name: "Missing joke",
+			up:   true,
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().Get(gomock.Any(), gomock.Any()).Times(1).Return(models.Jusgo{}, repository.ErrNotFound)
+			},
+			wantErr: repository.ErrNotFound,
+		},
+		{
+			Name: "Counting fails",
+			up:   true,
+			stub: func(repo *mockproviders.MockRepositoryProvider) {
+				repo.EXPECT().Get(gomock.Any(), gomock.Any()).Times(1).Return(joke, nil)
+				repo.EXPECT().AddVote(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(ctx context.Context, _ models.Vote) error {
+					require.True(t, inTransaction(ctx), "the vote is rolled back with the count")
+					return nil
+				})
+				repo.EXPECT().IncrementVotes(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(ctx context.Context, _ string, _, _ int64) (models.Jusgo, error) {
+						require.True(t, inTransaction(ctx))
+						return models.Jusgo{}, context.DeadlineExceeded
+					},
+				)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testData {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := mockproviders.NewMockRepositoryProvider(ctrl)
+			expectTransactions(repo)
+			tc.stub(repo)
+
+			service := NewService(repo)
+			_, err := service.Srvc.Vote(ctx, joke.ID, "ip:127.0.0.1", tc.up)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestGetTopJokes(t *testing.T) {
+	ctx := context.Background()
+	best, good, deleted, disliked := createJoke(), createJoke(), createJoke(), createJoke()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repo := mockproviders.NewMockRepositoryProvider(ctrl)
+	since := time.Now().Add(-24 * time.Hour)
+	repo.EXPECT().
+		GetVoteTotals(gomock.Any(), gomock.Eq(since)).
+		Times(1).
+		Return([]models.VoteTotal{
+			{JokeID: disliked.ID, Downvotes: 5},
+			{JokeID: good.ID, Upvotes: 3, Downvotes: 1},
+			{JokeID: deleted.ID, Upvotes: 4, Downvotes: 1},
+			{JokeID: best.ID, Upvotes: 20},
+		}, nil)
+	repo.EXPECT().Get(gomock.Any(), gomock.Eq(best.ID)).Times(1).Return(best, nil)
+	repo.EXPECT().Get(gomock.Any(), gomock.Eq(deleted.ID)).Times(1).Return(models.Jusgo{}, repository.ErrNotFound)
+	repo.EXPECT().Get(gomock.Any(), gomock.Eq(good.ID)).Times(1).Return(good, nil)
+
+	service := NewService(repo)
+	top, err := service.Srvc.GetTopJokes(ctx, since, 2)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	require.Equal(t, best.ID, top[0].ID)
+	require.Equal(t, good.ID, top[1].ID)
+	require.Greater(t, top[0].Score, top[1].Score)
+
+	// of all time, by the stored rating
+	best.Rating = 0.8
+	repo.EXPECT().
+		GetAll(gomock.Any(), gomock.Any(), gomock.Eq(models.JokeSort{Field: models.SortRating, Descending: true}), gomock.Eq(int64(0)), gomock.Eq(int64(10))).
+		Times(1).
+		Return([]models.Jusgo{best, good}, nil)
+
+	top, err = service.Srvc.GetTopJokes(ctx, time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	require.Equal(t, best.ID, top[0].ID)
+	require.Equal(t, best.Rating, top[0].Score)
+}